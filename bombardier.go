@@ -19,13 +19,16 @@ func main() {
 		fmt.Println(err)
 		os.Exit(lib.ExitFailure)
 	}
-	c := make(chan os.Signal, 1)
+	c := make(chan os.Signal, 2)
 	signal.Notify(c, os.Interrupt)
 
 	go func() {
 		<-c
-		bombardier.Barrier.Cancel()
+		bombardier.Cancel()
+		<-c
+		bombardier.Abort()
 	}()
+	printSnapshotsOnSignal(bombardier)
 	bombardier.Bombard()
 	if bombardier.Conf.PrintResult {
 		bombardier.PrintStats()
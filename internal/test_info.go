@@ -11,6 +11,10 @@ import (
 type TestInfo struct {
 	Spec   Spec
 	Result Results
+
+	// Baseline holds results of some previous test to compare
+	// against, nil if no baseline were provided.
+	Baseline *Baseline
 }
 
 // Header represents HTTP header.
@@ -40,8 +44,19 @@ type Spec struct {
 	Stream     bool
 	Timeout    time.Duration
 	ClientType ClientType
+	// CustomClient is the name of the custom client used to
+	// perform the test, empty if a built-in one were used.
+	CustomClient string
 
 	Rate *uint64
+	// PipelineDepth is the number of requests sent over each
+	// connection without waiting for responses, it's zero unless
+	// pipelined client were used.
+	PipelineDepth uint64
+
+	// Proxy is URL of the proxy used (with password redacted), empty
+	// if connections weren't tunneled through a proxy.
+	Proxy string
 }
 
 // IsTimedTest tells if the test was limited by time.
@@ -58,19 +73,142 @@ func (s Spec) IsTestWithNumberOfReqs() bool {
 // IsFastHTTP tells whether fasthttp were used as HTTP client to
 // perform the test.
 func (s Spec) IsFastHTTP() bool {
-	return s.ClientType == FastHTTP
+	return !s.IsCustomClient() && s.ClientType == FastHTTP
 }
 
 // IsNetHTTPV1 tells whether Go's default net/http library and
 // HTTP/1.x were used to perform the test.
 func (s Spec) IsNetHTTPV1() bool {
-	return s.ClientType == NetHTTP1
+	return !s.IsCustomClient() && s.ClientType == NetHTTP1
 }
 
 // IsNetHTTPV2 tells whether Go's default net/http library and
 // HTTP/1.x (or HTTP/2.0, if possible) were used to perform the test.
 func (s Spec) IsNetHTTPV2() bool {
-	return s.ClientType == NetHTTP2
+	return !s.IsCustomClient() && s.ClientType == NetHTTP2
+}
+
+// IsHTTP3 tells whether quic-go's HTTP/3 client were used to perform
+// the test.
+func (s Spec) IsHTTP3() bool {
+	return !s.IsCustomClient() && s.ClientType == HTTP3
+}
+
+// IsGRPC tells whether gRPC client were used to perform the test.
+func (s Spec) IsGRPC() bool {
+	return !s.IsCustomClient() && s.ClientType == GRPC
+}
+
+// IsWebSocket tells whether WebSocket client were used to perform
+// the test.
+func (s Spec) IsWebSocket() bool {
+	return !s.IsCustomClient() && s.ClientType == WebSocket
+}
+
+// IsRawTCP tells whether raw TCP client were used to perform the test.
+func (s Spec) IsRawTCP() bool {
+	return !s.IsCustomClient() && s.ClientType == RawTCP
+}
+
+// IsPipelined tells whether fasthttp were used to pipeline requests
+// over HTTP/1.1 connections.
+func (s Spec) IsPipelined() bool {
+	return !s.IsCustomClient() && s.ClientType == Pipelined
+}
+
+// IsCustomClient tells whether a custom client were used to perform
+// the test.
+func (s Spec) IsCustomClient() bool {
+	return s.CustomClient != ""
+}
+
+// WebSocketStats contains WebSocket-specific results of the test.
+type WebSocketStats struct {
+	// Connects and ConnectFailures are numbers of successful and
+	// failed opening handshakes.
+	Connects, ConnectFailures uint64
+	// Messages is the number of messages echoed by the server.
+	Messages uint64
+	// CloseCodes maps codes of closing handshakes initiated by the
+	// server to number of times they occurred.
+	CloseCodes map[int]uint64
+}
+
+// ConnectionStats contains numbers of connections opened by HTTP
+// clients, requests sent over previously used connections and
+// connections closed by the server.
+type ConnectionStats struct {
+	Opened, Reused, ClosedByPeer uint64
+}
+
+// HTTP2Stats contains numbers of HTTP/2 connections, streams sent over
+// them, GOAWAY and RST_STREAM frames received from the server.
+type HTTP2Stats struct {
+	Connections, Streams, GoAways, StreamResets uint64
+}
+
+// StreamsPerConnection returns the average number of streams sent
+// over a connection.
+func (s HTTP2Stats) StreamsPerConnection() float64 {
+	if s.Connections == 0 {
+		return 0
+	}
+	return float64(s.Streams) / float64(s.Connections)
+}
+
+// QUICStats contains numbers of QUIC handshakes completed by HTTP/3
+// client and of those, that resumed sessions using 0-RTT.
+type QUICStats struct {
+	Handshakes, ZeroRTT uint64
+}
+
+// BackendStats contains numbers of connections established to an
+// address of the target, failures to connect to it and bytes sent
+// over those connections.
+type BackendStats struct {
+	Connections, DialFailures, BytesRead, BytesWritten uint64
+}
+
+// SourceStats contains number of connections made from a local
+// address and errors, that occurred while connecting from it.
+type SourceStats struct {
+	Connections uint64
+	// Errors are sorted by frequency, most frequent first.
+	Errors []ErrorWithCount
+}
+
+// RedirectStats contains numbers of redirects followed by HTTP clients.
+type RedirectStats struct {
+	// Chains is the number of requests redirected at least once.
+	Chains uint64
+	// Codes maps status codes of intermediate responses to number
+	// of times they were followed.
+	Codes map[int]uint64
+}
+
+// Followed returns the total number of redirects followed.
+func (s RedirectStats) Followed() uint64 {
+	sum := uint64(0)
+	for _, n := range s.Codes {
+		sum += n
+	}
+	return sum
+}
+
+// CompressionStats contains number of responses decoded by HTTP
+// clients and their sizes before and after decoding.
+type CompressionStats struct {
+	Responses                          uint64
+	CompressedBytes, DecompressedBytes uint64
+}
+
+// Ratio returns the ratio of decompressed size of responses to
+// compressed one.
+func (s CompressionStats) Ratio() float64 {
+	if s.CompressedBytes == 0 {
+		return 0
+	}
+	return float64(s.DecompressedBytes) / float64(s.CompressedBytes)
 }
 
 // Results holds results of the test.
@@ -81,10 +219,75 @@ type Results struct {
 	Req1XX, Req2XX, Req3XX, Req4XX, Req5XX uint64
 	Others                                 uint64
 
+	// GRPCCodes maps names of gRPC codes (i.e. "OK", "UNAVAILABLE")
+	// to number of times they occurred, it's nil unless gRPC
+	// client were used.
+	GRPCCodes map[string]uint64
+	// WebSocket is nil unless WebSocket client were used.
+	WebSocket *WebSocketStats
+	// TCPResponses is the number of responses received by raw TCP
+	// client.
+	TCPResponses uint64
+	// Connections is nil unless one of HTTP clients were used with
+	// connections controlled.
+	Connections *ConnectionStats
+	// HTTP2 is nil unless HTTP/2 connections and streams were
+	// controlled.
+	HTTP2 *HTTP2Stats
+	// QUIC is nil unless HTTP/3 client were used.
+	QUIC *QUICStats
+	// Redirects is nil unless redirects were followed. Status codes
+	// above only count final responses.
+	Redirects *RedirectStats
+	// Compression is nil unless responses were decoded. Bytes read
+	// above are counted as sent over the wire.
+	Compression *CompressionStats
+	// Backends maps addresses connections were made to, it's nil
+	// unless they were resolved by bombardier.
+	Backends map[string]BackendStats
+	// Sources maps local addresses connections were made from, it's
+	// nil unless they were bound to local addresses.
+	Sources map[string]SourceStats
+
 	Errors []ErrorWithCount
 
 	Latencies ReadonlyUint64Histogram
 	Requests  ReadonlyFloat64Histogram
+
+	// Histograms below are nil unless streams of events were
+	// measured. Timings are in microseconds.
+	TimeToFirstByte  ReadonlyUint64Histogram
+	TimeToFirstEvent ReadonlyUint64Histogram
+	InterEventGaps   ReadonlyUint64Histogram
+	StreamDurations  ReadonlyUint64Histogram
+	// EventsPerStream holds number of events received per stream.
+	EventsPerStream ReadonlyUint64Histogram
+
+	// ProxyConnects holds time taken to establish tunnels through
+	// the proxy, in microseconds, it's nil unless proxy were used.
+	ProxyConnects ReadonlyUint64Histogram
+
+	// QUICHandshakes holds time taken to complete QUIC handshakes, in
+	// microseconds, it's nil unless HTTP/3 client were used.
+	QUICHandshakes ReadonlyUint64Histogram
+
+	// RedirectHops holds latencies of each hop of redirected
+	// requests, in microseconds, it's nil unless redirects were
+	// followed. Latencies above cover the whole chain.
+	RedirectHops ReadonlyUint64Histogram
+
+	// BodySizes holds sizes of generated request bodies, in bytes,
+	// it's nil unless random bodies were sent.
+	BodySizes ReadonlyUint64Histogram
+
+	// ResponseSizes holds sizes of response bodies, in bytes, it's nil
+	// unless HTTP clients were used.
+	ResponseSizes ReadonlyUint64Histogram
+
+	// Cancelled tells whether the test was stopped before its
+	// completion. TimeTaken is the effective duration of the
+	// test in this case.
+	Cancelled bool
 }
 
 // ReadonlyUint64Histogram is a readonly histogram with uint64 keys
@@ -102,8 +305,11 @@ type ReadonlyFloat64Histogram interface {
 }
 
 // Throughput returns total throughput (read + write) in bytes per
-// second
+// second, or 0 if no time was taken
 func (r Results) Throughput() float64 {
+	if r.TimeTaken <= 0 {
+		return 0
+	}
 	return float64(r.BytesRead+r.BytesWritten) / r.TimeTaken.Seconds()
 }
 
@@ -121,7 +327,12 @@ type LatenciesStats struct {
 // LatenciesStats performs various statistical calculations on
 // latencies.
 func (r Results) LatenciesStats(percentiles []float64) *LatenciesStats {
-	h := r.Latencies
+	return uint64HistogramStats(r.Latencies, percentiles)
+}
+
+func uint64HistogramStats(
+	h ReadonlyUint64Histogram, percentiles []float64,
+) *LatenciesStats {
 	sum := uint64(0)
 	count := uint64(0)
 	max := uint64(0)
@@ -185,6 +396,84 @@ func (r Results) LatenciesStats(percentiles []float64) *LatenciesStats {
 	}
 }
 
+// StreamStats contains statistical information about streams of
+// events. Each of the fields is nil if there wasn't enough data to
+// compute it.
+type StreamStats struct {
+	TimeToFirstByte  *LatenciesStats
+	TimeToFirstEvent *LatenciesStats
+	InterEventGaps   *LatenciesStats
+	Durations        *LatenciesStats
+	// This one is in events, rather than microseconds.
+	EventsPerStream *LatenciesStats
+}
+
+// StreamStats performs various statistical calculations on streams
+// of events. It returns nil unless streams of events were measured.
+func (r Results) StreamStats(percentiles []float64) *StreamStats {
+	if r.StreamDurations == nil {
+		return nil
+	}
+	return &StreamStats{
+		TimeToFirstByte:  uint64HistogramStats(r.TimeToFirstByte, percentiles),
+		TimeToFirstEvent: uint64HistogramStats(r.TimeToFirstEvent, percentiles),
+		InterEventGaps:   uint64HistogramStats(r.InterEventGaps, percentiles),
+		Durations:        uint64HistogramStats(r.StreamDurations, percentiles),
+		EventsPerStream:  uint64HistogramStats(r.EventsPerStream, percentiles),
+	}
+}
+
+// ProxyConnectStats performs various statistical calculations on time
+// taken to establish tunnels through the proxy. It returns nil unless
+// proxy were used or there wasn't enough data to compute them.
+func (r Results) ProxyConnectStats(percentiles []float64) *LatenciesStats {
+	if r.ProxyConnects == nil {
+		return nil
+	}
+	return uint64HistogramStats(r.ProxyConnects, percentiles)
+}
+
+// QUICHandshakeStats performs various statistical calculations on
+// time taken to complete QUIC handshakes. It returns nil unless HTTP/3
+// client were used or there wasn't enough data to compute them.
+func (r Results) QUICHandshakeStats(percentiles []float64) *LatenciesStats {
+	if r.QUICHandshakes == nil {
+		return nil
+	}
+	return uint64HistogramStats(r.QUICHandshakes, percentiles)
+}
+
+// RedirectHopStats performs various statistical calculations on
+// latencies of each hop of redirected requests. It returns nil unless
+// redirects were followed or there wasn't enough data to compute them.
+func (r Results) RedirectHopStats(percentiles []float64) *LatenciesStats {
+	if r.RedirectHops == nil {
+		return nil
+	}
+	return uint64HistogramStats(r.RedirectHops, percentiles)
+}
+
+// BodySizeStats performs various statistical calculations on sizes of
+// generated request bodies, that are in bytes rather than
+// microseconds. It returns nil unless random bodies were sent or there
+// wasn't enough data to compute them.
+func (r Results) BodySizeStats(percentiles []float64) *LatenciesStats {
+	if r.BodySizes == nil {
+		return nil
+	}
+	return uint64HistogramStats(r.BodySizes, percentiles)
+}
+
+// ResponseSizeStats performs various statistical calculations on sizes
+// of response bodies, that are in bytes. It returns nil unless HTTP
+// clients were used or there wasn't enough data to compute them.
+func (r Results) ResponseSizeStats(percentiles []float64) *LatenciesStats {
+	if r.ResponseSizes == nil {
+		return nil
+	}
+	return uint64HistogramStats(r.ResponseSizes, percentiles)
+}
+
 // RequestsStats contains statistical information about requests.
 type RequestsStats struct {
 	// These are in requests per second.
@@ -275,6 +564,45 @@ func (r Results) RequestsStats(percentiles []float64) *RequestsStats {
 	}
 }
 
+// Baseline holds results of a previous test, as they were written
+// by the json format.
+type Baseline struct {
+	BytesRead        int64   `json:"bytesRead"`
+	BytesWritten     int64   `json:"bytesWritten"`
+	TimeTakenSeconds float64 `json:"timeTakenSeconds"`
+
+	Req1XX uint64 `json:"req1xx"`
+	Req2XX uint64 `json:"req2xx"`
+	Req3XX uint64 `json:"req3xx"`
+	Req4XX uint64 `json:"req4xx"`
+	Req5XX uint64 `json:"req5xx"`
+	Others uint64 `json:"others"`
+
+	Latency *BaselineStats `json:"latency"`
+	RPS     *BaselineStats `json:"rps"`
+}
+
+// BaselineStats contains statistical information about either
+// latencies (in microseconds) or requests per second of the
+// baseline.
+type BaselineStats struct {
+	Mean   float64 `json:"mean"`
+	Stddev float64 `json:"stddev"`
+	Max    float64 `json:"max"`
+
+	// This is map[percentile(as in "50", "75", etc.)]value
+	Percentiles map[string]float64 `json:"percentiles"`
+}
+
+// Throughput returns total throughput (read + write) of the
+// baseline in bytes per second, or 0 if its duration is unknown
+func (b Baseline) Throughput() float64 {
+	if b.TimeTakenSeconds <= 0 {
+		return 0
+	}
+	return float64(b.BytesRead+b.BytesWritten) / b.TimeTakenSeconds
+}
+
 // ErrorWithCount contains error description alongside with number of
 // times this error occurred.
 type ErrorWithCount struct {
@@ -304,4 +632,15 @@ const (
 	NetHTTP1
 	// NetHTTP2 is Go's default HTTP client with HTTP/2.0 permitted.
 	NetHTTP2
+	// GRPC is gRPC client on top of HTTP/2.0.
+	GRPC
+	// WebSocket is WebSocket client.
+	WebSocket
+	// RawTCP is raw TCP client.
+	RawTCP
+	// Pipelined is fasthttp's client, that pipelines HTTP/1.1
+	// requests.
+	Pipelined
+	// HTTP3 is quic-go's HTTP/3 client.
+	HTTP3
 )
@@ -368,6 +368,623 @@ func TestArgsParsing(t *testing.T) {
 				Format:        knownFormat("plain-text"),
 			},
 		},
+		{
+			[][]string{
+				{
+					programName,
+					"--http3",
+					"https://somehost.somedomain",
+				},
+			},
+			Config{
+				NumConns:      defaultNumberOfConns,
+				Timeout:       defaultTimeout,
+				Headers:       new(HeadersList),
+				Method:        "GET",
+				Url:           "https://somehost.somedomain",
+				ClientType:    nhttp3,
+				PrintIntro:    true,
+				PrintProgress: true,
+				PrintResult:   true,
+				Format:        knownFormat("plain-text"),
+			},
+		},
+		{
+			[][]string{
+				{
+					programName,
+					"--grpc",
+					"https://somehost.somedomain/pkg.Service/Method",
+				},
+			},
+			Config{
+				NumConns:      defaultNumberOfConns,
+				Timeout:       defaultTimeout,
+				Headers:       new(HeadersList),
+				Method:        "GET",
+				Url:           "https://somehost.somedomain/pkg.Service/Method",
+				ClientType:    grpc,
+				PrintIntro:    true,
+				PrintProgress: true,
+				PrintResult:   true,
+				Format:        knownFormat("plain-text"),
+			},
+		},
+		{
+			[][]string{
+				{
+					programName,
+					"--grpc",
+					"--grpc-protoset", "service.protoset",
+					"https://somehost.somedomain/pkg.Service/Method",
+				},
+				{
+					programName,
+					"--grpc",
+					"--grpc-protoset=service.protoset",
+					"https://somehost.somedomain/pkg.Service/Method",
+				},
+			},
+			Config{
+				NumConns:      defaultNumberOfConns,
+				Timeout:       defaultTimeout,
+				Headers:       new(HeadersList),
+				Method:        "GET",
+				Url:           "https://somehost.somedomain/pkg.Service/Method",
+				ClientType:    grpc,
+				GRPCProtoset:  "service.protoset",
+				PrintIntro:    true,
+				PrintProgress: true,
+				PrintResult:   true,
+				Format:        knownFormat("plain-text"),
+			},
+		},
+		{
+			[][]string{
+				{
+					programName,
+					"--grpc",
+					"--grpc-max-msg-size", "16MiB",
+					"https://somehost.somedomain/pkg.Service/Method",
+				},
+				{
+					programName,
+					"--grpc",
+					"--grpc-max-msg-size=16MiB",
+					"https://somehost.somedomain/pkg.Service/Method",
+				},
+			},
+			Config{
+				NumConns:       defaultNumberOfConns,
+				Timeout:        defaultTimeout,
+				Headers:        new(HeadersList),
+				Method:         "GET",
+				Url:            "https://somehost.somedomain/pkg.Service/Method",
+				ClientType:     grpc,
+				GRPCMaxMsgSize: 16 << 20,
+				PrintIntro:     true,
+				PrintProgress:  true,
+				PrintResult:    true,
+				Format:         knownFormat("plain-text"),
+			},
+		},
+		{
+			[][]string{
+				{
+					programName,
+					"--http1",
+					"--stream-events",
+					"https://somehost.somedomain",
+				},
+			},
+			Config{
+				NumConns:      defaultNumberOfConns,
+				Timeout:       defaultTimeout,
+				Headers:       new(HeadersList),
+				Method:        "GET",
+				Url:           "https://somehost.somedomain",
+				ClientType:    nhttp1,
+				StreamEvents:  true,
+				PrintIntro:    true,
+				PrintProgress: true,
+				PrintResult:   true,
+				Format:        knownFormat("plain-text"),
+			},
+		},
+		{
+			[][]string{
+				{
+					programName,
+					"--websocket",
+					"ws://somehost.somedomain",
+				},
+			},
+			Config{
+				NumConns:      defaultNumberOfConns,
+				Timeout:       defaultTimeout,
+				Headers:       new(HeadersList),
+				Method:        "GET",
+				Url:           "ws://somehost.somedomain",
+				ClientType:    ws,
+				PrintIntro:    true,
+				PrintProgress: true,
+				PrintResult:   true,
+				Format:        knownFormat("plain-text"),
+			},
+		},
+		{
+			[][]string{
+				{
+					programName,
+					"--websocket-echo",
+					"--body=hello",
+					"ws://somehost.somedomain",
+				},
+			},
+			Config{
+				NumConns:      defaultNumberOfConns,
+				Timeout:       defaultTimeout,
+				Headers:       new(HeadersList),
+				Method:        "GET",
+				Body:          "hello",
+				Url:           "ws://somehost.somedomain",
+				ClientType:    ws,
+				WebSocketEcho: true,
+				PrintIntro:    true,
+				PrintProgress: true,
+				PrintResult:   true,
+				Format:        knownFormat("plain-text"),
+			},
+		},
+		{
+			[][]string{
+				{
+					programName,
+					"--tcp",
+					"--tcp-terminator=\\r\\n",
+					"tcp://somehost.somedomain:6379",
+				},
+			},
+			Config{
+				NumConns:      defaultNumberOfConns,
+				Timeout:       defaultTimeout,
+				Headers:       new(HeadersList),
+				Method:        "GET",
+				Url:           "tcp://somehost.somedomain:6379",
+				ClientType:    rawTCP,
+				TCP:           TCPOptions{Terminator: "\r\n"},
+				PrintIntro:    true,
+				PrintProgress: true,
+				PrintResult:   true,
+				Format:        knownFormat("plain-text"),
+			},
+		},
+		{
+			[][]string{
+				{
+					programName,
+					"--proxy=socks5://localhost:1080",
+					"https://somehost.somedomain",
+				},
+			},
+			Config{
+				NumConns:      defaultNumberOfConns,
+				Timeout:       defaultTimeout,
+				Headers:       new(HeadersList),
+				Method:        "GET",
+				Url:           "https://somehost.somedomain",
+				Proxy:         "socks5://localhost:1080",
+				PrintIntro:    true,
+				PrintProgress: true,
+				PrintResult:   true,
+				Format:        knownFormat("plain-text"),
+			},
+		},
+		{
+			[][]string{
+				{
+					programName,
+					"--disable-keep-alive",
+					"--conn-idle-timeout=5s",
+					"https://somehost.somedomain",
+				},
+			},
+			Config{
+				NumConns:      defaultNumberOfConns,
+				Timeout:       defaultTimeout,
+				Headers:       new(HeadersList),
+				Method:        "GET",
+				Url:           "https://somehost.somedomain",
+				Connections:   ConnOptions{DisableKeepAlive: true, IdleTimeout: 5 * time.Second},
+				PrintIntro:    true,
+				PrintProgress: true,
+				PrintResult:   true,
+				Format:        knownFormat("plain-text"),
+			},
+		},
+		{
+			[][]string{
+				{
+					programName,
+					"--max-conn-requests", "100",
+					"--max-conn-lifetime", "1m",
+					"https://somehost.somedomain",
+				},
+			},
+			Config{
+				NumConns:      defaultNumberOfConns,
+				Timeout:       defaultTimeout,
+				Headers:       new(HeadersList),
+				Method:        "GET",
+				Url:           "https://somehost.somedomain",
+				Connections:   ConnOptions{MaxRequests: 100, MaxLifetime: time.Minute},
+				PrintIntro:    true,
+				PrintProgress: true,
+				PrintResult:   true,
+				Format:        knownFormat("plain-text"),
+			},
+		},
+		{
+			[][]string{
+				{
+					programName,
+					"--pipeline",
+					"--pipeline-depth=16",
+					"https://somehost.somedomain",
+				},
+				{
+					programName,
+					"--pipeline-depth", "16",
+					"--pipeline",
+					"https://somehost.somedomain",
+				},
+			},
+			Config{
+				NumConns:      defaultNumberOfConns,
+				Timeout:       defaultTimeout,
+				Headers:       new(HeadersList),
+				Method:        "GET",
+				Url:           "https://somehost.somedomain",
+				ClientType:    pipelined,
+				PipelineDepth: 16,
+				PrintIntro:    true,
+				PrintProgress: true,
+				PrintResult:   true,
+				Format:        knownFormat("plain-text"),
+			},
+		},
+		{
+			[][]string{
+				{
+					programName,
+					"--form", "name=bombardier",
+					"--form-file", "upload=@testbody.txt",
+					"--form=empty=",
+					"https://somehost.somedomain",
+				},
+			},
+			Config{
+				NumConns: defaultNumberOfConns,
+				Timeout:  defaultTimeout,
+				Headers:  new(HeadersList),
+				Method:   "GET",
+				Url:      "https://somehost.somedomain",
+				Form: &FormFields{
+					{Name: "name", Value: "bombardier"},
+					{Name: "upload", Value: "testbody.txt", File: true},
+					{Name: "empty"},
+				},
+				PrintIntro:    true,
+				PrintProgress: true,
+				PrintResult:   true,
+				Format:        knownFormat("plain-text"),
+			},
+		},
+		{
+			[][]string{
+				{
+					programName,
+					"--body-random=64KiB-1MiB",
+					"--body-compressible",
+					"https://somehost.somedomain",
+				},
+				{
+					programName,
+					"--body-random", "64KiB-1MiB",
+					"--body-size-dist", "uniform",
+					"--body-compressible",
+					"https://somehost.somedomain",
+				},
+			},
+			Config{
+				NumConns: defaultNumberOfConns,
+				Timeout:  defaultTimeout,
+				Headers:  new(HeadersList),
+				Method:   "GET",
+				Url:      "https://somehost.somedomain",
+				RandomBody: RandomBodyOptions{
+					Size:         1 << 20,
+					MinSize:      64 << 10,
+					Distribution: uniformSize,
+					Compressible: true,
+				},
+				PrintIntro:    true,
+				PrintProgress: true,
+				PrintResult:   true,
+				Format:        knownFormat("plain-text"),
+			},
+		},
+		{
+			[][]string{
+				{
+					programName,
+					"--body-random=1MB",
+					"--body-size-dist=lognormal:0.5",
+					"https://somehost.somedomain",
+				},
+			},
+			Config{
+				NumConns: defaultNumberOfConns,
+				Timeout:  defaultTimeout,
+				Headers:  new(HeadersList),
+				Method:   "GET",
+				Url:      "https://somehost.somedomain",
+				RandomBody: RandomBodyOptions{
+					Size:         1000 * 1000,
+					Distribution: logNormalSize,
+					Sigma:        0.5,
+				},
+				PrintIntro:    true,
+				PrintProgress: true,
+				PrintResult:   true,
+				Format:        knownFormat("plain-text"),
+			},
+		},
+		{
+			[][]string{
+				{
+					programName,
+					"--expect-size=1KiB-2KiB",
+					"--expect-checksum=md5:d41d8cd98f00b204e9800998ecf8427e",
+					"https://somehost.somedomain",
+				},
+				{
+					programName,
+					"--expect-checksum", "md5:d41d8cd98f00b204e9800998ecf8427e",
+					"--expect-size", "1KiB-2KiB",
+					"https://somehost.somedomain",
+				},
+			},
+			Config{
+				NumConns: defaultNumberOfConns,
+				Timeout:  defaultTimeout,
+				Headers:  new(HeadersList),
+				Method:   "GET",
+				Url:      "https://somehost.somedomain",
+				Expect: ResponseChecks{
+					CheckSize: true,
+					MinSize:   1 << 10,
+					MaxSize:   2 << 10,
+					Checksum:  "md5:d41d8cd98f00b204e9800998ecf8427e",
+				},
+				PrintIntro:    true,
+				PrintProgress: true,
+				PrintResult:   true,
+				Format:        knownFormat("plain-text"),
+			},
+		},
+		{
+			[][]string{
+				{
+					programName,
+					"--expect-size=0",
+					"https://somehost.somedomain",
+				},
+			},
+			Config{
+				NumConns: defaultNumberOfConns,
+				Timeout:  defaultTimeout,
+				Headers:  new(HeadersList),
+				Method:   "GET",
+				Url:      "https://somehost.somedomain",
+				Expect: ResponseChecks{
+					CheckSize: true,
+				},
+				PrintIntro:    true,
+				PrintProgress: true,
+				PrintResult:   true,
+				Format:        knownFormat("plain-text"),
+			},
+		},
+		{
+			[][]string{
+				{
+					programName,
+					"--resolve=example.com:443:10.0.0.1,10.0.0.2",
+					"--resolve", "example.com:80:10.0.0.3",
+					"--dns-server=10.0.0.53",
+					"--spread=random",
+					"https://example.com",
+				},
+			},
+			Config{
+				NumConns: defaultNumberOfConns,
+				Timeout:  defaultTimeout,
+				Headers:  new(HeadersList),
+				Method:   "GET",
+				Url:      "https://example.com",
+				Resolve: &ResolveList{
+					{"example.com", "443", []string{"10.0.0.1", "10.0.0.2"}},
+					{"example.com", "80", []string{"10.0.0.3"}},
+				},
+				DNSServer:     "10.0.0.53",
+				ResolvePolicy: "random",
+				PrintIntro:    true,
+				PrintProgress: true,
+				PrintResult:   true,
+				Format:        knownFormat("plain-text"),
+			},
+		},
+		{
+			[][]string{
+				{
+					programName,
+					"--local-addr=10.0.0.2,10.0.1.0/24",
+					"--local-addr", "fd00::1",
+					"https://somehost.somedomain",
+				},
+			},
+			Config{
+				NumConns:      defaultNumberOfConns,
+				Timeout:       defaultTimeout,
+				Headers:       new(HeadersList),
+				Method:        "GET",
+				Url:           "https://somehost.somedomain",
+				LocalAddrs:    &LocalAddrList{"10.0.0.2", "10.0.1.0/24", "fd00::1"},
+				PrintIntro:    true,
+				PrintProgress: true,
+				PrintResult:   true,
+				Format:        knownFormat("plain-text"),
+			},
+		},
+		{
+			[][]string{
+				{
+					programName,
+					"--net-latency=100ms",
+					"--net-jitter=20ms",
+					"--net-bandwidth=64KiB",
+					"--net-drop=0.01",
+					"--net-slow-read=10ms",
+					"https://somehost.somedomain",
+				},
+				{
+					programName,
+					"--net-latency", "100ms",
+					"--net-jitter", "20ms",
+					"--net-bandwidth", "64KiB",
+					"--net-drop", "0.01",
+					"--net-slow-read", "10ms",
+					"https://somehost.somedomain",
+				},
+			},
+			Config{
+				NumConns: defaultNumberOfConns,
+				Timeout:  defaultTimeout,
+				Headers:  new(HeadersList),
+				Method:   "GET",
+				Url:      "https://somehost.somedomain",
+				Impairment: ImpairmentOptions{
+					Latency:         100 * time.Millisecond,
+					Jitter:          20 * time.Millisecond,
+					Bandwidth:       64 << 10,
+					DropProbability: 0.01,
+					SlowRead:        10 * time.Millisecond,
+				},
+				PrintIntro:    true,
+				PrintProgress: true,
+				PrintResult:   true,
+				Format:        knownFormat("plain-text"),
+			},
+		},
+		{
+			[][]string{
+				{
+					programName,
+					"--compress-body=gzip",
+					"--decompress",
+					"https://somehost.somedomain",
+				},
+				{
+					programName,
+					"--decompress",
+					"--compress-body", "gzip",
+					"https://somehost.somedomain",
+				},
+			},
+			Config{
+				NumConns:      defaultNumberOfConns,
+				Timeout:       defaultTimeout,
+				Headers:       new(HeadersList),
+				Method:        "GET",
+				Url:           "https://somehost.somedomain",
+				CompressBody:  "gzip",
+				Decompress:    true,
+				PrintIntro:    true,
+				PrintProgress: true,
+				PrintResult:   true,
+				Format:        knownFormat("plain-text"),
+			},
+		},
+		{
+			[][]string{
+				{
+					programName,
+					"--follow-redirects=3",
+					"https://somehost.somedomain",
+				},
+				{
+					programName,
+					"--follow-redirects", "3",
+					"https://somehost.somedomain",
+				},
+			},
+			Config{
+				NumConns:      defaultNumberOfConns,
+				Timeout:       defaultTimeout,
+				Headers:       new(HeadersList),
+				Method:        "GET",
+				Url:           "https://somehost.somedomain",
+				MaxRedirects:  3,
+				PrintIntro:    true,
+				PrintProgress: true,
+				PrintResult:   true,
+				Format:        knownFormat("plain-text"),
+			},
+		},
+		{
+			[][]string{
+				{
+					programName,
+					"--http2",
+					"--h2c",
+					"--h2-conns=4",
+					"--h2-max-streams=100",
+					"--h2-stream-window=65536",
+					"--h2-conn-window=1048576",
+					"http://somehost.somedomain",
+				},
+				{
+					programName,
+					"--h2-conn-window", "1048576",
+					"--h2-stream-window", "65536",
+					"--h2-max-streams", "100",
+					"--h2-conns", "4",
+					"--h2c",
+					"--http2",
+					"http://somehost.somedomain",
+				},
+			},
+			Config{
+				NumConns:   defaultNumberOfConns,
+				Timeout:    defaultTimeout,
+				Headers:    new(HeadersList),
+				Method:     "GET",
+				Url:        "http://somehost.somedomain",
+				ClientType: nhttp2,
+				HTTP2: HTTP2Options{
+					H2C:                  true,
+					Connections:          4,
+					MaxConcurrentStreams: 100,
+					StreamWindowSize:     64 << 10,
+					ConnWindowSize:       1 << 20,
+				},
+				PrintIntro:    true,
+				PrintProgress: true,
+				PrintResult:   true,
+				Format:        knownFormat("plain-text"),
+			},
+		},
 		{
 			[][]string{
 				{
@@ -679,6 +1296,164 @@ func TestArgsParsing(t *testing.T) {
 				Format:        userDefinedTemplate("/path/to/tmpl.txt"),
 			},
 		},
+		{
+			[][]string{
+				{
+					programName,
+					"--format", "markdown",
+					"https://somehost.somedomain",
+				},
+				{
+					programName,
+					"--format=md",
+					"https://somehost.somedomain",
+				},
+				{
+					programName,
+					"-o", "md",
+					"https://somehost.somedomain",
+				},
+			},
+			Config{
+				NumConns:      defaultNumberOfConns,
+				Timeout:       defaultTimeout,
+				Headers:       new(HeadersList),
+				Method:        "GET",
+				Url:           "https://somehost.somedomain",
+				PrintIntro:    true,
+				PrintProgress: true,
+				PrintResult:   true,
+				Format:        knownFormat("markdown"),
+			},
+		},
+		{
+			[][]string{
+				{
+					programName,
+					"--baseline", "/path/to/baseline.json",
+					"https://somehost.somedomain",
+				},
+				{
+					programName,
+					"--baseline=/path/to/baseline.json",
+					"https://somehost.somedomain",
+				},
+			},
+			Config{
+				NumConns:      defaultNumberOfConns,
+				Timeout:       defaultTimeout,
+				Headers:       new(HeadersList),
+				Method:        "GET",
+				Url:           "https://somehost.somedomain",
+				PrintIntro:    true,
+				PrintProgress: true,
+				PrintResult:   true,
+				Format:        knownFormat("plain-text"),
+				BaselinePath:  "/path/to/baseline.json",
+			},
+		},
+		{
+			[][]string{
+				{
+					programName,
+					"--dashboard",
+					"https://somehost.somedomain",
+				},
+			},
+			Config{
+				NumConns:      defaultNumberOfConns,
+				Timeout:       defaultTimeout,
+				Headers:       new(HeadersList),
+				Method:        "GET",
+				Url:           "https://somehost.somedomain",
+				PrintIntro:    true,
+				PrintProgress: true,
+				PrintResult:   true,
+				Dashboard:     true,
+				Format:        knownFormat("plain-text"),
+			},
+		},
+		{
+			[][]string{
+				{
+					programName,
+					"--snapshot-every", "1m",
+					"--snapshot-file", "/path/to/snapshot.json",
+					"https://somehost.somedomain",
+				},
+				{
+					programName,
+					"--snapshot-every=1m",
+					"--snapshot-file=/path/to/snapshot.json",
+					"https://somehost.somedomain",
+				},
+			},
+			Config{
+				NumConns:         defaultNumberOfConns,
+				Timeout:          defaultTimeout,
+				Headers:          new(HeadersList),
+				Method:           "GET",
+				Url:              "https://somehost.somedomain",
+				PrintIntro:       true,
+				PrintProgress:    true,
+				PrintResult:      true,
+				Format:           knownFormat("plain-text"),
+				SnapshotInterval: time.Minute,
+				SnapshotPath:     "/path/to/snapshot.json",
+			},
+		},
+		{
+			[][]string{
+				{
+					programName,
+					"--drain-timeout", "5s",
+					"https://somehost.somedomain",
+				},
+				{
+					programName,
+					"--drain-timeout=5s",
+					"https://somehost.somedomain",
+				},
+			},
+			Config{
+				NumConns:      defaultNumberOfConns,
+				Timeout:       defaultTimeout,
+				Headers:       new(HeadersList),
+				Method:        "GET",
+				Url:           "https://somehost.somedomain",
+				PrintIntro:    true,
+				PrintProgress: true,
+				PrintResult:   true,
+				Format:        knownFormat("plain-text"),
+				DrainTimeout:  5 * time.Second,
+			},
+		},
+		{
+			[][]string{
+				{
+					programName,
+					"--client", "custom",
+					"https://somehost.somedomain",
+				},
+				{
+					programName,
+					"--client=custom",
+					"https://somehost.somedomain",
+				},
+			},
+			Config{
+				NumConns:      defaultNumberOfConns,
+				Timeout:       defaultTimeout,
+				Headers:       new(HeadersList),
+				Method:        "GET",
+				Url:           "https://somehost.somedomain",
+				PrintIntro:    true,
+				PrintProgress: true,
+				PrintResult:   true,
+				Format:        knownFormat("plain-text"),
+				CustomClient:  "custom",
+			},
+		},
 	}
 	for _, e := range expectations {
 		for _, args := range e.in {
@@ -0,0 +1,30 @@
+package lib
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/tony24681379/bombardier/internal"
+)
+
+// readBaseline - helper function to read results of a previous test
+// from the file produced by json format
+func readBaseline(path string) (*internal.Baseline, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	var doc struct {
+		Result *internal.Baseline `json:"result"`
+	}
+	if err := json.NewDecoder(f).Decode(&doc); err != nil {
+		return nil, err
+	}
+	if doc.Result == nil {
+		return nil, errNoResultInBaseline
+	}
+	return doc.Result, nil
+}
@@ -1,6 +1,7 @@
 package lib
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -20,6 +21,7 @@ import (
 
 type Bombardier struct {
 	bytesRead, bytesWritten int64
+	openConns               int64
 
 	// HTTP codes
 	req1xx uint64
@@ -29,18 +31,60 @@ type Bombardier struct {
 	req5xx uint64
 	others uint64
 
+	// gRPC codes
+	grpcCodes [len(grpcCodeNames)]uint64
+	// WebSocket messages echoed by the server
+	wsMessages uint64
+	// responses received by raw TCP client
+	tcpResponses uint64
+	// streams is nil unless streams of events are measured
+	streams *streamStats
+	// proxyConnects is nil unless connections are tunneled through
+	// a proxy
+	proxyConnects *uhist.Histogram
+	// conns is nil unless one of HTTP clients is used
+	conns *connStats
+	// http2 is nil unless HTTP/2 options are set
+	http2 *http2Stats
+	// quic is nil unless HTTP/3 client is used
+	quic *quicStats
+	// redirects is nil unless redirects are followed
+	redirects *redirectStats
+	// compression is nil unless responses are decoded
+	compression *compressionStats
+	// bodySizes is nil unless random bodies are sent
+	bodySizes *uhist.Histogram
+	// responseSizes is nil unless HTTP clients are used
+	responseSizes *uhist.Histogram
+	// backends is nil unless addresses are resolved by bombardier
+	backends *backendStats
+	// sources is nil unless connections are bound to local addresses
+	sources *sourceStats
+
 	Conf        Config
 	Barrier     completionBarrier
 	ratelimiter limiter
 	workers     sync.WaitGroup
 
+	// Timings
+	tl        sync.Mutex
+	began     time.Time
 	timeTaken time.Duration
+
 	latencies *uhist.Histogram
 	requests  *fhist.Histogram
 
 	client   client
 	doneChan chan struct{}
 
+	// Cancellation
+	ctx        context.Context
+	abort      context.CancelFunc
+	cancelled  uint32
+	dl         sync.Mutex
+	drainTimer *time.Timer
+	finished   bool
+
 	// RPS metrics
 	rpl   sync.Mutex
 	reqs  int64
@@ -55,14 +99,21 @@ type Bombardier struct {
 	// Output
 	out      io.Writer
 	template *template.Template
+	baseline *internal.Baseline
+	options  *options
+
+	// Snapshots
+	sl        sync.Mutex
+	snapshots uint64
 }
 
-func NewBombardier(c Config) (*Bombardier, error) {
+func NewBombardier(c Config, opts ...Option) (*Bombardier, error) {
 	if err := c.checkArgs(); err != nil {
 		return nil, err
 	}
 	b := new(Bombardier)
 	b.Conf = c
+	b.options = newOptions(opts...)
 	b.latencies = uhist.Default()
 	b.requests = fhist.Default()
 
@@ -122,24 +173,142 @@ func NewBombardier(c Config) (*Bombardier, error) {
 			pbody = &sbody
 		}
 	}
+	if c.RandomBody.isSet() {
+		var rb *randomBody
+		rb, err = newRandomBody(c.RandomBody)
+		if err != nil {
+			return nil, err
+		}
+		pbody, bsp = nil, rb.produce
+		b.bodySizes = rb.sizes
+	}
+	headers := c.Headers
+	if c.Form != nil && len(*c.Form) > 0 {
+		var form *formBody
+		form, err = newFormBody(*c.Form)
+		if err != nil {
+			return nil, err
+		}
+		if c.Stream {
+			bsp = form.stream
+		} else {
+			var formBytes []byte
+			formBytes, err = form.bytes()
+			if err != nil {
+				return nil, err
+			}
+			sbody := string(formBytes)
+			pbody = &sbody
+		}
+		headers = withHeader(headers, "Content-Type", form.contentType)
+	}
+	if c.CompressBody != "" {
+		var compressed []byte
+		compressed, err = compressBody([]byte(*pbody), c.CompressBody)
+		if err != nil {
+			return nil, err
+		}
+		sbody := string(compressed)
+		pbody = &sbody
+		headers = withHeader(headers, "Content-Encoding", c.CompressBody)
+	}
+
+	b.ctx, b.abort = context.WithCancel(context.Background())
 
 	cc := &clientOpts{
 		HTTP2:     false,
+		ctx:       b.ctx,
 		maxConns:  c.NumConns,
 		timeout:   c.Timeout,
 		tlsConfig: tlsConfig,
 
-		headers:      c.Headers,
+		headers:      headers,
 		url:          c.Url,
 		method:       c.Method,
 		body:         pbody,
 		bodProd:      bsp,
 		bytesRead:    &b.bytesRead,
 		bytesWritten: &b.bytesWritten,
+		openConns:    &b.openConns,
+
+		middleware: b.options.middleware,
+
+		wsEcho:        c.WebSocketEcho,
+		tcp:           c.TCP,
+		pipelineDepth: c.PipelineDepth,
+
+		conns:      c.Connections,
+		impairment: c.Impairment,
+	}
+	if c.CustomClient == "" && strings.HasPrefix(c.Url, unixSocketScheme) {
+		// Validated by Config.checkArgs
+		cc.unixSocket, cc.url, _ = splitUnixSocketURL(c.Url)
+	}
+	if c.Proxy != "" {
+		// Validated by Config.checkArgs
+		cc.proxy, _ = c.proxyURL()
+		b.proxyConnects = uhist.Default()
+		cc.proxyConnects = b.proxyConnects
+	}
+	if c.LocalAddrs != nil && len(*c.LocalAddrs) > 0 {
+		// Validated by Config.checkArgs
+		ips, _ := expandLocalAddrs(*c.LocalAddrs)
+		b.sources = newSourceStats()
+		cc.localAddrs = newLocalAddrs(ips, b.sources)
+	}
+	if c.resolvesAddrs() {
+		b.backends = newBackendStats()
+		cc.resolver = newResolver(
+			c.Resolve, c.DNSServer, c.ResolvePolicy, b.backends,
+		)
+	}
+	if c.isHTTP() && c.Connections.isSet() {
+		b.conns = new(connStats)
+		cc.connStats = b.conns
+	}
+	if c.isHTTP() || c.isPipelined() {
+		b.responseSizes = uhist.Default()
+		cc.responses = newResponseVerifier(b.responseSizes, c.Expect)
+	}
+	if c.HTTP2.isSet() {
+		b.http2 = new(http2Stats)
+		cc.http2 = c.HTTP2
+		cc.http2Stats = b.http2
+	}
+	if c.isHTTP3() {
+		b.quic = newQUICStats()
+		cc.quicStats = b.quic
+	}
+	if c.MaxRedirects > 0 {
+		b.redirects = newRedirectStats()
+		cc.maxRedirects = c.MaxRedirects
+		cc.redirects = b.redirects
+	}
+	if c.Decompress {
+		b.compression = new(compressionStats)
+		cc.compression = b.compression
+	}
+	if c.StreamEvents {
+		b.streams = newStreamStats()
+		cc.streams = b.streams
+	}
+	if c.CustomClient != "" {
+		b.client, err = newCustomClient(c.CustomClient, cc)
+		if err != nil {
+			return nil, err
+		}
+	} else if c.ClientType == grpc {
+		cc.grpcProtoset = c.GRPCProtoset
+		cc.grpcMaxMsgSize = c.GRPCMaxMsgSize
+		b.client, err = newGRPCClient(cc)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		b.client = makeHTTPClient(c.ClientType, cc)
 	}
-	b.client = makeHTTPClient(c.ClientType, cc)
 
-	if !b.Conf.PrintProgress {
+	if !b.Conf.PrintProgress || b.Conf.Dashboard {
 		b.bar.Output = ioutil.Discard
 		b.bar.NotPrint = true
 	}
@@ -149,7 +318,18 @@ func NewBombardier(c Config) (*Bombardier, error) {
 		return nil, err
 	}
 
-	b.workers.Add(int(c.NumConns))
+	if c.BaselinePath != "" {
+		b.baseline, err = readBaseline(c.BaselinePath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if b.options.out != nil {
+		b.redirectOutputTo(b.options.out)
+	}
+
+	b.workers.Add(int(c.numWorkers()))
 	b.errors = newErrorMap()
 	b.doneChan = make(chan struct{}, 2)
 	return b, nil
@@ -163,6 +343,15 @@ func makeHTTPClient(clientType clientTyp, cc *clientOpts) client {
 	case nhttp2:
 		cc.HTTP2 = true
 		cl = newHTTPClient(cc)
+	case nhttp3:
+		cc.HTTP3 = true
+		cl = newHTTPClient(cc)
+	case ws:
+		cl = newWSClient(cc)
+	case rawTCP:
+		cl = newTCPClient(cc)
+	case pipelined:
+		cl = newFastHTTPPipelineClient(cc)
 	case fhttp:
 		fallthrough
 	default:
@@ -206,11 +395,16 @@ func (b *Bombardier) prepareTemplate() (*template.Template, error) {
 			"StringToBytes": func(s string) []byte {
 				return []byte(s)
 			},
-			"UUIDV1": uuid.NewV1,
-			"UUIDV2": uuid.NewV2,
-			"UUIDV3": uuid.NewV3,
-			"UUIDV4": uuid.NewV4,
-			"UUIDV5": uuid.NewV5,
+			"Uint64ToFloat64": func(u uint64) float64 {
+				return float64(u)
+			},
+			"EscapeMarkdown": escapeMarkdown,
+			"PercentChange":  percentChange,
+			"UUIDV1":         uuid.NewV1,
+			"UUIDV2":         uuid.NewV2,
+			"UUIDV3":         uuid.NewV3,
+			"UUIDV4":         uuid.NewV4,
+			"UUIDV5":         uuid.NewV5,
 		}).Parse(string(templateBytes))
 
 	if err != nil {
@@ -227,6 +421,22 @@ func (b *Bombardier) writeStatistics(
 	b.reqs++
 	b.rpl.Unlock()
 	var counter *uint64
+	if b.isGRPC() {
+		counter = &b.others
+		if code >= 0 && code < len(b.grpcCodes) {
+			counter = &b.grpcCodes[code]
+		}
+		atomic.AddUint64(counter, 1)
+		return
+	}
+	if code == wsMessageCode && b.Conf.isWebSocket() {
+		atomic.AddUint64(&b.wsMessages, 1)
+		return
+	}
+	if code == tcpResponseCode && b.Conf.isRawTCP() {
+		atomic.AddUint64(&b.tcpResponses, 1)
+		return
+	}
 	switch code / 100 {
 	case 1:
 		counter = &b.req1xx
@@ -244,8 +454,16 @@ func (b *Bombardier) writeStatistics(
 	atomic.AddUint64(counter, 1)
 }
 
+func (b *Bombardier) isGRPC() bool {
+	return b.Conf.ClientType == grpc && b.Conf.CustomClient == ""
+}
+
 func (b *Bombardier) performSingleRequest() {
 	code, msTaken, err := b.client.do()
+	if err != nil && b.ctx.Err() != nil {
+		// Request was aborted, so there is nothing to record
+		return
+	}
 	if err != nil {
 		b.errors.add(err)
 	}
@@ -265,7 +483,12 @@ func (b *Bombardier) worker() {
 
 func (b *Bombardier) barUpdater() {
 	done := b.Barrier.done()
+	ticker := time.NewTicker(b.bar.RefreshRate)
+	defer ticker.Stop()
 	for {
+		current := int64(b.Barrier.completed() * float64(b.bar.Total))
+		b.bar.Set64(current)
+		b.bar.Update()
 		select {
 		case <-done:
 			b.bar.Set64(b.bar.Total)
@@ -276,11 +499,7 @@ func (b *Bombardier) barUpdater() {
 			}
 			b.doneChan <- struct{}{}
 			return
-		default:
-			current := int64(b.Barrier.completed() * float64(b.bar.Total))
-			b.bar.Set64(current)
-			b.bar.Update()
-			time.Sleep(b.bar.RefreshRate)
+		case <-ticker.C:
 		}
 	}
 }
@@ -326,33 +545,90 @@ func (b *Bombardier) Bombard() {
 		b.printIntro()
 	}
 	b.bar.Start()
-	bombardmentBegin := time.Now()
+	b.tl.Lock()
+	b.began = time.Now()
+	b.tl.Unlock()
 	b.start = time.Now()
-	for i := uint64(0); i < b.Conf.NumConns; i++ {
+	for i := uint64(0); i < b.Conf.numWorkers(); i++ {
 		go func() {
 			defer b.workers.Done()
 			b.worker()
 		}()
 	}
 	go b.rateMeter()
-	go b.barUpdater()
+	if b.Conf.SnapshotInterval > 0 {
+		go b.snapshotter()
+	}
+	if b.Conf.Dashboard && b.Conf.PrintProgress {
+		go b.dashboardUpdater()
+	} else {
+		go b.barUpdater()
+	}
 	b.workers.Wait()
-	b.timeTaken = time.Since(bombardmentBegin)
+	b.tl.Lock()
+	b.timeTaken = time.Since(b.began)
+	b.tl.Unlock()
 	<-b.doneChan
 	<-b.doneChan
+	// Release connections and everything that is waiting on them
+	b.abort()
+	// Test is over, so there is nothing left to abort
+	b.dl.Lock()
+	b.finished = true
+	if b.drainTimer != nil {
+		b.drainTimer.Stop()
+	}
+	b.dl.Unlock()
+}
+
+// Cancel gracefully stops the test. No new requests are sent, while
+// requests in flight are allowed to finish, but for no longer than
+// Conf.DrainTimeout, if it's set.
+func (b *Bombardier) Cancel() {
+	atomic.StoreUint32(&b.cancelled, 1)
+	b.Barrier.Cancel()
+	if b.Conf.DrainTimeout > 0 {
+		b.dl.Lock()
+		if b.drainTimer == nil && !b.finished {
+			b.drainTimer = time.AfterFunc(b.Conf.DrainTimeout, b.Abort)
+		}
+		b.dl.Unlock()
+	}
+}
+
+// Abort immediately stops the test, aborting requests in flight.
+func (b *Bombardier) Abort() {
+	atomic.StoreUint32(&b.cancelled, 1)
+	b.Barrier.Cancel()
+	b.abort()
 }
 
 func (b *Bombardier) printIntro() {
+	b.printIntroTo(b.out)
+}
+
+func (b *Bombardier) printIntroTo(out io.Writer) {
 	if b.Conf.testType() == counted {
-		fmt.Fprintf(b.out,
+		fmt.Fprintf(out,
 			"Bombarding %v with %v request(s) using %v connection(s)\n",
 			b.Conf.Url, *b.Conf.NumReqs, b.Conf.NumConns)
 	} else if b.Conf.testType() == timed {
-		fmt.Fprintf(b.out, "Bombarding %v for %v using %v connection(s)\n",
+		fmt.Fprintf(out, "Bombarding %v for %v using %v connection(s)\n",
 			b.Conf.Url, *b.Conf.Duration, b.Conf.NumConns)
 	}
 }
 
+// elapsed returns the time taken by the test, if it's finished, or
+// the time elapsed since its beginning otherwise.
+func (b *Bombardier) elapsed() time.Duration {
+	b.tl.Lock()
+	defer b.tl.Unlock()
+	if b.timeTaken > 0 || b.began.IsZero() {
+		return b.timeTaken
+	}
+	return time.Since(b.began)
+}
+
 func (b *Bombardier) gatherInfo() internal.TestInfo {
 	info := internal.TestInfo{
 		Spec: internal.Spec{
@@ -371,23 +647,78 @@ func (b *Bombardier) gatherInfo() internal.TestInfo {
 			Timeout:    b.Conf.Timeout,
 			ClientType: internal.ClientType(b.Conf.ClientType),
 
+			CustomClient: b.Conf.CustomClient,
+
 			Rate: b.Conf.Rate,
 		},
 		Result: internal.Results{
-			BytesRead:    b.bytesRead,
-			BytesWritten: b.bytesWritten,
-			TimeTaken:    b.timeTaken,
+			BytesRead:    atomic.LoadInt64(&b.bytesRead),
+			BytesWritten: atomic.LoadInt64(&b.bytesWritten),
+			TimeTaken:    b.elapsed(),
 
-			Req1XX: b.req1xx,
-			Req2XX: b.req2xx,
-			Req3XX: b.req3xx,
-			Req4XX: b.req4xx,
-			Req5XX: b.req5xx,
-			Others: b.others,
+			Req1XX: atomic.LoadUint64(&b.req1xx),
+			Req2XX: atomic.LoadUint64(&b.req2xx),
+			Req3XX: atomic.LoadUint64(&b.req3xx),
+			Req4XX: atomic.LoadUint64(&b.req4xx),
+			Req5XX: atomic.LoadUint64(&b.req5xx),
+			Others: atomic.LoadUint64(&b.others),
 
 			Latencies: b.latencies,
 			Requests:  b.requests,
+
+			GRPCCodes: b.gatherGRPCCodes(),
+			WebSocket: b.gatherWebSocketStats(),
+
+			TCPResponses: atomic.LoadUint64(&b.tcpResponses),
+
+			Cancelled: atomic.LoadUint32(&b.cancelled) == 1,
 		},
+		Baseline: b.baseline,
+	}
+	if b.Conf.Connections.isSet() {
+		info.Result.Connections = b.gatherConnectionStats()
+	}
+	if b.http2 != nil {
+		info.Result.HTTP2 = b.http2.snapshot()
+	}
+	if b.quic != nil {
+		info.Result.QUIC = b.quic.snapshot()
+		info.Result.QUICHandshakes = b.quic.handshakeTimes
+	}
+	if b.redirects != nil {
+		info.Result.Redirects = b.redirects.snapshot()
+		info.Result.RedirectHops = b.redirects.hops
+	}
+	if b.bodySizes != nil {
+		info.Result.BodySizes = b.bodySizes
+	}
+	if b.backends != nil {
+		info.Result.Backends = b.backends.snapshot()
+	}
+	if b.sources != nil {
+		info.Result.Sources = b.sources.snapshot()
+	}
+	if b.responseSizes != nil {
+		info.Result.ResponseSizes = b.responseSizes
+	}
+	if b.compression != nil {
+		info.Result.Compression = b.compression.snapshot()
+	}
+	if b.Conf.isPipelined() {
+		info.Spec.PipelineDepth = b.Conf.PipelineDepth
+	}
+	if b.proxyConnects != nil {
+		info.Result.ProxyConnects = b.proxyConnects
+		if u, err := b.Conf.proxyURL(); err == nil {
+			info.Spec.Proxy = u.Redacted()
+		}
+	}
+	if b.streams != nil {
+		info.Result.TimeToFirstByte = b.streams.firstByte
+		info.Result.TimeToFirstEvent = b.streams.firstEvent
+		info.Result.InterEventGaps = b.streams.gaps
+		info.Result.StreamDurations = b.streams.durations
+		info.Result.EventsPerStream = b.streams.events
 	}
 
 	testType := b.Conf.testType()
@@ -419,6 +750,40 @@ func (b *Bombardier) gatherInfo() internal.TestInfo {
 	return info
 }
 
+func (b *Bombardier) gatherGRPCCodes() map[string]uint64 {
+	if !b.isGRPC() {
+		return nil
+	}
+	codes := make(map[string]uint64)
+	for i := range b.grpcCodes {
+		if count := atomic.LoadUint64(&b.grpcCodes[i]); count > 0 {
+			codes[grpcCodeNames[i]] = count
+		}
+	}
+	return codes
+}
+
+func (b *Bombardier) gatherWebSocketStats() *internal.WebSocketStats {
+	wc, ok := b.client.(*wsClient)
+	if !ok {
+		return nil
+	}
+	stats := wc.stats()
+	stats.Messages = atomic.LoadUint64(&b.wsMessages)
+	return stats
+}
+
+func (b *Bombardier) gatherConnectionStats() *internal.ConnectionStats {
+	if b.conns == nil {
+		return nil
+	}
+	return &internal.ConnectionStats{
+		Opened:       atomic.LoadUint64(&b.conns.opened),
+		Reused:       atomic.LoadUint64(&b.conns.reused),
+		ClosedByPeer: atomic.LoadUint64(&b.conns.closedByPeer),
+	}
+}
+
 func (b *Bombardier) PrintStats() {
 	info := b.gatherInfo()
 	err := b.template.Execute(b.out, info)
@@ -11,7 +11,9 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -335,6 +337,121 @@ func TestBombardierStatsPrinting(t *testing.T) {
 	}
 }
 
+func TestBombardierMarkdownWithBaseline(t *testing.T) {
+	s := httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			_, err := rw.Write([]byte("OK"))
+			if err != nil {
+				t.Error(err)
+			}
+		}),
+	)
+	defer s.Close()
+	out := markdownWithBaseline(t, s.URL, `{"spec":{},"result":{`+
+		`"bytesRead":1024,"bytesWritten":1024,"timeTakenSeconds":1,`+
+		`"req2xx":10,"latency":{"mean":100,"stddev":1,"max":200},`+
+		`"rps":{"mean":10,"stddev":1,"max":20}}}`)
+	for _, expected := range []string{
+		"| Statistics | Avg | Stdev | Max |",
+		"| 2xx | 10 |",
+		"| error \\| with pipe | 1 |",
+		"| Compared to baseline | Baseline | Current | Change |",
+		"| Latency (avg) | 100.00us |",
+		"| Throughput | 2.00KB/s |",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("Expected %q in output:\n%v", expected, out)
+		}
+	}
+}
+
+func TestBombardierMarkdownWithBaselineOfUnknownDuration(t *testing.T) {
+	s := httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}),
+	)
+	defer s.Close()
+	out := markdownWithBaseline(t, s.URL, `{"spec":{},"result":{`+
+		`"bytesRead":1024,"bytesWritten":1024,"timeTakenSeconds":0}}`)
+	if !strings.Contains(out, "| Throughput | n/a |") {
+		t.Errorf("Expected unknown throughput of baseline in output:\n%v", out)
+	}
+	if strings.Contains(out, "Inf") || strings.Contains(out, "NaN") {
+		t.Errorf("Unexpected Inf or NaN in output:\n%v", out)
+	}
+}
+
+// markdownWithBaseline runs the test against url with the given
+// baseline and returns results in markdown format.
+func markdownWithBaseline(t *testing.T, url, result string) string {
+	baseline, err := ioutil.TempFile("", "baseline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.Remove(baseline.Name())
+	}()
+	if _, err = baseline.WriteString(result); err != nil {
+		t.Fatal(err)
+	}
+	if err = baseline.Close(); err != nil {
+		t.Fatal(err)
+	}
+	numReqs := uint64(10)
+	b, e := NewBombardier(Config{
+		NumConns:     defaultNumberOfConns,
+		NumReqs:      &numReqs,
+		Url:          url,
+		Headers:      new(HeadersList),
+		Timeout:      defaultTimeout,
+		Method:       "GET",
+		Body:         "",
+		PrintResult:  true,
+		Format:       knownFormat("markdown"),
+		BaselinePath: baseline.Name(),
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	b.errors.add(errors.New("error | with pipe"))
+
+	out := new(bytes.Buffer)
+	b.redirectOutputTo(out)
+	b.Bombard()
+
+	b.PrintStats()
+	return out.String()
+}
+
+func TestBombardierErrorIfBaselineHasNoResult(t *testing.T) {
+	baseline, err := ioutil.TempFile("", "baseline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.Remove(baseline.Name())
+	}()
+	if _, err = baseline.WriteString(`{"spec":{}}`); err != nil {
+		t.Fatal(err)
+	}
+	if err = baseline.Close(); err != nil {
+		t.Fatal(err)
+	}
+	numReqs := uint64(10)
+	_, e := NewBombardier(Config{
+		NumConns:     defaultNumberOfConns,
+		NumReqs:      &numReqs,
+		Url:          "http://localhost",
+		Headers:      new(HeadersList),
+		Timeout:      defaultTimeout,
+		Method:       "GET",
+		Format:       knownFormat("markdown"),
+		BaselinePath: baseline.Name(),
+	})
+	if e != errNoResultInBaseline {
+		t.Errorf("Expected %v, but got %v", errNoResultInBaseline, e)
+	}
+}
+
 func TestBombardierErrorIfFailToReadClientCert(t *testing.T) {
 	numReqs := uint64(10)
 	_, e := NewBombardier(Config{
@@ -703,3 +820,203 @@ func testBombardierStreamsBodyFromFile(clientType clientTyp, t *testing.T) {
 	b.disableOutput()
 	b.Bombard()
 }
+
+func TestBombardierAbort(t *testing.T) {
+	testAllClients(t, testBombardierAbort)
+}
+
+func testBombardierAbort(clientType clientTyp, t *testing.T) {
+	testBombardierCancellation(clientType, t, 0, func(b *Bombardier) {
+		b.Abort()
+	})
+}
+
+func TestBombardierCancelWithDrainTimeout(t *testing.T) {
+	testAllClients(t, testBombardierCancelWithDrainTimeout)
+}
+
+func testBombardierCancelWithDrainTimeout(clientType clientTyp, t *testing.T) {
+	testBombardierCancellation(
+		clientType, t, 100*time.Millisecond, func(b *Bombardier) {
+			b.Cancel()
+		},
+	)
+}
+
+func TestBombardierStopsDrainTimerOnceFinished(t *testing.T) {
+	s := httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}),
+	)
+	defer s.Close()
+	testDuration := 10 * time.Second
+	b, e := NewBombardier(Config{
+		NumConns:     10,
+		Duration:     &testDuration,
+		Url:          s.URL,
+		Headers:      new(HeadersList),
+		Timeout:      testDuration,
+		Method:       "GET",
+		Format:       knownFormat("plain-text"),
+		DrainTimeout: time.Hour,
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	b.disableOutput()
+	time.AfterFunc(100*time.Millisecond, b.Cancel)
+	b.Bombard()
+	b.dl.Lock()
+	defer b.dl.Unlock()
+	if b.drainTimer == nil {
+		t.Fatal("Drain timer should be started by Cancel")
+	}
+	if b.drainTimer.Stop() {
+		t.Error("Drain timer should be stopped once the test is finished")
+	}
+}
+
+func testBombardierCancellation(
+	clientType clientTyp, t *testing.T,
+	drainTimeout time.Duration, cancel func(*Bombardier),
+) {
+	unblock := make(chan struct{})
+	s := httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			select {
+			case <-unblock:
+			case <-r.Context().Done():
+			}
+		}),
+	)
+	defer s.Close()
+	defer close(unblock)
+	testDuration := 10 * time.Second
+	b, e := NewBombardier(Config{
+		NumConns:     10,
+		Duration:     &testDuration,
+		Url:          s.URL,
+		Headers:      new(HeadersList),
+		Timeout:      testDuration,
+		Method:       "GET",
+		ClientType:   clientType,
+		Format:       knownFormat("plain-text"),
+		DrainTimeout: drainTimeout,
+	})
+	if e != nil {
+		t.Error(e)
+		return
+	}
+	b.disableOutput()
+	time.AfterFunc(200*time.Millisecond, func() {
+		cancel(b)
+	})
+	start := time.Now()
+	b.Bombard()
+	if taken := time.Since(start); taken > 2*time.Second {
+		t.Errorf("Test took too long to stop: %v", taken)
+	}
+	info := b.gatherInfo()
+	if !info.Result.Cancelled {
+		t.Error("Test should be marked as cancelled")
+	}
+	if info.Result.TimeTaken > 2*time.Second {
+		t.Errorf("Unexpected effective duration: %v", info.Result.TimeTaken)
+	}
+	if len(info.Result.Errors) != 0 {
+		t.Errorf("Aborted requests shouldn't be recorded: %v",
+			info.Result.Errors)
+	}
+}
+
+func TestBombardierCancelWaitsForRequestsInFlight(t *testing.T) {
+	testAllClients(t, testBombardierCancelWaitsForRequestsInFlight)
+}
+
+func testBombardierCancelWaitsForRequestsInFlight(
+	clientType clientTyp, t *testing.T,
+) {
+	s := httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			time.Sleep(500 * time.Millisecond)
+		}),
+	)
+	defer s.Close()
+	numConns := uint64(10)
+	testDuration := 10 * time.Second
+	b, e := NewBombardier(Config{
+		NumConns:   numConns,
+		Duration:   &testDuration,
+		Url:        s.URL,
+		Headers:    new(HeadersList),
+		Timeout:    testDuration,
+		Method:     "GET",
+		ClientType: clientType,
+		Format:     knownFormat("plain-text"),
+	})
+	if e != nil {
+		t.Error(e)
+		return
+	}
+	b.disableOutput()
+	time.AfterFunc(200*time.Millisecond, b.Cancel)
+	b.Bombard()
+	if b.req2xx != numConns {
+		t.Errorf("Expected %v requests to finish, but got %v",
+			numConns, b.req2xx)
+	}
+	if !b.gatherInfo().Result.Cancelled {
+		t.Error("Test should be marked as cancelled")
+	}
+}
+
+func TestBombardierUnixSocket(t *testing.T) {
+	testAllClients(t, testBombardierUnixSocket)
+}
+
+func testBombardierUnixSocket(clientType clientTyp, t *testing.T) {
+	dir, err := ioutil.TempDir("", "bombardier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	socket := filepath.Join(dir, "app.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var requests uint64
+	s := httptest.NewUnstartedServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			if r.URL.RequestURI() != "/api/v1?q=1" {
+				t.Errorf("Expected %v, but got %v",
+					"/api/v1?q=1", r.URL.RequestURI())
+			}
+			atomic.AddUint64(&requests, 1)
+		}),
+	)
+	s.Listener = l
+	s.Start()
+	defer s.Close()
+	numReqs := uint64(10)
+	b, e := NewBombardier(Config{
+		NumConns:   defaultNumberOfConns,
+		NumReqs:    &numReqs,
+		Url:        "unix://" + socket + ":/api/v1?q=1",
+		Headers:    new(HeadersList),
+		Timeout:    defaultTimeout,
+		Method:     "GET",
+		ClientType: clientType,
+		Format:     knownFormat("plain-text"),
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	b.disableOutput()
+	b.Bombard()
+	if b.req2xx != numReqs || requests != numReqs {
+		t.Errorf("Expected %v requests, but got %v (server: %v), errors: %v",
+			numReqs, b.req2xx, requests, b.errors.byFrequency())
+	}
+}
@@ -0,0 +1,129 @@
+package lib
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Client performs requests of the test. It's what makes bombardier
+// protocol-agnostic: completion barriers, rate limiting, statistics
+// and output formats work the same way for any Client.
+//
+// Do is called concurrently from every worker goroutine (there are
+// ClientConfig.MaxConns of them), so implementations must be safe for
+// concurrent use.
+type Client interface {
+	// Do performs a single request and returns its status code,
+	// time taken in microseconds and an error, if any. Codes are
+	// grouped into 1xx-5xx classes, everything else (including
+	// negative codes, that are commonly used for failed requests)
+	// is counted as others.
+	Do() (code int, usTaken uint64, err error)
+}
+
+// ClientConfig describes requests a Client should perform.
+type ClientConfig struct {
+	// Context is done when the test is aborted and requests in
+	// flight should be aborted too.
+	Context context.Context
+
+	URL, Method string
+	Headers     http.Header
+
+	// Body is the request body, unless BodyStream is not nil, in
+	// which case each request should use a fresh stream produced
+	// by BodyStream.
+	Body       string
+	BodyStream func() (io.ReadCloser, error)
+
+	MaxConns  uint64
+	Timeout   time.Duration
+	TLSConfig *tls.Config
+
+	// Dial establishes connections, that are accounted for in
+	// throughput statistics.
+	Dial func(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// ClientFactory creates Client for the test.
+type ClientFactory func(ClientConfig) (Client, error)
+
+var (
+	customClientsMu sync.RWMutex
+	customClients   = make(map[string]ClientFactory)
+)
+
+// RegisterClient makes a custom client available under the given
+// name, so that it can be selected using Config.CustomClient.
+func RegisterClient(name string, factory ClientFactory) error {
+	if name == "" || factory == nil {
+		return errInvalidClientRegistration
+	}
+	customClientsMu.Lock()
+	defer customClientsMu.Unlock()
+	if _, dup := customClients[name]; dup {
+		return errClientRegistered
+	}
+	customClients[name] = factory
+	return nil
+}
+
+func lookupClient(name string) (ClientFactory, bool) {
+	customClientsMu.RLock()
+	defer customClientsMu.RUnlock()
+	factory, ok := customClients[name]
+	return factory, ok
+}
+
+type unknownClientError struct {
+	name string
+}
+
+func (u *unknownClientError) Error() string {
+	return fmt.Sprintf("Unknown client: %v", u.name)
+}
+
+// customClient adapts Client to the client interface used internally.
+type customClient struct {
+	Client
+}
+
+func (c customClient) do() (code int, msTaken uint64, err error) {
+	return c.Do()
+}
+
+func newCustomClient(name string, opts *clientOpts) (client, error) {
+	factory, ok := lookupClient(name)
+	if !ok {
+		return nil, &unknownClientError{name}
+	}
+	cc := ClientConfig{
+		Context: opts.context(),
+
+		URL:     opts.url,
+		Method:  opts.method,
+		Headers: headersToHTTPHeaders(opts.headers),
+
+		BodyStream: opts.bodProd,
+
+		MaxConns:  opts.maxConns,
+		Timeout:   opts.timeout,
+		TLSConfig: opts.tlsConfig,
+
+		Dial: httpDialContextFunc(opts),
+	}
+	if opts.body != nil {
+		cc.Body = *opts.body
+	}
+	c, err := factory(cc)
+	if err != nil {
+		return nil, err
+	}
+	return customClient{c}, nil
+}
@@ -0,0 +1,105 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+type fakeClient struct {
+	calls *uint64
+}
+
+func (f fakeClient) Do() (int, uint64, error) {
+	n := atomic.AddUint64(f.calls, 1)
+	if n%2 == 0 {
+		return 503, 100, nil
+	}
+	return 200, 50, nil
+}
+
+func TestRegisterClient(t *testing.T) {
+	factory := func(ClientConfig) (Client, error) {
+		return nil, nil
+	}
+	if err := RegisterClient("", factory); err != errInvalidClientRegistration {
+		t.Errorf("Expected %v, but got %v", errInvalidClientRegistration, err)
+	}
+	if err := RegisterClient("nil-factory", nil); err != errInvalidClientRegistration {
+		t.Errorf("Expected %v, but got %v", errInvalidClientRegistration, err)
+	}
+	if err := RegisterClient("test-duplicate", factory); err != nil {
+		t.Error(err)
+	}
+	if err := RegisterClient("test-duplicate", factory); err != errClientRegistered {
+		t.Errorf("Expected %v, but got %v", errClientRegistered, err)
+	}
+}
+
+func TestRunWithCustomClient(t *testing.T) {
+	calls := uint64(0)
+	var received ClientConfig
+	err := RegisterClient("test-fake", func(cc ClientConfig) (Client, error) {
+		received = cc
+		return fakeClient{&calls}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	numReqs := uint64(10)
+	res, err := Run(context.Background(), Config{
+		NumConns:     2,
+		NumReqs:      &numReqs,
+		Url:          "amqp://broker/queue",
+		Method:       "POST",
+		Body:         "message",
+		Timeout:      defaultTimeout,
+		CustomClient: "test-fake",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != numReqs {
+		t.Errorf("Expected %v calls, but got %v", numReqs, calls)
+	}
+	if res.Req2XX != numReqs/2 || res.Req5XX != numReqs/2 {
+		t.Errorf("Unexpected results: %+v", res)
+	}
+	if received.URL != "amqp://broker/queue" || received.Method != "POST" ||
+		received.Body != "message" || received.Dial == nil ||
+		received.Context == nil {
+		t.Errorf("Unexpected client config: %+v", received)
+	}
+}
+
+func TestCustomClientFactoryError(t *testing.T) {
+	factoryErr := errors.New("can't create client")
+	err := RegisterClient("test-failing", func(ClientConfig) (Client, error) {
+		return nil, factoryErr
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	numReqs := uint64(10)
+	_, err = Run(context.Background(), Config{
+		NumReqs:      &numReqs,
+		Url:          "http://localhost",
+		CustomClient: "test-failing",
+	})
+	if err != factoryErr {
+		t.Errorf("Expected %v, but got %v", factoryErr, err)
+	}
+}
+
+func TestUnknownCustomClient(t *testing.T) {
+	numReqs := uint64(10)
+	_, err := Run(context.Background(), Config{
+		NumReqs:      &numReqs,
+		Url:          "http://localhost",
+		CustomClient: "test-unknown",
+	})
+	if _, ok := err.(*unknownClientError); !ok {
+		t.Errorf("Expected unknown client error, but got %v", err)
+	}
+}
@@ -1,14 +1,20 @@
 package lib
 
 import (
+	"bytes"
+	"context"
 	"crypto/tls"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	uhist "github.com/codesenberg/concurrent/uint64/histogram"
 	"github.com/goware/urlx"
 	"github.com/valyala/fasthttp"
 	"golang.org/x/net/http2"
@@ -22,6 +28,12 @@ type bodyStreamProducer func() (io.ReadCloser, error)
 
 type clientOpts struct {
 	HTTP2 bool
+	// HTTP3 makes net/http client send requests over QUIC
+	HTTP3 bool
+
+	// ctx is used to abort requests in flight, defaults to
+	// context.Background() if nil
+	ctx context.Context
 
 	maxConns  uint64
 	timeout   time.Duration
@@ -34,36 +46,310 @@ type clientOpts struct {
 	bodProd bodyStreamProducer
 
 	bytesRead, bytesWritten *int64
+	openConns               *int64
+	// unixSocket is dialed instead of the host from url, if not empty
+	unixSocket string
+	// proxy is nil unless connections are tunneled through a proxy
+	proxy *url.URL
+	// resolver is nil unless addresses are resolved by bombardier
+	resolver *resolver
+	// localAddrs is nil unless connections are bound to local
+	// addresses
+	localAddrs *localAddrs
+	// impairment simulates poor network, if set
+	impairment ImpairmentOptions
+	// proxyConnects records time taken to establish tunnels
+	// through the proxy, if not nil
+	proxyConnects *uhist.Histogram
+	// conns controls reuse of connections
+	conns ConnOptions
+	// connStats is optional and may be nil
+	connStats *connStats
+
+	middleware middlewareChain
+
+	// wsEcho enables echo mode of the WebSocket client
+	wsEcho bool
+	// streams is not nil if responses should be measured as
+	// streams of events
+	streams *streamStats
+	// tcp describes responses of the raw TCP client
+	tcp TCPOptions
+	// http2 controls connections and streams of the HTTP/2 client
+	http2 HTTP2Options
+	// http2Stats is optional and may be nil
+	http2Stats *http2Stats
+	// quicStats is optional and may be nil
+	quicStats *quicStats
+	// pipelineDepth is the number of requests in flight per
+	// connection of the pipelined client
+	pipelineDepth uint64
+	// maxRedirects is the number of redirects HTTP clients follow
+	maxRedirects uint64
+	// redirects is optional and may be nil
+	redirects *redirectStats
+	// compression is nil unless responses should be decoded
+	compression *compressionStats
+	// responses is optional and may be nil
+	responses *responseVerifier
+	// grpcProtoset is the file with descriptors of gRPC method,
+	// server reflection is used if it's empty
+	grpcProtoset string
+	// grpcMaxMsgSize is the size of the largest message gRPC client
+	// accepts
+	grpcMaxMsgSize uint64
+}
+
+func (opts *clientOpts) context() context.Context {
+	if opts.ctx == nil {
+		return context.Background()
+	}
+	return opts.ctx
+}
+
+// fasthttpDoer is implemented by both fasthttp.HostClient and
+// fasthttp.PipelineClient.
+type fasthttpDoer interface {
+	Do(req *fasthttp.Request, resp *fasthttp.Response) error
 }
 
 type fasthttpClient struct {
-	client *fasthttp.Client
+	// client is nil if slots are used instead
+	client fasthttpDoer
+	// slots is nil unless reuse of connections is controlled
+	slots       chan *connSlot
+	maxRequests uint64
 
 	headers     *fasthttp.RequestHeader
 	url, method string
 
 	body    *string
 	bodProd bodyStreamProducer
+
+	middleware middlewareChain
+	// closeConns tells whether connections should be closed after
+	// each request
+	closeConns bool
+	// connStats is optional and may be nil
+	connStats *connStats
+
+	// redirects is nil unless redirects are followed, addr and isTLS
+	// tell which of them redirectClient follows
+	redirects      *redirectStats
+	maxRedirects   int
+	redirectClient *fasthttp.Client
+	addr           string
+	isTLS          bool
+
+	// compression is nil unless responses are decoded
+	compression *compressionStats
+	// responses is optional and may be nil
+	responses *responseVerifier
 }
 
 func newFastHTTPClient(opts *clientOpts) client {
 	c := new(fasthttpClient)
-	c.client = &fasthttp.Client{
-		MaxConnsPerHost:               int(opts.maxConns),
-		ReadTimeout:                   opts.timeout,
-		WriteTimeout:                  opts.timeout,
-		DisableHeaderNamesNormalizing: true,
-		TLSConfig:                     opts.tlsConfig,
-		Dial: fasthttpDialFunc(
-			opts.bytesRead, opts.bytesWritten,
-		),
+	addr, isTLS := fasthttpAddr(opts.url)
+	if opts.conns.isSet() {
+		c.slots = newConnSlots(opts, addr, isTLS)
+		c.maxRequests = opts.conns.MaxRequests
+	} else {
+		c.client = newHostClient(
+			opts, addr, isTLS, int(opts.maxConns), fasthttpDialFunc(opts),
+		)
 	}
 	c.headers = headersToFastHTTPHeaders(opts.headers)
 	c.url, c.method, c.body = opts.url, opts.method, opts.body
 	c.bodProd = opts.bodProd
+	c.middleware = opts.middleware
+	c.closeConns = opts.conns.DisableKeepAlive
+	c.connStats = opts.connStats
+	c.responses = opts.responses
+	c.setCompression(opts)
+	if opts.maxRedirects > 0 {
+		c.redirects = opts.redirects
+		if c.redirects == nil {
+			c.redirects = newRedirectStats()
+		}
+		c.maxRedirects = int(opts.maxRedirects)
+		c.addr, c.isTLS = addr, isTLS
+		// Redirects to other hosts can't be followed by HostClient
+		c.redirectClient = &fasthttp.Client{
+			MaxConnsPerHost:               int(opts.maxConns),
+			MaxIdleConnDuration:           opts.conns.IdleTimeout,
+			ReadTimeout:                   opts.timeout,
+			WriteTimeout:                  opts.timeout,
+			DisableHeaderNamesNormalizing: true,
+			TLSConfig:                     opts.tlsConfig,
+			Dial:                          fasthttpDialFunc(opts),
+		}
+	}
 	return client(c)
 }
 
+// newFastHTTPPipelineClient returns fasthttp client, that sends up to
+// opts.pipelineDepth requests over each connection without waiting
+// for responses.
+func newFastHTTPPipelineClient(opts *clientOpts) client {
+	c := new(fasthttpClient)
+	addr, isTLS := fasthttpAddr(opts.url)
+	c.client = &fasthttp.PipelineClient{
+		Addr:               addr,
+		MaxConns:           int(opts.maxConns),
+		MaxPendingRequests: int(opts.pipelineDepth),
+		ReadTimeout:        opts.timeout,
+		WriteTimeout:       opts.timeout,
+		IsTLS:              isTLS,
+		TLSConfig:          opts.tlsConfig,
+		Dial:               fasthttpDialFunc(opts),
+	}
+	c.headers = headersToFastHTTPHeaders(opts.headers)
+	c.url, c.method, c.body = opts.url, opts.method, opts.body
+	c.bodProd = opts.bodProd
+	c.middleware = opts.middleware
+	c.responses = opts.responses
+	c.setCompression(opts)
+	return client(c)
+}
+
+func (c *fasthttpClient) setCompression(opts *clientOpts) {
+	c.compression = opts.compression
+	if c.compression == nil {
+		return
+	}
+	if c.headers == nil {
+		c.headers = new(fasthttp.RequestHeader)
+	}
+	if len(c.headers.Peek("Accept-Encoding")) == 0 {
+		c.headers.Set("Accept-Encoding", acceptEncoding)
+	}
+}
+
+// fasthttpAddr returns host:port, that fasthttp clients connect to,
+// and tells whether connections must be encrypted.
+func fasthttpAddr(rawurl string) (addr string, isTLS bool) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		// rawurl guaranteed to be valid at this point
+		panic(err)
+	}
+	isTLS = u.Scheme == "https"
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if isTLS {
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port), isTLS
+}
+
+func newHostClient(
+	opts *clientOpts, addr string, isTLS bool, maxConns int,
+	dial fasthttp.DialFunc,
+) *fasthttp.HostClient {
+	return &fasthttp.HostClient{
+		Addr:                          addr,
+		IsTLS:                         isTLS,
+		TLSConfig:                     opts.tlsConfig,
+		MaxConns:                      maxConns,
+		MaxIdleConnDuration:           opts.conns.IdleTimeout,
+		MaxConnDuration:               opts.conns.MaxLifetime,
+		ReadTimeout:                   opts.timeout,
+		WriteTimeout:                  opts.timeout,
+		DisableHeaderNamesNormalizing: true,
+		Dial:                          dial,
+	}
+}
+
+// connSlot holds a connection of fasthttpClient, whose reuse is
+// controlled. Each slot has HostClient of its own, that keeps at most
+// one connection, so that requests could be told apart by connections
+// they're sent over.
+type connSlot struct {
+	client *fasthttp.HostClient
+	// conn is the connection client dialed last, if any
+	conn     *countingConn
+	lifetime time.Duration
+	// closing tells whether the slot made the request in flight
+	// close the connection
+	closing bool
+}
+
+func newConnSlots(
+	opts *clientOpts, addr string, isTLS bool,
+) chan *connSlot {
+	slots := make(chan *connSlot, opts.maxConns)
+	dial := fasthttpDialFunc(opts)
+	for i := uint64(0); i < opts.maxConns; i++ {
+		s := &connSlot{lifetime: opts.conns.MaxLifetime}
+		s.client = newHostClient(opts, addr, isTLS, 1,
+			func(address string) (net.Conn, error) {
+				conn, err := dial(address)
+				if err == nil {
+					// Dialed for the request about to be sent
+					s.conn = countingConnOf(conn)
+					s.conn.countRequest()
+				}
+				return conn, err
+			},
+		)
+		slots <- s
+	}
+	return slots
+}
+
+// startRequest makes the request close the connection, if it's the
+// last one allowed over it. Connections are retired after MaxLifetime
+// by HostClient itself.
+func (s *connSlot) startRequest(req *fasthttp.Request, maxRequests uint64) {
+	n := uint64(1)
+	if s.conn != nil && !s.conn.isClosed() {
+		n = s.conn.countRequest()
+	}
+	s.closing = maxRequests > 0 && n >= maxRequests &&
+		!req.ConnectionClose()
+	if s.closing {
+		req.SetConnectionClose()
+	}
+}
+
+// finishRequest records, whether the server closed the connection on
+// its own.
+func (s *connSlot) finishRequest(
+	req *fasthttp.Request, resp *fasthttp.Response, err error,
+) {
+	if err == nil && s.conn != nil && !s.retired() &&
+		resp.ConnectionClose() && !req.ConnectionClose() {
+		s.conn.closeAnnounced()
+	}
+	if s.closing {
+		req.Header.ResetConnectionClose()
+		s.closing = false
+	}
+}
+
+// retired tells whether HostClient made the request close connection
+// older than MaxConnDuration, which isn't seen in the request itself,
+// since Connection header is reset after the request is sent.
+func (s *connSlot) retired() bool {
+	return s.lifetime > 0 && time.Since(s.conn.created) > s.lifetime
+}
+
+// send sends the request to the host of the test, over the connection
+// of the slot, if any.
+func (c *fasthttpClient) send(
+	slot *connSlot, req *fasthttp.Request, resp *fasthttp.Response,
+) error {
+	if slot == nil {
+		return c.client.Do(req, resp)
+	}
+	slot.startRequest(req, c.maxRequests)
+	err := slot.client.Do(req, resp)
+	slot.finishRequest(req, resp, err)
+	return err
+}
+
 func (c *fasthttpClient) do() (
 	code int, msTaken uint64, err error,
 ) {
@@ -75,6 +361,9 @@ func (c *fasthttpClient) do() (
 	}
 	req.Header.SetMethod(c.method)
 	req.SetRequestURI(c.url)
+	if c.closeConns {
+		req.SetConnectionClose()
+	}
 	if c.body != nil {
 		req.SetBodyString(*c.body)
 	} else {
@@ -82,19 +371,56 @@ func (c *fasthttpClient) do() (
 		if bserr != nil {
 			return 0, 0, bserr
 		}
-		req.SetBodyStream(bs, -1)
+		req.SetBodyStream(bs, int(bodySize(bs)))
+	}
+
+	var freq *fasthttpRequest
+	if len(c.middleware) > 0 {
+		freq = &fasthttpRequest{req: req, streamed: c.body == nil}
+		if err = c.middleware.beforeRequest(freq); err != nil {
+			c.middleware.onError(freq, err)
+			fasthttp.ReleaseRequest(req)
+			fasthttp.ReleaseResponse(resp)
+			return 0, 0, err
+		}
+	}
+
+	var slot *connSlot
+	if c.slots != nil {
+		slot = <-c.slots
 	}
 
 	// fire the request
 	start := time.Now()
-	err = c.client.Do(req, resp)
+	err = c.send(slot, req, resp)
+	if err == nil && c.redirects != nil {
+		err = c.followRedirects(slot, req, resp, start)
+	}
+	if slot != nil {
+		c.slots <- slot
+	}
 	if err != nil {
 		code = -1
 	} else {
 		code = resp.StatusCode()
+		if c.compression != nil {
+			err = c.decode(resp)
+		}
+		if err == nil && c.responses != nil {
+			err = c.responses.verify(resp.Body())
+		}
 	}
 	msTaken = uint64(time.Since(start).Nanoseconds() / 1000)
 
+	if freq != nil {
+		if err == nil {
+			err = c.middleware.afterResponse(freq, &fasthttpResponse{resp})
+		}
+		if err != nil {
+			c.middleware.onError(freq, err)
+		}
+	}
+
 	// release resources
 	fasthttp.ReleaseRequest(req)
 	fasthttp.ReleaseResponse(resp)
@@ -102,8 +428,75 @@ func (c *fasthttpClient) do() (
 	return
 }
 
+// decode decodes body of the response, if it's encoded, so that
+// middleware could inspect it.
+func (c *fasthttpClient) decode(resp *fasthttp.Response) error {
+	encoding := string(resp.Header.Peek("Content-Encoding"))
+	if !isKnownEncoding(encoding) {
+		return nil
+	}
+	body := resp.Body()
+	decoded, err := decodeBody(body, encoding)
+	if err != nil {
+		return err
+	}
+	c.compression.record(len(body), len(decoded))
+	resp.Header.Del("Content-Encoding")
+	resp.SetBody(decoded)
+	return nil
+}
+
+// followRedirects follows up to c.maxRedirects redirects, leaving the
+// final response in resp.
+func (c *fasthttpClient) followRedirects(
+	slot *connSlot, req *fasthttp.Request, resp *fasthttp.Response,
+	start time.Time,
+) error {
+	rc := c.redirects.newChain(start)
+	defer rc.done()
+	for rc.hops < c.maxRedirects {
+		code := resp.StatusCode()
+		location := resp.Header.Peek("Location")
+		if redirectIndex(code) < 0 || len(location) == 0 {
+			return nil
+		}
+		rc.follow(code)
+
+		req.URI().UpdateBytes(location)
+		method := string(req.Header.Method())
+		if m := redirectMethod(code, method); m != method {
+			// SetMethod appends to the current method
+			req.Header.SetMethodBytes([]byte(m))
+			req.ResetBody()
+		} else if c.body == nil {
+			bs, err := c.bodProd()
+			if err != nil {
+				return err
+			}
+			req.SetBodyStream(bs, int(bodySize(bs)))
+		}
+
+		addr, isTLS := fasthttpAddr(req.URI().String())
+		if addr == c.addr && isTLS == c.isTLS {
+			if err := c.send(slot, req, resp); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := c.redirectClient.Do(req, resp); err != nil {
+			return err
+		}
+		if c.connStats != nil &&
+			resp.ConnectionClose() && !req.ConnectionClose() {
+			atomic.AddUint64(&c.connStats.closedByPeer, 1)
+		}
+	}
+	return nil
+}
+
 type httpClient struct {
 	client *http.Client
+	ctx    context.Context
 
 	headers http.Header
 	url     *url.URL
@@ -111,6 +504,21 @@ type httpClient struct {
 
 	body    *string
 	bodProd bodyStreamProducer
+
+	middleware   middlewareChain
+	readResponse bool
+
+	streams *streamStats
+	// limitConns tells whether connections should be retired after
+	// some number of requests or time
+	limitConns bool
+	closeConns bool
+	// redirects is nil unless redirects are followed
+	redirects *redirectStats
+	// compression is nil unless responses are decoded
+	compression *compressionStats
+	// responses is optional and may be nil
+	responses *responseVerifier
 }
 
 func newHTTPClient(opts *clientOpts) client {
@@ -118,8 +526,10 @@ func newHTTPClient(opts *clientOpts) client {
 	tr := &http.Transport{
 		TLSClientConfig:     opts.tlsConfig,
 		MaxIdleConnsPerHost: int(opts.maxConns),
+		DisableKeepAlives:   opts.conns.DisableKeepAlive,
+		IdleConnTimeout:     opts.conns.IdleTimeout,
 	}
-	tr.DialContext = httpDialContextFunc(opts.bytesRead, opts.bytesWritten)
+	tr.DialContext = httpDialContextFunc(opts)
 	if opts.HTTP2 {
 		_ = http2.ConfigureTransport(tr)
 	} else {
@@ -127,28 +537,77 @@ func newHTTPClient(opts *clientOpts) client {
 			map[string]func(authority string, c *tls.Conn) http.RoundTripper,
 		)
 	}
+	var err error
+	c.url, err = urlx.Parse(opts.url)
+	if err != nil {
+		// opts.url guaranteed to be valid at this point
+		panic(err)
+	}
+	var rt http.RoundTripper = tr
+	if opts.HTTP3 {
+		rt = newHTTP3Transport(opts)
+	} else if opts.HTTP2 && opts.http2.isSet() {
+		rt = newHTTP2Pool(opts, c.url)
+	}
 
 	cl := &http.Client{
-		Transport: tr,
+		Transport: rt,
 		Timeout:   opts.timeout,
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			return http.ErrUseLastResponse
 		},
 	}
 	c.client = cl
+	c.ctx = opts.context()
 
 	c.headers = headersToHTTPHeaders(opts.headers)
+	c.compression = opts.compression
+	if c.compression != nil && c.headers.Get("Accept-Encoding") == "" {
+		// Transport doesn't decode responses by itself then
+		c.headers.Set("Accept-Encoding", acceptEncoding)
+	}
 	c.method, c.body, c.bodProd = opts.method, opts.body, opts.bodProd
-	var err error
-	c.url, err = urlx.Parse(opts.url)
-	if err != nil {
-		// opts.url guaranteed to be valid at this point
-		panic(err)
+	c.middleware = opts.middleware
+	c.responses = opts.responses
+	c.readResponse = opts.middleware.inspectsResponses()
+	c.streams = opts.streams
+	c.limitConns = opts.conns.MaxRequests > 0 || opts.conns.MaxLifetime > 0
+	c.closeConns = opts.conns.DisableKeepAlive
+	if opts.maxRedirects > 0 {
+		c.redirects = opts.redirects
+		if c.redirects == nil {
+			c.redirects = newRedirectStats()
+		}
+		cl.CheckRedirect = checkRedirectFunc(int(opts.maxRedirects))
 	}
 
 	return client(c)
 }
 
+// checkRedirectFunc returns http.Client's CheckRedirect, that follows
+// up to max redirects, recording hops of requests that carry
+// redirectChain in their context.
+func checkRedirectFunc(
+	max int,
+) func(req *http.Request, via []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) > max {
+			return http.ErrUseLastResponse
+		}
+		if rc := redirectChainFrom(req.Context()); rc != nil {
+			rc.follow(req.Response.StatusCode)
+		}
+		return nil
+	}
+}
+
+func (c *httpClient) getBody() (io.ReadCloser, error) {
+	if c.body != nil {
+		return ioutil.NopCloser(strings.NewReader(*c.body)), nil
+	}
+	return c.bodProd()
+}
+
 func (c *httpClient) do() (
 	code int, msTaken uint64, err error,
 ) {
@@ -158,10 +617,6 @@ func (c *httpClient) do() (
 	req.Method = c.method
 	req.URL = c.url
 
-	if host := req.Header.Get("Host"); host != "" {
-		req.Host = host
-	}
-
 	if c.body != nil {
 		br := strings.NewReader(*c.body)
 		req.Body = ioutil.NopCloser(br)
@@ -171,26 +626,146 @@ func (c *httpClient) do() (
 			return 0, 0, bserr
 		}
 		req.Body = bs
+		// Redirected requests keep the length, while bodies produced
+		// for them may differ in size
+		if c.redirects == nil {
+			req.ContentLength = bodySize(bs)
+		}
+	}
+	if c.redirects != nil {
+		// Otherwise 307 and 308 redirects aren't followed
+		req.GetBody = c.getBody
 	}
 
+	var hreq *httpRequest
+	if len(c.middleware) > 0 {
+		// Headers are shared between requests otherwise
+		req.Header = c.headers.Clone()
+		hreq = &httpRequest{req: req}
+		if c.body != nil {
+			hreq.body = []byte(*c.body)
+		}
+		if err = c.middleware.beforeRequest(hreq); err != nil {
+			c.middleware.onError(hreq, err)
+			return 0, 0, err
+		}
+	}
+
+	if host := req.Header.Get("Host"); host != "" {
+		req.Host = host
+	}
+	if c.limitConns && hreq == nil {
+		// Connection header may be set below
+		req.Header = c.headers.Clone()
+	}
+
+	var firstByte time.Time
+	var cc *countingConn
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			cc = countingConnOf(info.Conn)
+			if cc != nil && cc.startRequest() && c.limitConns {
+				// Transport closes the connection after response
+				// to such request
+				req.Header.Set("Connection", "close")
+			}
+		},
+	}
+	if c.streams != nil {
+		trace.GotFirstResponseByte = func() {
+			firstByte = time.Now()
+		}
+	}
+	ctx := httptrace.WithClientTrace(c.ctx, trace)
+
+	var respBody []byte
+	var rc *redirectChain
 	start := time.Now()
-	resp, err := c.client.Do(req)
+	if c.redirects != nil {
+		rc = c.redirects.newChain(start)
+		ctx = context.WithValue(ctx, redirectChainKey{}, rc)
+	}
+	resp, err := c.client.Do(req.WithContext(ctx))
 	if err != nil {
 		code = -1
 	} else {
 		code = resp.StatusCode
+		if cc != nil && resp.Close && !c.closeConns &&
+			req.Header.Get("Connection") != "close" {
+			cc.closeAnnounced()
+		}
 
-		_, berr := io.Copy(ioutil.Discard, resp.Body)
+		var body io.Reader = resp.Body
+		var encoded, decoded *countingReader
+		var berr error
+		if enc := resp.Header.Get("Content-Encoding"); c.compression != nil &&
+			isKnownEncoding(enc) {
+			encoded = &countingReader{r: resp.Body}
+			body, berr = newDecoder(encoded, enc)
+			if berr == nil {
+				defer releaseDecoder(body, enc)
+			}
+			decoded = &countingReader{r: body}
+			body = decoded
+		}
+		var verified *verifyingReader
+		if c.responses != nil {
+			verified = c.responses.reader(body)
+			body = verified
+		}
+		switch {
+		case berr != nil:
+		case c.streams != nil:
+			if firstByte.IsZero() {
+				firstByte = time.Now()
+			}
+			var buf *bytes.Buffer
+			if c.readResponse {
+				buf = new(bytes.Buffer)
+				body = io.TeeReader(body, buf)
+			}
+			berr = c.streams.record(
+				body, isEventStream(resp), start, firstByte,
+			)
+			if buf != nil {
+				respBody = buf.Bytes()
+			}
+		case c.readResponse:
+			respBody, berr = ioutil.ReadAll(body)
+		default:
+			_, berr = io.Copy(ioutil.Discard, body)
+		}
 		if berr != nil {
 			err = berr
+		} else {
+			if decoded != nil {
+				c.compression.record(encoded.n, decoded.n)
+			}
+			if verified != nil {
+				err = verified.verify()
+			}
 		}
 
 		if cerr := resp.Body.Close(); cerr != nil {
 			err = cerr
 		}
 	}
+	if rc != nil {
+		rc.done()
+	}
 	msTaken = uint64(time.Since(start).Nanoseconds() / 1000)
 
+	if hreq != nil {
+		if err == nil {
+			err = c.middleware.afterResponse(
+				hreq, &httpResponse{resp, respBody},
+			)
+		}
+		if err != nil {
+			c.middleware.onError(hreq, err)
+		}
+	}
+
 	return
 }
 
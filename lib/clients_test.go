@@ -1,10 +1,14 @@
 package lib
 
 import (
+	"bufio"
 	"bytes"
 	"crypto/tls"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -179,3 +183,101 @@ func TestHTTP1Clients(t *testing.T) {
 		}
 	}
 }
+
+func TestBombardierPipelined(t *testing.T) {
+	var served uint64
+	s := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddUint64(&served, 1)
+		},
+	))
+	defer s.Close()
+	numReqs := uint64(100)
+	b, e := NewBombardier(Config{
+		NumConns:      2,
+		NumReqs:       &numReqs,
+		Url:           s.URL,
+		Headers:       new(HeadersList),
+		Timeout:       defaultTimeout,
+		Method:        "GET",
+		ClientType:    pipelined,
+		PipelineDepth: 5,
+		Format:        knownFormat("plain-text"),
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	b.disableOutput()
+	b.Bombard()
+
+	if b.req2xx != numReqs || served != numReqs {
+		t.Errorf("Expected %v requests served, but got %v (%v 2xx), "+
+			"errors: %v", numReqs, served, b.req2xx, b.errors.byFrequency())
+	}
+	if info := b.gatherInfo(); !info.Spec.IsPipelined() ||
+		info.Spec.PipelineDepth != 5 {
+		t.Errorf("Expected pipelined client in spec, but got %+v",
+			info.Spec)
+	}
+}
+
+func TestPipelinedClientSendsRequestsAhead(t *testing.T) {
+	// The server answers only once it receives the whole pipeline,
+	// which would never happen, if requests weren't pipelined
+	const depth = 4
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = l.Close()
+	}()
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+		br := bufio.NewReader(conn)
+		for {
+			for i := 0; i < depth; i++ {
+				if _, err := http.ReadRequest(br); err != nil {
+					return
+				}
+			}
+			for i := 0; i < depth; i++ {
+				_, _ = io.WriteString(conn,
+					"HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n")
+			}
+		}
+	}()
+
+	bytesRead, bytesWritten := int64(0), int64(0)
+	c := newFastHTTPPipelineClient(&clientOpts{
+		maxConns:      1,
+		timeout:       defaultTimeout,
+		headers:       new(HeadersList),
+		url:           "http://" + l.Addr().String(),
+		method:        "GET",
+		body:          new(string),
+		bytesRead:     &bytesRead,
+		bytesWritten:  &bytesWritten,
+		pipelineDepth: depth,
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < depth; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 2; j++ {
+				code, _, err := c.do()
+				if err != nil || code != http.StatusOK {
+					t.Errorf("Expected 200, but got %v, %v", code, err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -9,6 +9,8 @@ import (
 const (
 	decBase = 10
 
+	unixSocketScheme = "unix://"
+
 	rateLimitInterval = 10 * time.Millisecond
 	oneSecond         = 1 * time.Second
 
@@ -21,9 +23,11 @@ var (
 	emptyConf = Config{}
 	Parser    = newKingpinParser()
 
-	defaultTestDuration  = 10 * time.Second
-	defaultNumberOfConns = uint64(125)
-	defaultTimeout       = 2 * time.Second
+	defaultTestDuration   = 10 * time.Second
+	defaultNumberOfConns  = uint64(125)
+	defaultTimeout        = 2 * time.Second
+	defaultPipelineDepth  = uint64(10)
+	defaultGRPCMaxMsgSize = uint64(4 << 20)
 
 	httpMethods = []string{
 		"GET", "POST", "PUT", "DELETE", "HEAD", "OPTIONS",
@@ -33,6 +37,8 @@ var (
 
 	errInvalidURL = errors.New(
 		"No hostname or invalid scheme")
+	errInvalidUnixSocketURL = errors.New(
+		"Unix socket URL must look like unix:///path/to.sock:/http/path")
 	errInvalidNumberOfConns = errors.New(
 		"Invalid number of connections(must be > 0)")
 	errInvalidNumberOfRequests = errors.New(
@@ -54,6 +60,154 @@ var (
 	errInvalidHeaderFormat = errors.New("Invalid header format")
 	errEmptyPrintSpec      = errors.New(
 		"Empty print spec is not a valid print spec")
+	errNoResultInBaseline = errors.New(
+		"Baseline file doesn't contain any results")
+	errNegativeSnapshotInterval = errors.New(
+		"Snapshot interval can't be negative")
+	errNegativeDrainTimeout = errors.New(
+		"Drain timeout can't be negative")
+	errInvalidClientRegistration = errors.New(
+		"Client must have a name and a factory")
+	errClientRegistered = errors.New(
+		"Client with this name is already registered")
+	errNoGRPCStatus = errors.New(
+		"Response doesn't contain gRPC status")
+	errInvalidGRPCStatus = errors.New(
+		"Response contains invalid gRPC status")
+	errGRPCProtosetNotSupported = errors.New(
+		"Protoset can only be used with gRPC client")
+	errInvalidProtobuf = errors.New(
+		"Invalid protobuf message")
+	errGRPCMaxMsgSizeNotSupported = errors.New(
+		"Max message size can only be set for gRPC client")
+	errGRPCMessageTooLarge = errors.New(
+		"Received gRPC message is larger than max message size")
+	errWebSocketHandshakeRejected = errors.New(
+		"WebSocket handshake was rejected")
+	errInvalidWebSocketAccept = errors.New(
+		"Invalid Sec-WebSocket-Accept in handshake response")
+	errInvalidWebSocketFrame = errors.New(
+		"Received invalid WebSocket frame")
+	errWebSocketClosed = errors.New(
+		"WebSocket connection was closed by server")
+	errStreamEventsNotSupported = errors.New(
+		"Streams of events can only be measured by net/http clients")
+	errNoTCPPort = errors.New(
+		"TCP URL must contain a port")
+	errAmbiguousTCPFraming = errors.New(
+		"Only one of terminator, length prefix and response size " +
+			"can be specified")
+	errInvalidTCPLengthPrefix = errors.New(
+		"Length prefix must be 1, 2, 4 or 8 bytes long")
+	errNegativeTCPResponseSize = errors.New(
+		"Response size can't be negative")
+	errInvalidProxyURL = errors.New(
+		"Proxy URL must have http, https, socks5 or socks5h scheme " +
+			"and a hostname")
+	errProxyWithUnixSocket = errors.New(
+		"Unix socket targets can't be reached through a proxy")
+	errInvalidSOCKS5Response = errors.New(
+		"Received invalid response from SOCKS5 proxy")
+	errSOCKS5AuthRequired = errors.New(
+		"SOCKS5 proxy requires unsupported authentication method")
+	errSOCKS5AuthFailed = errors.New(
+		"SOCKS5 proxy rejected username or password")
+	errInvalidSOCKS5Credentials = errors.New(
+		"SOCKS5 username and password can't be longer than 255 bytes")
+	errInvalidSOCKS5Address = errors.New(
+		"SOCKS5 host name can't be longer than 255 bytes")
+	errNegativeConnOption = errors.New(
+		"Connection lifetime and idle timeout can't be negative")
+	errConnOptionsNotSupported = errors.New(
+		"Connection reuse can only be controlled for fasthttp and " +
+			"net/http clients")
+	errConnOptionsWithHTTP3 = errors.New(
+		"Connection reuse can't be controlled for HTTP/3 client")
+	errConnLimitsWithHTTP2 = errors.New(
+		"Requests and lifetime of HTTP/2 connections can't be limited")
+	errHTTP2OptionsNotSupported = errors.New(
+		"HTTP/2 options can only be used with --http2")
+	errHTTP2OptionsWithConnOptions = errors.New(
+		"Connection reuse can't be controlled along with HTTP/2 options")
+	errHTTP3NeedsHTTPS = errors.New(
+		"HTTP/3 client can only be used with https:// URLs")
+	errHTTP3OptionsNotSupported = errors.New(
+		"Proxy, resolving, local addresses and network impairment " +
+			"aren't supported by HTTP/3 client")
+	errInvalidHTTP2WindowSize = errors.New(
+		"HTTP/2 connection window must be between 64KiB and 4MiB, " +
+			"stream window must be between 16KiB and 4MiB")
+	errHTTP2NeedsH2C = errors.New(
+		"HTTP/2 over cleartext connections requires --h2c")
+	errHTTP2NotNegotiated = errors.New(
+		"Server doesn't support HTTP/2")
+	errUnknownBodyEncoding = errors.New(
+		"Unknown body encoding, only gzip, deflate, br and zstd are " +
+			"supported")
+	errCompressionNotSupported = errors.New(
+		"Compression is only supported by HTTP clients")
+	errCompressStreamedBody = errors.New(
+		"Streamed body can't be compressed")
+	errInvalidFormField = errors.New(
+		"Form fields must be in key=value format")
+	errFormWithBody = errors.New(
+		"Form can't be used along with body")
+	errFormNotSupported = errors.New(
+		"Forms can only be sent by HTTP clients")
+	errInvalidByteSize = errors.New(
+		"Invalid size, must be a number optionally followed by " +
+			"B, KB, KiB, MB, MiB, GB or GiB")
+	errInvalidSizeDistribution = errors.New(
+		"Invalid distribution of body sizes, must be fixed, uniform " +
+			"or lognormal, optionally followed by positive sigma")
+	errInvalidSizeRange = errors.New(
+		"Invalid range of body sizes, it's only supported by uniform " +
+			"distribution and its lower bound can't exceed the upper one")
+	errZeroRandomBodySize = errors.New(
+		"Size of random bodies must be positive")
+	errRandomBodyTooLarge = errors.New(
+		"Size of random bodies can't exceed 1TiB")
+	errRandomBodyWithBody = errors.New(
+		"Random body can't be used along with body, form or compression")
+	errRandomBodyNotSupported = errors.New(
+		"Random bodies can only be sent by HTTP clients")
+	errInvalidChecksum = errors.New(
+		"Invalid checksum, must be md5, sha1, sha256 or sha512 " +
+			"followed by colon and hex-encoded digest")
+	errInvalidExpectedSize = errors.New(
+		"Lower bound of expected response size can't exceed the upper one")
+	errResponseChecksNotSupported = errors.New(
+		"Responses can only be checked by HTTP clients")
+	errUnexpectedResponseSize = errors.New(
+		"Unexpected size of response body")
+	errChecksumMismatch = errors.New(
+		"Checksum of response body doesn't match")
+	errInvalidResolveEntry = errors.New(
+		"Invalid address override, must be host:port:addr[,addr...] " +
+			"(IPv6 addresses in brackets)")
+	errInvalidDNSServer = errors.New(
+		"Invalid DNS server, must be IP address optionally followed " +
+			"by port")
+	errUnknownResolvePolicy = errors.New(
+		"Unknown resolve policy, must be round-robin or random")
+	errResolveWithProxy = errors.New(
+		"Addresses can't be resolved by bombardier, when connecting " +
+			"through proxy or Unix socket")
+	errInvalidLocalAddr = errors.New(
+		"Invalid local address, must be IP address or CIDR")
+	errTooManyLocalAddrs = errors.New(
+		"Too many local addresses, no more than 65536 are allowed")
+	errLocalAddrWithUnixSocket = errors.New(
+		"Local addresses can't be used with Unix socket")
+	errDialTimeout = errors.New(
+		"Timed out while connecting")
+	errInvalidImpairment = errors.New(
+		"Invalid network impairment, durations can't be negative and " +
+			"drop probability must be within [0, 1]")
+	errConnDropped = errors.New(
+		"Connection dropped to simulate network failure")
+	errRedirectsNotSupported = errors.New(
+		"Redirects can only be followed by fasthttp and net/http clients")
 )
 
 func init() {
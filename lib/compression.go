@@ -0,0 +1,158 @@
+package lib
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zlib"
+	"github.com/klauspost/compress/zstd"
+	"github.com/tony24681379/bombardier/internal"
+)
+
+const (
+	encodingGzip    = "gzip"
+	encodingDeflate = "deflate"
+	encodingBrotli  = "br"
+	encodingZstd    = "zstd"
+)
+
+var knownEncodings = []string{
+	encodingGzip, encodingDeflate, encodingBrotli, encodingZstd,
+}
+
+// acceptEncoding is sent by clients that decode responses.
+var acceptEncoding = strings.Join(knownEncodings, ", ")
+
+func isKnownEncoding(encoding string) bool {
+	for _, known := range knownEncodings {
+		if encoding == known {
+			return true
+		}
+	}
+	return false
+}
+
+func compressBody(body []byte, encoding string) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case encodingGzip:
+		w = gzip.NewWriter(&buf)
+	case encodingDeflate:
+		w = zlib.NewWriter(&buf)
+	case encodingBrotli:
+		w = brotli.NewWriter(&buf)
+	case encodingZstd:
+		var err error
+		if w, err = zstd.NewWriter(&buf); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errUnknownBodyEncoding
+	}
+	if _, err := w.Write(body); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decoders holds decoders of each known encoding, that are reused for
+// responses, since creating them is expensive.
+var decoders = map[string]*sync.Pool{
+	encodingGzip:    new(sync.Pool),
+	encodingDeflate: new(sync.Pool),
+	encodingBrotli:  new(sync.Pool),
+	encodingZstd:    new(sync.Pool),
+}
+
+// newDecoder returns reader of r decoded according to Content-Encoding
+// header, it must only be called for known encodings. The decoder
+// should be released once it's read.
+func newDecoder(r io.Reader, encoding string) (io.Reader, error) {
+	if d, ok := decoders[encoding].Get().(io.Reader); ok {
+		if err := resetDecoder(d, r); err != nil {
+			releaseDecoder(d, encoding)
+			return nil, err
+		}
+		return d, nil
+	}
+	switch encoding {
+	case encodingGzip:
+		return gzip.NewReader(r)
+	case encodingBrotli:
+		return brotli.NewReader(r), nil
+	case encodingZstd:
+		// Single goroutine decoder doesn't need to be closed
+		return zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+	}
+	return zlib.NewReader(r)
+}
+
+func resetDecoder(d, r io.Reader) error {
+	switch d := d.(type) {
+	case *gzip.Reader:
+		return d.Reset(r)
+	case *brotli.Reader:
+		return d.Reset(r)
+	case *zstd.Decoder:
+		return d.Reset(r)
+	case zlib.Resetter:
+		return d.Reset(r, nil)
+	}
+	return nil
+}
+
+// releaseDecoder lets decoder returned by newDecoder be reused.
+func releaseDecoder(d io.Reader, encoding string) {
+	decoders[encoding].Put(d)
+}
+
+func decodeBody(body []byte, encoding string) ([]byte, error) {
+	r, err := newDecoder(bytes.NewReader(body), encoding)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseDecoder(r, encoding)
+	return ioutil.ReadAll(r)
+}
+
+// countingReader counts bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += n
+	return n, err
+}
+
+// compressionStats holds number of decoded responses and their sizes
+// before and after decoding.
+type compressionStats struct {
+	responses, compressed, decompressed uint64
+}
+
+func (s *compressionStats) record(compressed, decompressed int) {
+	atomic.AddUint64(&s.responses, 1)
+	atomic.AddUint64(&s.compressed, uint64(compressed))
+	atomic.AddUint64(&s.decompressed, uint64(decompressed))
+}
+
+func (s *compressionStats) snapshot() *internal.CompressionStats {
+	return &internal.CompressionStats{
+		Responses:         atomic.LoadUint64(&s.responses),
+		CompressedBytes:   atomic.LoadUint64(&s.compressed),
+		DecompressedBytes: atomic.LoadUint64(&s.decompressed),
+	}
+}
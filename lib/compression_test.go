@@ -0,0 +1,189 @@
+package lib
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCompressBodyRoundTrip(t *testing.T) {
+	body := []byte(strings.Repeat("abracadabra", 100))
+	for _, encoding := range knownEncodings {
+		compressed, err := compressBody(body, encoding)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(compressed) >= len(body) {
+			t.Errorf("%v: expected body to be compressed, but got %v bytes",
+				encoding, len(compressed))
+		}
+		decoded, err := decodeBody(compressed, encoding)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(decoded, body) {
+			t.Errorf("%v: expected %q, but got %q", encoding, body, decoded)
+		}
+	}
+	if _, err := compressBody(body, "compress"); err != errUnknownBodyEncoding {
+		t.Errorf("Expected %v, but got %v", errUnknownBodyEncoding, err)
+	}
+}
+
+func TestReusedDecoders(t *testing.T) {
+	for _, encoding := range knownEncodings {
+		for _, body := range []string{"abracadabra", "hocus-pocus"} {
+			compressed, err := compressBody([]byte(body), encoding)
+			if err != nil {
+				t.Fatal(err)
+			}
+			d, err := newDecoder(bytes.NewReader(compressed), encoding)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := ioutil.ReadAll(d)
+			if err != nil || string(decoded) != body {
+				t.Errorf("%v: expected %q, but got (%q, %v)",
+					encoding, body, decoded, err)
+			}
+			releaseDecoder(d, encoding)
+		}
+	}
+}
+
+func TestBombardierCompressesBody(t *testing.T) {
+	clients := []clientTyp{fhttp, nhttp1, nhttp2, pipelined}
+	for _, encoding := range knownEncodings {
+		for _, clientType := range clients {
+			t.Run(encoding+"/"+clientType.String(), func(t *testing.T) {
+				testBombardierCompressesBody(t, clientType, encoding)
+			})
+		}
+	}
+}
+
+func testBombardierCompressesBody(
+	t *testing.T, clientType clientTyp, encoding string,
+) {
+	const body = "abracadabra"
+	s := httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			enc := r.Header.Get("Content-Encoding")
+			raw, err := ioutil.ReadAll(r.Body)
+			if err != nil || enc != encoding {
+				rw.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			decoded, err := decodeBody(raw, enc)
+			if err != nil || string(decoded) != body {
+				rw.WriteHeader(http.StatusBadRequest)
+			}
+		}),
+	)
+	defer s.Close()
+	numReqs := uint64(5)
+	b, e := NewBombardier(Config{
+		NumConns:     1,
+		NumReqs:      &numReqs,
+		Url:          s.URL,
+		Headers:      new(HeadersList),
+		Timeout:      defaultTimeout,
+		Method:       "POST",
+		Body:         body,
+		CompressBody: encoding,
+		ClientType:   clientType,
+		Format:       knownFormat("plain-text"),
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	b.disableOutput()
+	b.Bombard()
+
+	if b.req2xx != numReqs {
+		t.Errorf("Expected %v 2xx, but got %v 2xx, %v 4xx, errors: %v",
+			numReqs, b.req2xx, b.req4xx, b.errors.byFrequency())
+	}
+	if len(*b.Conf.Headers) != 0 {
+		t.Error("Expected headers of config to stay intact")
+	}
+}
+
+func TestBombardierDecompressesResponses(t *testing.T) {
+	testAllClients(t, func(clientType clientTyp, t *testing.T) {
+		for _, encoding := range knownEncodings {
+			t.Run(encoding, func(t *testing.T) {
+				testBombardierDecompressesResponses(t, clientType, encoding)
+			})
+		}
+	})
+}
+
+func testBombardierDecompressesResponses(
+	t *testing.T, clientType clientTyp, encoding string,
+) {
+	payload := []byte(strings.Repeat("abracadabra", 100))
+	compressed, err := compressBody(payload, encoding)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Accept-Encoding") != acceptEncoding {
+				rw.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			rw.Header().Set("Content-Encoding", encoding)
+			_, _ = rw.Write(compressed)
+		}),
+	)
+	defer s.Close()
+	errUnexpectedBody := errors.New("unexpected body")
+	var checked uint64
+	checking := Middleware{
+		AfterResponse: func(r Request, resp Response) error {
+			atomic.AddUint64(&checked, 1)
+			if !bytes.Equal(resp.Body(), payload) {
+				return errUnexpectedBody
+			}
+			return nil
+		},
+	}
+	numReqs := uint64(5)
+	b, e := NewBombardier(Config{
+		NumConns:   1,
+		NumReqs:    &numReqs,
+		Url:        s.URL,
+		Headers:    new(HeadersList),
+		Timeout:    defaultTimeout,
+		Method:     "GET",
+		Decompress: true,
+		ClientType: clientType,
+		Format:     knownFormat("plain-text"),
+	}, WithMiddleware(checking))
+	if e != nil {
+		t.Fatal(e)
+	}
+	b.disableOutput()
+	b.Bombard()
+
+	if b.req2xx != numReqs || b.errors.sum() != 0 {
+		t.Errorf("Expected %v 2xx, but got %v 2xx, %v 4xx, errors: %v",
+			numReqs, b.req2xx, b.req4xx, b.errors.byFrequency())
+	}
+	if checked != numReqs {
+		t.Errorf("Expected %v responses to be checked, but got %v",
+			numReqs, checked)
+	}
+	stats := b.compression.snapshot()
+	if stats.Responses != numReqs ||
+		stats.CompressedBytes != numReqs*uint64(len(compressed)) ||
+		stats.DecompressedBytes != numReqs*uint64(len(payload)) {
+		t.Errorf("Unexpected compression stats: %+v", *stats)
+	}
+}
@@ -0,0 +1,191 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/tony24681379/bombardier/internal"
+)
+
+const (
+	dashboardRefreshRate = 1 * time.Second
+	dashboardTopErrors   = 5
+
+	ansiEnterAltScreen = "\x1b[?1049h\x1b[?25l"
+	ansiLeaveAltScreen = "\x1b[?25h\x1b[?1049l"
+	ansiClearScreen    = "\x1b[H\x1b[2J"
+)
+
+var dashboardPercentiles = []float64{0.5, 0.95, 0.99}
+
+// latenciesWindow is a histogram of latencies recorded since the
+// previous call to advance. It's computed as a difference between
+// two snapshots of the cumulative histogram, so that workers don't
+// have to do any additional bookkeeping.
+type latenciesWindow struct {
+	source internal.ReadonlyUint64Histogram
+	prev   map[uint64]uint64
+	window map[uint64]uint64
+}
+
+func newLatenciesWindow(
+	source internal.ReadonlyUint64Histogram,
+) *latenciesWindow {
+	return &latenciesWindow{
+		source: source,
+		prev:   make(map[uint64]uint64),
+		window: make(map[uint64]uint64),
+	}
+}
+
+func (w *latenciesWindow) advance() {
+	cur := make(map[uint64]uint64, len(w.prev))
+	w.window = make(map[uint64]uint64)
+	w.source.VisitAll(func(k, v uint64) bool {
+		cur[k] = v
+		if d := v - w.prev[k]; d > 0 {
+			w.window[k] = d
+		}
+		return true
+	})
+	w.prev = cur
+}
+
+func (w *latenciesWindow) Get(k uint64) uint64 {
+	return w.window[k]
+}
+
+func (w *latenciesWindow) VisitAll(f func(uint64, uint64) bool) {
+	for k, v := range w.window {
+		if !f(k, v) {
+			return
+		}
+	}
+}
+
+func (w *latenciesWindow) Count() uint64 {
+	return uint64(len(w.window))
+}
+
+type dashboardSample struct {
+	at    time.Time
+	reqs  uint64
+	bytes int64
+}
+
+func (b *Bombardier) dashboardUpdater() {
+	done := b.Barrier.done()
+	window := newLatenciesWindow(b.latencies)
+	prev := b.dashboardSample()
+	ticker := time.NewTicker(dashboardRefreshRate)
+	defer ticker.Stop()
+	fmt.Fprint(b.out, ansiEnterAltScreen)
+	b.renderDashboard(prev, prev, window)
+	for {
+		select {
+		case <-done:
+			fmt.Fprint(b.out, ansiLeaveAltScreen)
+			b.doneChan <- struct{}{}
+			return
+		case <-ticker.C:
+			cur := b.dashboardSample()
+			window.advance()
+			b.renderDashboard(prev, cur, window)
+			prev = cur
+		}
+	}
+}
+
+func (b *Bombardier) dashboardSample() dashboardSample {
+	return dashboardSample{
+		at:   time.Now(),
+		reqs: b.completedRequests(),
+		bytes: atomic.LoadInt64(&b.bytesRead) +
+			atomic.LoadInt64(&b.bytesWritten),
+	}
+}
+
+func (b *Bombardier) completedRequests() uint64 {
+	return atomic.LoadUint64(&b.req1xx) + atomic.LoadUint64(&b.req2xx) +
+		atomic.LoadUint64(&b.req3xx) + atomic.LoadUint64(&b.req4xx) +
+		atomic.LoadUint64(&b.req5xx) + atomic.LoadUint64(&b.others) +
+		b.grpcRequests() + atomic.LoadUint64(&b.wsMessages) +
+		atomic.LoadUint64(&b.tcpResponses)
+}
+
+func (b *Bombardier) grpcRequests() uint64 {
+	sum := uint64(0)
+	for i := range b.grpcCodes {
+		sum += atomic.LoadUint64(&b.grpcCodes[i])
+	}
+	return sum
+}
+
+func (b *Bombardier) renderDashboard(
+	prev, cur dashboardSample, window *latenciesWindow,
+) {
+	rps, bps := 0.0, 0.0
+	if elapsed := cur.at.Sub(prev.at).Seconds(); elapsed > 0 {
+		rps = float64(cur.reqs-prev.reqs) / elapsed
+		bps = float64(cur.bytes-prev.bytes) / elapsed
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteString(ansiClearScreen)
+	b.printIntroTo(buf)
+	fmt.Fprintf(buf, "  %-12v %9.2f%%\n\n",
+		"Progress", math.Min(b.Barrier.completed(), 1)*100)
+	fmt.Fprintf(buf, "  %-12v %10.2f\n", "Reqs/sec", rps)
+	lr := internal.Results{Latencies: window}
+	if ls := lr.LatenciesStats(dashboardPercentiles); ls != nil {
+		fmt.Fprintf(buf, "  %-12v %10v %10v %10v\n", "Latency",
+			"p50 "+formatTimeUs(float64(ls.Percentiles[0.5])),
+			"p95 "+formatTimeUs(float64(ls.Percentiles[0.95])),
+			"p99 "+formatTimeUs(float64(ls.Percentiles[0.99])))
+	} else {
+		fmt.Fprintf(buf, "  %-12v %10v\n", "Latency", "-")
+	}
+	fmt.Fprintf(buf, "  %-12v %10v/s\n", "Throughput", formatBinary(bps))
+	fmt.Fprintf(buf, "  %-12v %10v\n",
+		"Connections", atomic.LoadInt64(&b.openConns))
+	if b.Conf.isRawTCP() {
+		fmt.Fprintf(buf, "\n  TCP responses:\n"+
+			"    received - %v, others - %v\n",
+			atomic.LoadUint64(&b.tcpResponses),
+			atomic.LoadUint64(&b.others))
+	} else if b.isGRPC() {
+		buf.WriteString("\n  gRPC codes:\n")
+		for i := range b.grpcCodes {
+			if count := atomic.LoadUint64(&b.grpcCodes[i]); count > 0 {
+				fmt.Fprintf(buf, "    %v - %v\n", grpcCodeNames[i], count)
+			}
+		}
+		fmt.Fprintf(buf, "    others - %v\n", atomic.LoadUint64(&b.others))
+	} else {
+		fmt.Fprintf(buf, "\n  HTTP codes:\n"+
+			"    1xx - %v, 2xx - %v, 3xx - %v, 4xx - %v, 5xx - %v\n"+
+			"    others - %v\n",
+			atomic.LoadUint64(&b.req1xx), atomic.LoadUint64(&b.req2xx),
+			atomic.LoadUint64(&b.req3xx), atomic.LoadUint64(&b.req4xx),
+			atomic.LoadUint64(&b.req5xx), atomic.LoadUint64(&b.others))
+	}
+	if ws := b.gatherWebSocketStats(); ws != nil {
+		fmt.Fprintf(buf, "  WebSocket:\n"+
+			"    connects - %v, connect failures - %v, messages - %v\n",
+			ws.Connects, ws.ConnectFailures, ws.Messages)
+	}
+	if errs := b.errors.byFrequency(); len(errs) > 0 {
+		buf.WriteString("  Top errors:\n")
+		for i, ewc := range errs {
+			if i == dashboardTopErrors {
+				break
+			}
+			fmt.Fprintf(buf, "    %10v - %v\n", ewc.error, ewc.count)
+		}
+	}
+	buf.WriteString("\nPress Ctrl+C to stop\n")
+	_, _ = b.out.Write(buf.Bytes())
+}
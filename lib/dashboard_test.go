@@ -0,0 +1,75 @@
+package lib
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	uhist "github.com/codesenberg/concurrent/uint64/histogram"
+)
+
+func TestLatenciesWindow(t *testing.T) {
+	h := uhist.Default()
+	w := newLatenciesWindow(h)
+	h.Increment(10)
+	h.Increment(10)
+	h.Increment(20)
+	w.advance()
+	if w.Get(10) != 2 || w.Get(20) != 1 || w.Count() != 2 {
+		t.Errorf("unexpected window: %v", w.window)
+	}
+	h.Increment(20)
+	h.Increment(30)
+	w.advance()
+	if w.Get(10) != 0 || w.Get(20) != 1 || w.Get(30) != 1 || w.Count() != 2 {
+		t.Errorf("unexpected window: %v", w.window)
+	}
+	w.advance()
+	if w.Count() != 0 {
+		t.Errorf("expected empty window, but got: %v", w.window)
+	}
+}
+
+func TestBombardierDashboard(t *testing.T) {
+	s := httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			rw.WriteHeader(http.StatusOK)
+		}),
+	)
+	defer s.Close()
+	numReqs := uint64(10)
+	b, e := NewBombardier(Config{
+		NumConns:      defaultNumberOfConns,
+		NumReqs:       &numReqs,
+		Url:           s.URL,
+		Headers:       new(HeadersList),
+		Timeout:       defaultTimeout,
+		Method:        "GET",
+		PrintProgress: true,
+		PrintResult:   true,
+		Dashboard:     true,
+		Format:        knownFormat("plain-text"),
+	})
+	if e != nil {
+		t.Error(e)
+		return
+	}
+	out := new(bytes.Buffer)
+	b.redirectOutputTo(out)
+	b.Bombard()
+
+	res := out.String()
+	if !strings.HasPrefix(res, ansiEnterAltScreen) {
+		t.Errorf("dashboard wasn't started: %q", res)
+	}
+	if !strings.HasSuffix(res, ansiLeaveAltScreen) {
+		t.Errorf("dashboard wasn't stopped: %q", res)
+	}
+	for _, expected := range []string{"Reqs/sec", "Connections", "HTTP codes"} {
+		if !strings.Contains(res, expected) {
+			t.Errorf("Expected %q in output: %q", expected, res)
+		}
+	}
+}
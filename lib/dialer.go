@@ -2,13 +2,59 @@ package lib
 
 import (
 	"context"
+	"crypto/tls"
+	"io"
 	"net"
+	"os"
+	"sync"
 	"sync/atomic"
+	"syscall"
+	"time"
 )
 
+// connStats holds numbers of connections opened, reused (that is,
+// requests sent over previously used connections) and closed by the
+// peer.
+type connStats struct {
+	opened, reused, closedByPeer uint64
+}
+
 type countingConn struct {
 	net.Conn
 	bytesRead, bytesWritten *int64
+
+	// openConns is optional and may be nil
+	openConns *int64
+	closeOnce sync.Once
+	closed    chan struct{}
+
+	// stats is optional and may be nil
+	stats       *connStats
+	limits      ConnOptions
+	created     time.Time
+	requests    uint64
+	closedLocal uint32
+	peerClosed  uint32
+}
+
+func newCountingConn(conn net.Conn, opts *clientOpts) *countingConn {
+	if opts.openConns != nil {
+		atomic.AddInt64(opts.openConns, 1)
+	}
+	if opts.connStats != nil {
+		atomic.AddUint64(&opts.connStats.opened, 1)
+	}
+	return &countingConn{
+		Conn:         conn,
+		bytesRead:    opts.bytesRead,
+		bytesWritten: opts.bytesWritten,
+		openConns:    opts.openConns,
+		closed:       make(chan struct{}),
+
+		stats:   opts.connStats,
+		limits:  opts.conns,
+		created: time.Now(),
+	}
 }
 
 func (cc *countingConn) Read(b []byte) (n int, err error) {
@@ -16,6 +62,8 @@ func (cc *countingConn) Read(b []byte) (n int, err error) {
 
 	if err == nil {
 		atomic.AddInt64(cc.bytesRead, int64(n))
+	} else if isPeerClose(err) {
+		cc.closedByPeer()
 	}
 
 	return
@@ -31,41 +79,153 @@ func (cc *countingConn) Write(b []byte) (n int, err error) {
 	return
 }
 
-var fasthttpDialFunc = func(
-	bytesRead, bytesWritten *int64,
-) func(string) (net.Conn, error) {
+func (cc *countingConn) Close() error {
+	cc.closeOnce.Do(func() {
+		atomic.StoreUint32(&cc.closedLocal, 1)
+		if cc.openConns != nil {
+			atomic.AddInt64(cc.openConns, -1)
+		}
+		close(cc.closed)
+	})
+	return cc.Conn.Close()
+}
+
+// countRequest records that a request is about to be sent over the
+// connection and returns the number of requests sent over it so far.
+func (cc *countingConn) countRequest() uint64 {
+	n := atomic.AddUint64(&cc.requests, 1)
+	if n > 1 && cc.stats != nil {
+		atomic.AddUint64(&cc.stats.reused, 1)
+	}
+	return n
+}
+
+// startRequest records that a request is about to be sent over the
+// connection and tells whether it must be the last one, according to
+// limits from ConnOptions.
+func (cc *countingConn) startRequest() (last bool) {
+	n := cc.countRequest()
+	l := cc.limits
+	return (l.MaxRequests > 0 && n >= l.MaxRequests) ||
+		(l.MaxLifetime > 0 && time.Since(cc.created) >= l.MaxLifetime)
+}
+
+func (cc *countingConn) isClosed() bool {
+	return atomic.LoadUint32(&cc.closedLocal) == 1
+}
+
+// closedByPeer records that the peer closed the connection, unless
+// it's already been closed locally.
+func (cc *countingConn) closedByPeer() {
+	if cc.isClosed() {
+		return
+	}
+	cc.closeAnnounced()
+}
+
+// closeAnnounced records that the peer asked to close the connection
+// (i.e. with Connection: close header), which is then usually closed
+// locally.
+func (cc *countingConn) closeAnnounced() {
+	if cc.stats == nil {
+		return
+	}
+	if atomic.CompareAndSwapUint32(&cc.peerClosed, 0, 1) {
+		atomic.AddUint64(&cc.stats.closedByPeer, 1)
+	}
+}
+
+func isPeerClose(err error) bool {
+	if err == io.EOF {
+		return true
+	}
+	if oe, ok := err.(*net.OpError); ok {
+		if se, ok := oe.Err.(*os.SyscallError); ok {
+			return se.Err == syscall.ECONNRESET
+		}
+	}
+	return false
+}
+
+// countingConnOf returns countingConn underlying the connection
+// established by the dial functions below, if any.
+func countingConnOf(conn net.Conn) *countingConn {
+	if tc, ok := conn.(*tls.Conn); ok {
+		conn = tc.NetConn()
+	}
+	if ic, ok := conn.(*impairedConn); ok {
+		return ic.cc
+	}
+	cc, _ := conn.(*countingConn)
+	return cc
+}
+
+// closeOnDone closes the connection as soon as ctx is done, which
+// aborts requests in flight for clients that don't support contexts.
+func closeOnDone(ctx context.Context, cc *countingConn) {
+	select {
+	case <-ctx.Done():
+		_ = cc.Close()
+	case <-cc.closed:
+	}
+}
+
+// dialContextFunc returns function, that dials the given address, or
+// the Unix socket from opts regardless of address, if there is one.
+// Connections are tunneled through the proxy from opts, if any, or
+// made to addresses picked by the resolver from opts, if any, and
+// from local addresses from opts, if any.
+func dialContextFunc(
+	opts *clientOpts,
+) func(context.Context, string, string) (net.Conn, error) {
+	dialer := &net.Dialer{}
+	dial := dialer.DialContext
+	if opts.localAddrs != nil {
+		dial = opts.localAddrs.dialContext(dialer)
+	}
+	if opts.resolver != nil {
+		return opts.resolver.dialContext(dial)
+	}
+	if opts.proxy != nil {
+		return newProxyDialer(opts, dial).dialContext
+	}
+	if opts.unixSocket == "" {
+		return dial
+	}
+	socket := opts.unixSocket
+	return func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return dialer.DialContext(ctx, "unix", socket)
+	}
+}
+
+var fasthttpDialFunc = func(opts *clientOpts) func(string) (net.Conn, error) {
+	ctx := opts.context()
+	dial := dialContextFunc(opts)
 	return func(address string) (net.Conn, error) {
-		conn, err := net.Dial("tcp", address)
+		conn, err := dial(ctx, "tcp", address)
 		if err != nil {
 			return nil, err
 		}
 
-		wrappedConn := &countingConn{
-			Conn:         conn,
-			bytesRead:    bytesRead,
-			bytesWritten: bytesWritten,
-		}
+		wrappedConn := newCountingConn(conn, opts)
+		go closeOnDone(ctx, wrappedConn)
 
-		return wrappedConn, nil
+		return impair(wrappedConn, opts.impairment), nil
 	}
 }
 
 var httpDialContextFunc = func(
-	bytesRead, bytesWritten *int64,
+	opts *clientOpts,
 ) func(context.Context, string, string) (net.Conn, error) {
-	dialer := &net.Dialer{}
+	dial := dialContextFunc(opts)
 	return func(ctx context.Context, network, address string) (net.Conn, error) {
-		conn, err := dialer.DialContext(ctx, network, address)
+		conn, err := dial(ctx, network, address)
 		if err != nil {
 			return nil, err
 		}
 
-		wrappedConn := &countingConn{
-			Conn:         conn,
-			bytesRead:    bytesRead,
-			bytesWritten: bytesWritten,
-		}
+		wrappedConn := newCountingConn(conn, opts)
 
-		return wrappedConn, nil
+		return impair(wrappedConn, opts.impairment), nil
 	}
 }
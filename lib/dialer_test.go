@@ -0,0 +1,273 @@
+package lib
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newConnTestBombardier(
+	t *testing.T, url string, clientType clientTyp, numReqs uint64,
+	conns ConnOptions,
+) *Bombardier {
+	b, e := NewBombardier(Config{
+		NumConns:    1,
+		NumReqs:     &numReqs,
+		Url:         url,
+		Headers:     new(HeadersList),
+		Timeout:     defaultTimeout,
+		Method:      "GET",
+		ClientType:  clientType,
+		Connections: conns,
+		Insecure:    true,
+		Format:      knownFormat("plain-text"),
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	b.disableOutput()
+	return b
+}
+
+func TestBombardierConnectionsReused(t *testing.T) {
+	testAllClients(t, func(clientType clientTyp, t *testing.T) {
+		s := httptest.NewServer(
+			http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}),
+		)
+		defer s.Close()
+		b := newConnTestBombardier(t, s.URL, clientType, 10, ConnOptions{
+			IdleTimeout: time.Minute,
+		})
+		b.Bombard()
+
+		expectConnections(t, b, connStats{1, 9, 0})
+	})
+}
+
+func TestBombardierDisableKeepAlive(t *testing.T) {
+	testAllClients(t, func(clientType clientTyp, t *testing.T) {
+		var closing uint64
+		s := httptest.NewServer(
+			http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				if r.Close {
+					atomic.AddUint64(&closing, 1)
+				}
+			}),
+		)
+		defer s.Close()
+		b := newConnTestBombardier(t, s.URL, clientType, 10, ConnOptions{
+			DisableKeepAlive: true,
+		})
+		b.Bombard()
+
+		if b.req2xx != 10 {
+			t.Errorf("Expected 10 2xx, but got %v, errors: %v",
+				b.req2xx, b.errors.byFrequency())
+		}
+		if closing != 10 {
+			t.Errorf("Expected 10 requests to close connections, "+
+				"but got %v", closing)
+		}
+		expectConnections(t, b, connStats{10, 0, 0})
+	})
+}
+
+func TestBombardierMaxConnRequests(t *testing.T) {
+	for _, clientType := range []clientTyp{fhttp, nhttp1} {
+		for _, useTLS := range []bool{false, true} {
+			name := clientType.String()
+			if useTLS {
+				name += "/tls"
+			}
+			t.Run(name, func(t *testing.T) {
+				testBombardierMaxConnRequests(t, clientType, useTLS)
+			})
+		}
+	}
+}
+
+func testBombardierMaxConnRequests(
+	t *testing.T, clientType clientTyp, useTLS bool,
+) {
+	var closing uint64
+	s := httptest.NewUnstartedServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			if r.Close {
+				atomic.AddUint64(&closing, 1)
+			}
+		}),
+	)
+	if useTLS {
+		s.StartTLS()
+	} else {
+		s.Start()
+	}
+	defer s.Close()
+	b := newConnTestBombardier(t, s.URL, clientType, 9, ConnOptions{
+		MaxRequests: 3,
+	})
+	b.Bombard()
+
+	if b.req2xx != 9 {
+		t.Errorf("Expected 9 2xx, but got %v, errors: %v",
+			b.req2xx, b.errors.byFrequency())
+	}
+	if closing != 3 {
+		t.Errorf("Expected last requests over 3 connections "+
+			"to close them, but got %v", closing)
+	}
+	expectConnections(t, b, connStats{3, 6, 0})
+}
+
+func TestBombardierMaxConnLifetime(t *testing.T) {
+	for _, clientType := range []clientTyp{fhttp, nhttp1} {
+		t.Run(clientType.String(), func(t *testing.T) {
+			s := httptest.NewServer(
+				http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+					time.Sleep(20 * time.Millisecond)
+				}),
+			)
+			defer s.Close()
+			b := newConnTestBombardier(t, s.URL, clientType, 6, ConnOptions{
+				MaxLifetime: time.Nanosecond,
+			})
+			b.Bombard()
+
+			if b.req2xx != 6 {
+				t.Errorf("Expected 6 2xx, but got %v, errors: %v",
+					b.req2xx, b.errors.byFrequency())
+			}
+			expectConnections(t, b, connStats{6, 0, 0})
+		})
+	}
+}
+
+func TestBombardierConnIdleTimeout(t *testing.T) {
+	testAllClients(t, func(clientType clientTyp, t *testing.T) {
+		s := httptest.NewServer(
+			http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}),
+		)
+		defer s.Close()
+		rate := uint64(10)
+		numReqs := uint64(3)
+		b, e := NewBombardier(Config{
+			NumConns:   1,
+			NumReqs:    &numReqs,
+			Rate:       &rate,
+			Url:        s.URL,
+			Headers:    new(HeadersList),
+			Timeout:    defaultTimeout,
+			Method:     "GET",
+			ClientType: clientType,
+			Connections: ConnOptions{
+				IdleTimeout: 10 * time.Millisecond,
+			},
+			Format: knownFormat("plain-text"),
+		})
+		if e != nil {
+			t.Fatal(e)
+		}
+		b.disableOutput()
+		b.Bombard()
+
+		stats := b.gatherConnectionStats()
+		if stats.Opened < 2 || stats.ClosedByPeer != 0 {
+			t.Errorf("Expected idle connections to be closed, but got %+v",
+				*stats)
+		}
+	})
+}
+
+func TestBombardierConnectionsClosedByPeer(t *testing.T) {
+	testAllClients(t, func(clientType clientTyp, t *testing.T) {
+		s := httptest.NewServer(
+			http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				rw.Header().Set("Connection", "close")
+			}),
+		)
+		defer s.Close()
+		b := newConnTestBombardier(t, s.URL, clientType, 5, ConnOptions{
+			IdleTimeout: time.Minute,
+		})
+		b.Bombard()
+
+		if b.req2xx != 5 {
+			t.Errorf("Expected 5 2xx, but got %v, errors: %v",
+				b.req2xx, b.errors.byFrequency())
+		}
+		expectConnections(t, b, connStats{5, 0, 5})
+	})
+}
+
+func TestBombardierIdleConnectionsClosedByPeer(t *testing.T) {
+	testAllClients(t, func(clientType clientTyp, t *testing.T) {
+		s := httptest.NewUnstartedServer(
+			http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}),
+		)
+		s.Config.IdleTimeout = 10 * time.Millisecond
+		s.Start()
+		defer s.Close()
+		rate := uint64(10)
+		numReqs := uint64(3)
+		b, e := NewBombardier(Config{
+			NumConns:   1,
+			NumReqs:    &numReqs,
+			Rate:       &rate,
+			Url:        s.URL,
+			Headers:    new(HeadersList),
+			Timeout:    defaultTimeout,
+			Method:     "GET",
+			ClientType: clientType,
+			Connections: ConnOptions{
+				IdleTimeout: time.Minute,
+			},
+			Format: knownFormat("plain-text"),
+		})
+		if e != nil {
+			t.Fatal(e)
+		}
+		b.disableOutput()
+		b.Bombard()
+
+		if b.req2xx != numReqs {
+			t.Errorf("Expected %v 2xx, but got %v, errors: %v",
+				numReqs, b.req2xx, b.errors.byFrequency())
+		}
+		stats := b.gatherConnectionStats()
+		if stats.Opened < 2 || stats.ClosedByPeer == 0 {
+			t.Errorf("Expected idle connections to be closed by server, "+
+				"but got %+v", *stats)
+		}
+	})
+}
+
+func TestBombardierReportsConnectionsOnlyWithOptions(t *testing.T) {
+	s := httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}),
+	)
+	defer s.Close()
+	for _, conns := range []ConnOptions{{}, {MaxRequests: 2}} {
+		b := newConnTestBombardier(t, s.URL, fhttp, 2, conns)
+		b.Bombard()
+
+		reported := b.gatherInfo().Result.Connections != nil
+		if reported != conns.isSet() {
+			t.Errorf("%+v: expected connections to be reported - %v, "+
+				"but got %v", conns, conns.isSet(), reported)
+		}
+	}
+}
+
+func expectConnections(t *testing.T, b *Bombardier, exp connStats) {
+	t.Helper()
+	act := b.gatherConnectionStats()
+	if act == nil {
+		t.Fatal("Expected connection stats to be gathered")
+	}
+	if act.Opened != exp.opened || act.Reused != exp.reused ||
+		act.ClosedByPeer != exp.closedByPeer {
+		t.Errorf("Expected %+v, but got %+v", exp, *act)
+	}
+}
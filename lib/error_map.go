@@ -43,7 +43,7 @@ func (e *errorMap) get(err error) uint64 {
 	if c == nil {
 		return uint64(0)
 	}
-	return *c
+	return atomic.LoadUint64(c)
 }
 
 func (e *errorMap) sum() uint64 {
@@ -51,7 +51,7 @@ func (e *errorMap) sum() uint64 {
 	defer e.mu.RUnlock()
 	sum := uint64(0)
 	for _, v := range e.m {
-		sum += *v
+		sum += atomic.LoadUint64(v)
 	}
 	return sum
 }
@@ -84,7 +84,9 @@ func (e *errorMap) byFrequency() errorsByFrequency {
 	e.mu.RLock()
 	byFreq := make(errorsByFrequency, 0, len(e.m))
 	for err, count := range e.m {
-		byFreq = append(byFreq, &errorWithCount{err, *count})
+		byFreq = append(byFreq, &errorWithCount{
+			err, atomic.LoadUint64(count),
+		})
 	}
 	e.mu.RUnlock()
 	sort.Sort(byFreq)
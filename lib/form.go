@@ -0,0 +1,175 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FormField is a field of the form sent as request body. Value is the
+// path of the file to upload, if File is true.
+type FormField struct {
+	Name, Value string
+	File        bool
+}
+
+// FormFields is the list of fields of the form.
+type FormFields []FormField
+
+// formFieldsValue is the value of --form and --form-file flags, that
+// both append to the same list of fields.
+type formFieldsValue struct {
+	fields *FormFields
+	file   bool
+}
+
+func (v formFieldsValue) String() string {
+	return fmt.Sprint(*v.fields)
+}
+
+func (v formFieldsValue) IsCumulative() bool {
+	return true
+}
+
+func (v formFieldsValue) Set(value string) error {
+	res := strings.SplitN(value, "=", 2)
+	if len(res) != 2 || res[0] == "" {
+		return errInvalidFormField
+	}
+	field := FormField{Name: res[0], Value: res[1], File: v.file}
+	if field.File {
+		field.Value = strings.TrimPrefix(field.Value, "@")
+	}
+	*v.fields = append(*v.fields, field)
+	return nil
+}
+
+// formBody is the body built from form fields. Multipart bodies are
+// kept as segments, that are either static or contents of files, so
+// that files could be streamed instead of being read into memory.
+type formBody struct {
+	contentType string
+	segments    []formSegment
+}
+
+type formSegment struct {
+	data []byte
+	// path is the file to read, if not empty
+	path string
+}
+
+func newFormBody(fields FormFields) (*formBody, error) {
+	multipartForm := false
+	for _, f := range fields {
+		multipartForm = multipartForm || f.File
+	}
+	if !multipartForm {
+		parts := make([]string, len(fields))
+		for i, f := range fields {
+			parts[i] = url.QueryEscape(f.Name) + "=" + url.QueryEscape(f.Value)
+		}
+		return &formBody{
+			contentType: "application/x-www-form-urlencoded",
+			segments: []formSegment{
+				{data: []byte(strings.Join(parts, "&"))},
+			},
+		}, nil
+	}
+
+	fb := new(formBody)
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	flush := func() {
+		data := append([]byte(nil), buf.Bytes()...)
+		fb.segments = append(fb.segments, formSegment{data: data})
+		buf.Reset()
+	}
+	for _, f := range fields {
+		if !f.File {
+			if err := w.WriteField(f.Name, f.Value); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if _, err := os.Stat(f.Value); err != nil {
+			return nil, err
+		}
+		_, err := w.CreateFormFile(f.Name, filepath.Base(f.Value))
+		if err != nil {
+			return nil, err
+		}
+		flush()
+		fb.segments = append(fb.segments, formSegment{path: f.Value})
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	flush()
+	fb.contentType = w.FormDataContentType()
+	return fb, nil
+}
+
+// bytes reads the whole body into memory.
+func (fb *formBody) bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	for _, s := range fb.segments {
+		if s.path == "" {
+			buf.Write(s.data)
+			continue
+		}
+		data, err := ioutil.ReadFile(s.path)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(data)
+	}
+	return buf.Bytes(), nil
+}
+
+// stream is bodyStreamProducer, that reads files as the body is sent.
+func (fb *formBody) stream() (io.ReadCloser, error) {
+	readers := make([]io.Reader, 0, len(fb.segments))
+	var files multiCloser
+	for _, s := range fb.segments {
+		if s.path == "" {
+			readers = append(readers, bytes.NewReader(s.data))
+			continue
+		}
+		f, err := os.Open(s.path)
+		if err != nil {
+			_ = files.Close()
+			return nil, err
+		}
+		files = append(files, f)
+		readers = append(readers, f)
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(readers...), files}, nil
+}
+
+type multiCloser []io.Closer
+
+func (mc multiCloser) Close() error {
+	var err error
+	for _, c := range mc {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// withHeader returns copy of the list with the header appended.
+func withHeader(h *HeadersList, key, value string) *HeadersList {
+	res := append(HeadersList{}, *h...)
+	res = append(res, header{key, value})
+	return &res
+}
@@ -0,0 +1,164 @@
+package lib
+
+import (
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestFormFieldsValue(t *testing.T) {
+	var fields FormFields
+	values := []struct {
+		value formFieldsValue
+		in    string
+		err   error
+	}{
+		{formFieldsValue{&fields, false}, "key=a=b c", nil},
+		{formFieldsValue{&fields, false}, "empty=", nil},
+		{formFieldsValue{&fields, true}, "upload=@testbody.txt", nil},
+		{formFieldsValue{&fields, true}, "raw=testbody.txt", nil},
+		{formFieldsValue{&fields, false}, "novalue", errInvalidFormField},
+		{formFieldsValue{&fields, true}, "=@testbody.txt", errInvalidFormField},
+	}
+	for _, v := range values {
+		if err := v.value.Set(v.in); err != v.err {
+			t.Errorf("%q: expected %v, but got %v", v.in, v.err, err)
+		}
+	}
+	exp := FormFields{
+		{"key", "a=b c", false},
+		{"empty", "", false},
+		{"upload", "testbody.txt", true},
+		{"raw", "testbody.txt", true},
+	}
+	if !reflect.DeepEqual(fields, exp) {
+		t.Errorf("Expected %v, but got %v", exp, fields)
+	}
+}
+
+func newFormTestBombardier(
+	t *testing.T, url string, clientType clientTyp, form FormFields,
+	stream bool,
+) *Bombardier {
+	numReqs := uint64(3)
+	b, e := NewBombardier(Config{
+		NumConns:   1,
+		NumReqs:    &numReqs,
+		Url:        url,
+		Headers:    new(HeadersList),
+		Timeout:    defaultTimeout,
+		Method:     "POST",
+		Form:       &form,
+		Stream:     stream,
+		ClientType: clientType,
+		Format:     knownFormat("plain-text"),
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	b.disableOutput()
+	return b
+}
+
+func TestBombardierSendsURLEncodedForm(t *testing.T) {
+	testAllClients(t, func(clientType clientTyp, t *testing.T) {
+		s := httptest.NewServer(
+			http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				ct := r.Header.Get("Content-Type")
+				if ct != "application/x-www-form-urlencoded" ||
+					r.ParseForm() != nil ||
+					r.PostForm.Get("name") != "bomb & ardier" ||
+					r.PostForm.Get("empty") != "" {
+					rw.WriteHeader(http.StatusBadRequest)
+				}
+			}),
+		)
+		defer s.Close()
+		b := newFormTestBombardier(t, s.URL, clientType, FormFields{
+			{Name: "name", Value: "bomb & ardier"},
+			{Name: "empty"},
+		}, false)
+		b.Bombard()
+
+		if b.req2xx != 3 {
+			t.Errorf("Expected 3 2xx, but got %v 2xx, %v 4xx, errors: %v",
+				b.req2xx, b.req4xx, b.errors.byFrequency())
+		}
+	})
+}
+
+func TestBombardierSendsMultipartForm(t *testing.T) {
+	for _, stream := range []bool{false, true} {
+		name := "in-memory"
+		if stream {
+			name = "streamed"
+		}
+		t.Run(name, func(t *testing.T) {
+			testAllClients(t, func(clientType clientTyp, t *testing.T) {
+				testBombardierSendsMultipartForm(t, clientType, stream)
+			})
+		})
+	}
+}
+
+func testBombardierSendsMultipartForm(
+	t *testing.T, clientType clientTyp, stream bool,
+) {
+	content, err := ioutil.ReadFile("testbody.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if mt != "multipart/form-data" ||
+				r.ParseMultipartForm(1<<20) != nil ||
+				r.FormValue("name") != "bombardier" {
+				rw.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			f, fh, err := r.FormFile("upload")
+			if err != nil || fh.Filename != "testbody.txt" {
+				rw.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			defer f.Close()
+			uploaded, err := ioutil.ReadAll(f)
+			if err != nil || string(uploaded) != string(content) {
+				rw.WriteHeader(http.StatusBadRequest)
+			}
+		}),
+	)
+	defer s.Close()
+	b := newFormTestBombardier(t, s.URL, clientType, FormFields{
+		{Name: "name", Value: "bombardier"},
+		{Name: "upload", Value: "testbody.txt", File: true},
+	}, stream)
+	b.Bombard()
+
+	if b.req2xx != 3 {
+		t.Errorf("Expected 3 2xx, but got %v 2xx, %v 4xx, errors: %v",
+			b.req2xx, b.req4xx, b.errors.byFrequency())
+	}
+}
+
+func TestBombardierFormFileMustExist(t *testing.T) {
+	numReqs := uint64(1)
+	_, e := NewBombardier(Config{
+		NumConns:   1,
+		NumReqs:    &numReqs,
+		Url:        "http://localhost:8080",
+		Headers:    new(HeadersList),
+		Timeout:    defaultTimeout,
+		Method:     "POST",
+		Form:       &FormFields{{Name: "f", Value: "nonexistent", File: true}},
+		ClientType: fhttp,
+		Format:     knownFormat("plain-text"),
+	})
+	if e == nil {
+		t.Error("Expected missing file to be reported")
+	}
+}
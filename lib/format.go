@@ -2,6 +2,7 @@ package lib
 
 import (
 	"fmt"
+	"strings"
 )
 
 type units struct {
@@ -53,3 +54,21 @@ func formatTimeUs(n float64) string {
 	}
 	return formatUnits(n, units, 2)
 }
+
+var markdownReplacer = strings.NewReplacer(
+	"\\", "\\\\",
+	"|", "\\|",
+	"\r\n", " ",
+	"\n", " ",
+)
+
+func escapeMarkdown(s string) string {
+	return markdownReplacer.Replace(s)
+}
+
+func percentChange(old, new float64) string {
+	if old == 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%+.2f%%", (new-old)/old*100)
+}
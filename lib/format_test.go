@@ -63,3 +63,43 @@ func TestShouldFormatUs(t *testing.T) {
 		}
 	}
 }
+
+func TestShouldEscapeMarkdown(t *testing.T) {
+	expectations := []struct {
+		in  string
+		out string
+	}{
+		{"", ""},
+		{"plain error", "plain error"},
+		{"a|b", "a\\|b"},
+		{"back\\slash", "back\\\\slash"},
+		{"multi\nline\r\nerror", "multi line error"},
+	}
+	for _, e := range expectations {
+		actual := escapeMarkdown(e.in)
+		expected := e.out
+		if expected != actual {
+			t.Errorf("Expected %q, but got %q", expected, actual)
+		}
+	}
+}
+
+func TestShouldFormatPercentChange(t *testing.T) {
+	expectations := []struct {
+		old, new float64
+		out      string
+	}{
+		{0, 10, "n/a"},
+		{10, 10, "+0.00%"},
+		{10, 15, "+50.00%"},
+		{10, 5, "-50.00%"},
+		{3, 4, "+33.33%"},
+	}
+	for _, e := range expectations {
+		actual := percentChange(e.old, e.new)
+		expected := e.out
+		if expected != actual {
+			t.Errorf("Expected \"%v\", but got \"%v\"", expected, actual)
+		}
+	}
+}
@@ -0,0 +1,336 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goware/urlx"
+	"golang.org/x/net/http2"
+)
+
+const grpcFrameHeaderLen = 5
+
+// Codes are listed in the order of their numeric values.
+var grpcCodeNames = [...]string{
+	"OK",
+	"CANCELLED",
+	"UNKNOWN",
+	"INVALID_ARGUMENT",
+	"DEADLINE_EXCEEDED",
+	"NOT_FOUND",
+	"ALREADY_EXISTS",
+	"PERMISSION_DENIED",
+	"RESOURCE_EXHAUSTED",
+	"FAILED_PRECONDITION",
+	"ABORTED",
+	"OUT_OF_RANGE",
+	"UNIMPLEMENTED",
+	"INTERNAL",
+	"UNAVAILABLE",
+	"DATA_LOSS",
+	"UNAUTHENTICATED",
+}
+
+const (
+	grpcOK            = 0
+	grpcUnknown       = 2
+	grpcPermDenied    = 7
+	grpcUnimplemented = 12
+	grpcInternal      = 13
+	grpcUnavailable   = 14
+	grpcUnauthn       = 16
+)
+
+// grpcClient performs unary and server-streaming gRPC calls. Request
+// message is encoded from JSON using descriptors of the method, that
+// are read from protoset or fetched using server reflection, while
+// responses are only split into messages, but not decoded.
+type grpcClient struct {
+	client *http.Client
+	ctx    context.Context
+
+	headers http.Header
+	url     *url.URL
+
+	// frame is the request message prefixed with gRPC frame
+	// header, nil if the message is streamed
+	frame   []byte
+	bodProd bodyStreamProducer
+	// encode converts streamed JSON messages to protobuf
+	encode func(msg []byte) ([]byte, error)
+	// maxMsgSize is the size of the largest response message
+	maxMsgSize uint64
+}
+
+func newGRPCClient(opts *clientOpts) (client, error) {
+	c := new(grpcClient)
+	c.ctx = opts.context()
+	c.maxMsgSize = opts.grpcMaxMsgSize
+	var err error
+	c.url, err = urlx.Parse(opts.url)
+	if err != nil {
+		// opts.url guaranteed to be valid at this point
+		panic(err)
+	}
+	c.client = &http.Client{
+		Transport: c.transport(httpDialContextFunc(opts), opts.tlsConfig),
+		Timeout:   opts.timeout,
+	}
+
+	c.headers = headersToHTTPHeaders(opts.headers)
+	c.headers.Set("Content-Type", "application/grpc")
+	c.headers.Set("TE", "trailers")
+	if err = c.prepareMessage(opts); err != nil {
+		return nil, err
+	}
+	return client(c), nil
+}
+
+func (c *grpcClient) transport(
+	dial func(context.Context, string, string) (net.Conn, error),
+	tlsConfig *tls.Config,
+) *http2.Transport {
+	plaintext := c.url.Scheme == "http"
+	return &http2.Transport{
+		TLSClientConfig: tlsConfig,
+		// Cleartext HTTP/2 with prior knowledge, as gRPC does it
+		AllowHTTP: true,
+		DialTLS: func(
+			network, addr string, cfg *tls.Config,
+		) (net.Conn, error) {
+			conn, err := dial(c.ctx, network, addr)
+			if err != nil || plaintext {
+				return conn, err
+			}
+			tlsConn := tls.Client(conn, cfg)
+			if err := tlsConn.Handshake(); err != nil {
+				_ = conn.Close()
+				return nil, err
+			}
+			return tlsConn, nil
+		},
+	}
+}
+
+// prepareMessage looks up the type of request message and encodes
+// the body, unless it's streamed. Empty message needs no lookup,
+// since it's the same for any type.
+func (c *grpcClient) prepareMessage(opts *clientOpts) error {
+	body := ""
+	if opts.body != nil {
+		body = *opts.body
+	}
+	if opts.grpcProtoset == "" && opts.bodProd == nil &&
+		strings.TrimSpace(body) == "" {
+		c.frame = grpcFrame(nil)
+		return nil
+	}
+	method := strings.TrimPrefix(c.url.Path, "/")
+	var (
+		d   *descriptors
+		err error
+	)
+	if opts.grpcProtoset != "" {
+		d, err = readProtoset(opts.grpcProtoset)
+	} else {
+		d, err = c.reflect(opts, grpcServiceOf(method))
+	}
+	if err != nil {
+		return err
+	}
+	m, err := d.method(method)
+	if err != nil {
+		return err
+	}
+	c.encode = func(msg []byte) ([]byte, error) {
+		return d.encodeJSON(m.Input(), msg)
+	}
+	if opts.bodProd != nil {
+		c.bodProd = opts.bodProd
+		return nil
+	}
+	msg, err := c.encode([]byte(body))
+	if err != nil {
+		return err
+	}
+	c.frame = grpcFrame(msg)
+	return nil
+}
+
+// grpcServiceOf returns the service of the method, i.e.
+// package.Service for package.Service/Method.
+func grpcServiceOf(method string) string {
+	if i := strings.IndexByte(method, '/'); i >= 0 {
+		return method[:i]
+	}
+	return method
+}
+
+func grpcFrame(msg []byte) []byte {
+	frame := make([]byte, grpcFrameHeaderLen+len(msg))
+	binary.BigEndian.PutUint32(frame[1:grpcFrameHeaderLen], uint32(len(msg)))
+	copy(frame[grpcFrameHeaderLen:], msg)
+	return frame
+}
+
+func (c *grpcClient) do() (
+	code int, msTaken uint64, err error,
+) {
+	frame := c.frame
+	if frame == nil {
+		bs, bserr := c.bodProd()
+		if bserr != nil {
+			return 0, 0, bserr
+		}
+		msg, rerr := ioutil.ReadAll(bs)
+		_ = bs.Close()
+		if rerr != nil {
+			return 0, 0, rerr
+		}
+		if msg, rerr = c.encode(msg); rerr != nil {
+			return 0, 0, rerr
+		}
+		frame = grpcFrame(msg)
+	}
+	req := &http.Request{
+		Method:        "POST",
+		URL:           c.url,
+		Host:          c.url.Host,
+		Header:        c.headers,
+		Body:          ioutil.NopCloser(bytes.NewReader(frame)),
+		ContentLength: int64(len(frame)),
+	}
+
+	start := time.Now()
+	resp, err := c.client.Do(req.WithContext(c.ctx))
+	if err != nil {
+		code = -1
+	} else {
+		code, err = readGRPCResponse(resp, c.maxMsgSize, nil)
+	}
+	msTaken = uint64(time.Since(start).Nanoseconds() / 1000)
+
+	return
+}
+
+// readGRPCResponse reads status of the call, appending messages of
+// the response to msgs, unless it's nil. Messages larger than
+// maxSize are errors.
+func readGRPCResponse(
+	resp *http.Response, maxSize uint64, msgs *[][]byte,
+) (code int, err error) {
+	defer func() {
+		if cerr := resp.Body.Close(); cerr != nil && err == nil {
+			code, err = -1, cerr
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		return grpcCodeFromHTTPStatus(resp.StatusCode), nil
+	}
+	if msgs == nil {
+		err = skipGRPCMessages(resp.Body, maxSize)
+	} else {
+		*msgs, err = readGRPCMessages(resp.Body, maxSize)
+	}
+	if err != nil {
+		return -1, err
+	}
+	status := resp.Trailer.Get("Grpc-Status")
+	if status == "" {
+		// Trailers-Only response
+		status = resp.Header.Get("Grpc-Status")
+	}
+	if status == "" {
+		return -1, errNoGRPCStatus
+	}
+	code, err = strconv.Atoi(status)
+	if err != nil {
+		return -1, errInvalidGRPCStatus
+	}
+	return code, nil
+}
+
+// skipGRPCMessages reads all the messages from the response.
+func skipGRPCMessages(r io.Reader, maxSize uint64) error {
+	header := make([]byte, grpcFrameHeaderLen)
+	for {
+		length, err := readGRPCFrameHeader(r, header, maxSize)
+		if err != nil {
+			return err
+		}
+		if length < 0 {
+			return nil
+		}
+		if _, err = io.CopyN(ioutil.Discard, r, length); err != nil {
+			return err
+		}
+	}
+}
+
+func readGRPCMessages(r io.Reader, maxSize uint64) ([][]byte, error) {
+	var msgs [][]byte
+	header := make([]byte, grpcFrameHeaderLen)
+	for {
+		length, err := readGRPCFrameHeader(r, header, maxSize)
+		if err != nil {
+			return nil, err
+		}
+		if length < 0 {
+			return msgs, nil
+		}
+		msg := make([]byte, length)
+		if _, err = io.ReadFull(r, msg); err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, msg)
+	}
+}
+
+// readGRPCFrameHeader reads header of the next message into buf and
+// returns length of the message, or -1 if there are no more messages.
+func readGRPCFrameHeader(
+	r io.Reader, buf []byte, maxSize uint64,
+) (int64, error) {
+	_, err := io.ReadFull(r, buf)
+	if err == io.EOF {
+		return -1, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	length := binary.BigEndian.Uint32(buf[1:])
+	if uint64(length) > maxSize {
+		return 0, errGRPCMessageTooLarge
+	}
+	return int64(length), nil
+}
+
+// grpcCodeFromHTTPStatus maps HTTP status codes to gRPC codes, as
+// described in gRPC's documentation (doc/http-grpc-status-mapping.md).
+func grpcCodeFromHTTPStatus(status int) int {
+	switch status {
+	case http.StatusBadRequest:
+		return grpcInternal
+	case http.StatusUnauthorized:
+		return grpcUnauthn
+	case http.StatusForbidden:
+		return grpcPermDenied
+	case http.StatusNotFound:
+		return grpcUnimplemented
+	case http.StatusTooManyRequests, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return grpcUnavailable
+	}
+	return grpcUnknown
+}
@@ -0,0 +1,385 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func newGRPCTestServer(
+	t *testing.T,
+	handler func(method string, msg []byte) (reply []byte, code int),
+) *httptest.Server {
+	s := httptest.NewUnstartedServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			if r.ProtoMajor != 2 {
+				t.Errorf("Expected HTTP/2, but got %v", r.Proto)
+			}
+			if r.Method != "POST" {
+				t.Errorf("Expected POST, but got %v", r.Method)
+			}
+			if ct := r.Header.Get("Content-Type"); ct != "application/grpc" {
+				t.Errorf("Expected application/grpc, but got %v", ct)
+			}
+			frame, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if len(frame) < grpcFrameHeaderLen {
+				t.Errorf("Frame is too short: %v", frame)
+				return
+			}
+			reply, code := handler(r.URL.Path, frame[grpcFrameHeaderLen:])
+			rw.Header().Set("Content-Type", "application/grpc")
+			if reply != nil {
+				_, _ = rw.Write(grpcFrame(reply))
+			}
+			rw.Header().Set(
+				http.TrailerPrefix+"Grpc-Status", strconv.Itoa(code),
+			)
+		}),
+	)
+	s.EnableHTTP2 = true
+	s.StartTLS()
+	return s
+}
+
+func TestGRPCFrame(t *testing.T) {
+	msg := []byte("abracadabra")
+	frame := grpcFrame(msg)
+	if frame[0] != 0 {
+		t.Errorf("Expected uncompressed frame, but got flag %v", frame[0])
+	}
+	if l := binary.BigEndian.Uint32(frame[1:5]); l != uint32(len(msg)) {
+		t.Errorf("Expected length %v, but got %v", len(msg), l)
+	}
+	if !bytes.Equal(frame[grpcFrameHeaderLen:], msg) {
+		t.Errorf("Expected %q, but got %q", msg, frame[grpcFrameHeaderLen:])
+	}
+}
+
+func TestSkipGRPCMessages(t *testing.T) {
+	buf := new(bytes.Buffer)
+	buf.Write(grpcFrame([]byte("first")))
+	buf.Write(grpcFrame(nil))
+	buf.Write(grpcFrame([]byte("second")))
+	if err := skipGRPCMessages(buf, 6); err != nil {
+		t.Error(err)
+	}
+	truncated := grpcFrame([]byte("truncated"))
+	err := skipGRPCMessages(bytes.NewReader(truncated[:8]), 9)
+	if err == nil {
+		t.Error("Expected an error for truncated message")
+	}
+	err = skipGRPCMessages(bytes.NewReader(grpcFrame([]byte("long"))), 3)
+	if err != errGRPCMessageTooLarge {
+		t.Errorf("Expected %v, but got %v", errGRPCMessageTooLarge, err)
+	}
+}
+
+func TestReadGRPCMessages(t *testing.T) {
+	buf := new(bytes.Buffer)
+	buf.Write(grpcFrame([]byte("first")))
+	buf.Write(grpcFrame([]byte("second")))
+	msgs, err := readGRPCMessages(buf, 6)
+	exp := [][]byte{[]byte("first"), []byte("second")}
+	if err != nil || !reflect.DeepEqual(msgs, exp) {
+		t.Errorf("Expected %q, but got (%q, %v)", exp, msgs, err)
+	}
+	// Length of the message is checked before it's read, so that
+	// a huge length in the header doesn't get allocated.
+	header := []byte{0, 0xff, 0xff, 0xff, 0xff}
+	_, err = readGRPCMessages(bytes.NewReader(header), defaultGRPCMaxMsgSize)
+	if err != errGRPCMessageTooLarge {
+		t.Errorf("Expected %v, but got %v", errGRPCMessageTooLarge, err)
+	}
+}
+
+func TestGRPCCodeFromHTTPStatus(t *testing.T) {
+	expectations := []struct {
+		in  int
+		out int
+	}{
+		{http.StatusBadRequest, grpcInternal},
+		{http.StatusUnauthorized, grpcUnauthn},
+		{http.StatusForbidden, grpcPermDenied},
+		{http.StatusNotFound, grpcUnimplemented},
+		{http.StatusTooManyRequests, grpcUnavailable},
+		{http.StatusBadGateway, grpcUnavailable},
+		{http.StatusServiceUnavailable, grpcUnavailable},
+		{http.StatusGatewayTimeout, grpcUnavailable},
+		{http.StatusTeapot, grpcUnknown},
+	}
+	for _, exp := range expectations {
+		if act := grpcCodeFromHTTPStatus(exp.in); act != exp.out {
+			t.Errorf("%v: expected %v, but got %v", exp.in, exp.out, act)
+		}
+	}
+}
+
+func TestBombardierGRPCCodeRecording(t *testing.T) {
+	request := []byte{0x0a, 0x01, 'x', 0x10, 0x96, 0x01}
+	s := newGRPCTestServer(t, func(_ string, msg []byte) ([]byte, int) {
+		if !bytes.Equal(msg, request) {
+			t.Errorf("Expected %v, but got %v", request, msg)
+		}
+		return []byte("reply"), 0
+	})
+	defer s.Close()
+	protoset := writeTestProtoset(t)
+	defer func() {
+		_ = os.Remove(protoset)
+	}()
+	numReqs := uint64(10)
+	b, e := NewBombardier(Config{
+		NumConns:     defaultNumberOfConns,
+		NumReqs:      &numReqs,
+		Url:          s.URL + "/test.Service/Method",
+		Headers:      new(HeadersList),
+		Timeout:      defaultTimeout,
+		Method:       "POST",
+		Body:         `{"name": "x", "count": 150}`,
+		ClientType:   grpc,
+		GRPCProtoset: protoset,
+		Format:       knownFormat("plain-text"),
+		Insecure:     true,
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	b.disableOutput()
+	b.Bombard()
+
+	codes := b.gatherInfo().Result.GRPCCodes
+	if codes["OK"] != numReqs {
+		t.Errorf("Expected %v OK responses, but got %v", numReqs, codes)
+	}
+	if b.others != 0 || b.req2xx != 0 {
+		t.Errorf("Expected no HTTP codes, but got 2xx - %v, others - %v",
+			b.req2xx, b.others)
+	}
+	if len(b.errors.byFrequency()) > 0 {
+		t.Error(b.errors.byFrequency())
+	}
+}
+
+func TestBombardierGRPCErrorStatus(t *testing.T) {
+	s := newGRPCTestServer(t, func(string, []byte) ([]byte, int) {
+		return nil, grpcUnavailable
+	})
+	defer s.Close()
+	numReqs := uint64(5)
+	b, e := NewBombardier(Config{
+		NumConns:   defaultNumberOfConns,
+		NumReqs:    &numReqs,
+		Url:        s.URL + "/test.Service/Method",
+		Headers:    new(HeadersList),
+		Timeout:    5 * time.Second,
+		Method:     "POST",
+		ClientType: grpc,
+		Format:     knownFormat("json"),
+		Insecure:   true,
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	out := new(bytes.Buffer)
+	b.redirectOutputTo(out)
+	b.Conf.PrintProgress = false
+	b.Bombard()
+	b.PrintStats()
+
+	codes := b.gatherInfo().Result.GRPCCodes
+	if codes["UNAVAILABLE"] != numReqs {
+		t.Errorf("Expected %v UNAVAILABLE responses, but got %v",
+			numReqs, codes)
+	}
+	exp := `"grpcCodes":{"UNAVAILABLE":5}`
+	if !bytes.Contains(out.Bytes(), []byte(exp)) {
+		t.Errorf("Expected %v in output, but got %v", exp, out.String())
+	}
+}
+
+// serveReflection implements v1alpha server reflection of test.proto,
+// while v1 is reported as unimplemented.
+func serveReflection(method string, msg []byte) ([]byte, int, bool) {
+	switch method {
+	case grpcReflectionMethods[0]:
+		return nil, grpcUnimplemented, true
+	case grpcReflectionMethods[1]:
+	default:
+		return nil, 0, false
+	}
+	var file *descriptorpb.FileDescriptorProto
+	_ = forEachProtoField(msg, func(
+		n protowire.Number, _ uint64, b []byte,
+	) error {
+		switch {
+		case n == reflectionFileBySymbol && string(b) == "test.Service":
+			file = testProtoFile()
+		case n == reflectionFileByName &&
+			string(b) == "google/protobuf/timestamp.proto":
+			file = testTimestampFile()
+		}
+		return nil
+	})
+	if file == nil {
+		return protoMsg(7, protoMsg(1, 5, 2, "not found")), grpcOK, true
+	}
+	data, err := proto.Marshal(file)
+	if err != nil {
+		panic(err)
+	}
+	return protoMsg(4, protoMsg(1, data)), grpcOK, true
+}
+
+func TestBombardierGRPCReflection(t *testing.T) {
+	request := []byte{0x0a, 0x01, 'x', 0x30, 0x01}
+	calls := uint64(0)
+	s := newGRPCTestServer(t, func(method string, msg []byte) ([]byte, int) {
+		if reply, code, ok := serveReflection(method, msg); ok {
+			return reply, code
+		}
+		if method != "/test.Service/Method" {
+			return nil, grpcUnimplemented
+		}
+		atomic.AddUint64(&calls, 1)
+		if !bytes.Equal(msg, request) {
+			t.Errorf("Expected %v, but got %v", request, msg)
+		}
+		return nil, grpcOK
+	})
+	defer s.Close()
+	numReqs := uint64(10)
+	b, e := NewBombardier(Config{
+		NumConns:   defaultNumberOfConns,
+		NumReqs:    &numReqs,
+		Url:        s.URL + "/test.Service/Method",
+		Headers:    new(HeadersList),
+		Timeout:    defaultTimeout,
+		Body:       `{"name": "x", "kind": "KIND_FIRST"}`,
+		Stream:     true,
+		ClientType: grpc,
+		Format:     knownFormat("plain-text"),
+		Insecure:   true,
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	b.disableOutput()
+	b.Bombard()
+
+	if calls := atomic.LoadUint64(&calls); calls != numReqs {
+		t.Errorf("Expected %v calls, but got %v", numReqs, calls)
+	}
+	codes := b.gatherInfo().Result.GRPCCodes
+	if codes["OK"] != numReqs {
+		t.Errorf("Expected %v OK responses, but got %v", numReqs, codes)
+	}
+	if len(b.errors.byFrequency()) > 0 {
+		t.Error(b.errors.byFrequency())
+	}
+}
+
+func TestBombardierGRPCReflectionErrors(t *testing.T) {
+	s := newGRPCTestServer(t, func(method string, msg []byte) ([]byte, int) {
+		if reply, code, ok := serveReflection(method, msg); ok {
+			return reply, code
+		}
+		return nil, grpcUnimplemented
+	})
+	defer s.Close()
+	for path, expected := range map[string]error{
+		"/other.Service/Method": &grpcReflectionError{5, "not found"},
+		"/test.Service/Other":   &unknownGRPCMethodError{"test.Service/Other"},
+	} {
+		_, e := NewBombardier(Config{
+			NumConns:   defaultNumberOfConns,
+			Url:        s.URL + path,
+			Headers:    new(HeadersList),
+			Timeout:    defaultTimeout,
+			Body:       `{"name": "x"}`,
+			ClientType: grpc,
+			Format:     knownFormat("plain-text"),
+			Insecure:   true,
+		})
+		if !reflect.DeepEqual(e, expected) {
+			t.Errorf("%v: expected %v, but got %v", path, expected, e)
+		}
+	}
+}
+
+func TestBombardierGRPCInvalidMessage(t *testing.T) {
+	protoset := writeTestProtoset(t)
+	defer func() {
+		_ = os.Remove(protoset)
+	}()
+	_, e := NewBombardier(Config{
+		NumConns:     defaultNumberOfConns,
+		Url:          "http://localhost/test.Service/Method",
+		Headers:      new(HeadersList),
+		Timeout:      defaultTimeout,
+		Body:         `{"count": "many"}`,
+		ClientType:   grpc,
+		GRPCProtoset: protoset,
+		Format:       knownFormat("plain-text"),
+	})
+	if _, ok := e.(*invalidJSONMessageError); !ok {
+		t.Errorf("Expected invalid JSON message, but got %v", e)
+	}
+}
+
+func TestBombardierGRPCMaxMsgSize(t *testing.T) {
+	s := newGRPCTestServer(t, func(string, []byte) ([]byte, int) {
+		return []byte("reply"), grpcOK
+	})
+	defer s.Close()
+	numReqs := uint64(5)
+	for _, maxSize := range []uint64{0, 4} {
+		b, e := NewBombardier(Config{
+			NumConns:       defaultNumberOfConns,
+			NumReqs:        &numReqs,
+			Url:            s.URL + "/test.Service/Method",
+			Headers:        new(HeadersList),
+			Timeout:        defaultTimeout,
+			ClientType:     grpc,
+			GRPCMaxMsgSize: maxSize,
+			Format:         knownFormat("plain-text"),
+			Insecure:       true,
+		})
+		if e != nil {
+			t.Fatal(e)
+		}
+		b.disableOutput()
+		b.Bombard()
+
+		codes := b.gatherInfo().Result.GRPCCodes
+		errs := b.errors.byFrequency()
+		if maxSize == 0 {
+			if codes["OK"] != numReqs || len(errs) > 0 {
+				t.Errorf("Expected %v OK responses, but got %v, %v",
+					numReqs, codes, errs)
+			}
+			continue
+		}
+		if len(errs) != 1 ||
+			errs[0].error != errGRPCMessageTooLarge.Error() ||
+			errs[0].count != numReqs {
+			t.Errorf("Expected %v %v errors, but got %v",
+				numReqs, errGRPCMessageTooLarge, errs)
+		}
+	}
+}
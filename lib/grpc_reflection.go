@@ -0,0 +1,196 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// Methods of server reflection service, in order of preference.
+var grpcReflectionMethods = [...]string{
+	"/grpc.reflection.v1.ServerReflection/ServerReflectionInfo",
+	"/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo",
+}
+
+// Fields of ServerReflectionRequest.
+const (
+	reflectionHost         protowire.Number = 1
+	reflectionFileByName   protowire.Number = 3
+	reflectionFileBySymbol protowire.Number = 4
+)
+
+type grpcReflectionError struct {
+	code    int
+	message string
+}
+
+func (g *grpcReflectionError) Error() string {
+	name := fmt.Sprint(g.code)
+	if g.code >= 0 && g.code < len(grpcCodeNames) {
+		name = grpcCodeNames[g.code]
+	}
+	if g.message == "" {
+		return fmt.Sprintf("Server reflection failed with %v", name)
+	}
+	return fmt.Sprintf("Server reflection failed with %v: %v",
+		name, g.message)
+}
+
+// grpcReflection fetches descriptors from the server.
+type grpcReflection struct {
+	c      *grpcClient
+	client *http.Client
+	// method is the index of reflection method server implements
+	method int
+}
+
+// reflect fetches descriptors of the service and all the files it
+// depends on. Connections it makes aren't accounted for in
+// statistics of the test.
+func (c *grpcClient) reflect(
+	opts *clientOpts, service string,
+) (*descriptors, error) {
+	tr := c.transport(dialContextFunc(opts), opts.tlsConfig)
+	defer tr.CloseIdleConnections()
+	r := &grpcReflection{
+		c:      c,
+		client: &http.Client{Transport: tr, Timeout: opts.timeout},
+	}
+
+	set := new(descriptorpb.FileDescriptorSet)
+	seen := make(map[string]bool)
+	files, err := r.request(reflectionFileBySymbol, service)
+	var pending []string
+	for err == nil && len(files) > 0 {
+		for _, data := range files {
+			file := new(descriptorpb.FileDescriptorProto)
+			if err = proto.Unmarshal(data, file); err != nil {
+				return nil, err
+			}
+			if !seen[file.GetName()] {
+				seen[file.GetName()] = true
+				set.File = append(set.File, file)
+				pending = append(pending, file.GetDependency()...)
+			}
+		}
+		files = nil
+		for err == nil && len(files) == 0 && len(pending) > 0 {
+			dep := pending[0]
+			pending = pending[1:]
+			if !seen[dep] {
+				files, err = r.request(reflectionFileByName, dep)
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newDescriptors(set)
+}
+
+// request asks for the file, either by its name or by a symbol it
+// contains, and returns the file along with files it depends on,
+// that server chose to send.
+func (r *grpcReflection) request(
+	field protowire.Number, value string,
+) ([][]byte, error) {
+	msg := protowire.AppendTag(nil, reflectionHost, protowire.BytesType)
+	msg = protowire.AppendString(msg, r.c.url.Host)
+	msg = protowire.AppendTag(msg, field, protowire.BytesType)
+	msg = protowire.AppendString(msg, value)
+	frame := grpcFrame(msg)
+	for {
+		u := *r.c.url
+		u.Path, u.RawPath = grpcReflectionMethods[r.method], ""
+		req := &http.Request{
+			Method:        "POST",
+			URL:           &u,
+			Host:          u.Host,
+			Header:        r.c.headers,
+			Body:          ioutil.NopCloser(bytes.NewReader(frame)),
+			ContentLength: int64(len(frame)),
+		}
+		resp, err := r.client.Do(req.WithContext(r.c.ctx))
+		if err != nil {
+			return nil, err
+		}
+		var msgs [][]byte
+		code, err := readGRPCResponse(resp, r.c.maxMsgSize, &msgs)
+		if err != nil {
+			return nil, err
+		}
+		if code == grpcUnimplemented &&
+			r.method+1 < len(grpcReflectionMethods) {
+			r.method++
+			continue
+		}
+		if code != grpcOK {
+			return nil, &grpcReflectionError{code: code}
+		}
+		if len(msgs) == 0 {
+			return nil, errInvalidProtobuf
+		}
+		return parseReflectionResponse(msgs[0])
+	}
+}
+
+// parseReflectionResponse returns files from ServerReflectionResponse.
+func parseReflectionResponse(resp []byte) ([][]byte, error) {
+	var (
+		files [][]byte
+		rerr  *grpcReflectionError
+	)
+	err := forEachProtoField(resp, func(
+		n protowire.Number, _ uint64, b []byte,
+	) error {
+		var err error
+		switch n {
+		case 4:
+			files, err = parseFileDescriptorResponse(b)
+		case 7:
+			rerr, err = parseReflectionError(b)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rerr != nil {
+		return nil, rerr
+	}
+	return files, nil
+}
+
+func parseFileDescriptorResponse(resp []byte) ([][]byte, error) {
+	var files [][]byte
+	err := forEachProtoField(resp, func(
+		n protowire.Number, _ uint64, b []byte,
+	) error {
+		if n == 1 {
+			files = append(files, b)
+		}
+		return nil
+	})
+	return files, err
+}
+
+func parseReflectionError(resp []byte) (*grpcReflectionError, error) {
+	rerr := new(grpcReflectionError)
+	err := forEachProtoField(resp, func(
+		n protowire.Number, v uint64, b []byte,
+	) error {
+		switch n {
+		case 1:
+			rerr.code = int(int32(v))
+		case 2:
+			rerr.message = string(b)
+		}
+		return nil
+	})
+	return rerr, err
+}
@@ -0,0 +1,398 @@
+package lib
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+
+	"github.com/tony24681379/bombardier/internal"
+	"golang.org/x/net/http2"
+)
+
+const (
+	http2FrameHeaderLen = 9
+
+	http2FrameRSTStream = 0x3
+	http2FrameGoAway    = 0x7
+
+	// Limit of streams the transport assumes until the server sets
+	// one
+	http2DefaultMaxStreams = 100
+
+	// Limits of windows the transport accepts
+	http2MinStreamWindowSize = 16 << 10
+	http2MinConnWindowSize   = 64 << 10
+	http2MaxWindowSize       = 4 << 20
+)
+
+// http2Stats holds numbers of HTTP/2 connections, streams opened over
+// them, GOAWAY and RST_STREAM frames received.
+type http2Stats struct {
+	conns, streams, goAways, streamResets uint64
+}
+
+func (s *http2Stats) snapshot() *internal.HTTP2Stats {
+	return &internal.HTTP2Stats{
+		Connections:  atomic.LoadUint64(&s.conns),
+		Streams:      atomic.LoadUint64(&s.streams),
+		GoAways:      atomic.LoadUint64(&s.goAways),
+		StreamResets: atomic.LoadUint64(&s.streamResets),
+	}
+}
+
+// http2Framing finds headers of frames in a stream of HTTP/2 frames.
+type http2Framing struct {
+	// buf holds the header of the current frame, filled is the
+	// number of bytes of it read so far, skip is the number of bytes
+	// of the payload left
+	buf    [http2FrameHeaderLen]byte
+	filled int
+	skip   uint32
+}
+
+// next returns b past the next frame header and the header itself,
+// if b completes it.
+func (f *http2Framing) next(b []byte) (rest, header []byte) {
+	for len(b) > 0 {
+		if f.skip > 0 {
+			k := uint32(len(b))
+			if k > f.skip {
+				k = f.skip
+			}
+			b, f.skip = b[k:], f.skip-k
+			continue
+		}
+		k := copy(f.buf[f.filled:], b)
+		b, f.filled = b[k:], f.filled+k
+		if f.filled < http2FrameHeaderLen {
+			continue
+		}
+		f.skip = http2FrameLen(f.buf[:])
+		f.filled = 0
+		return b, f.buf[:]
+	}
+	return b, nil
+}
+
+func http2FrameLen(header []byte) uint32 {
+	return uint32(header[0])<<16 | uint32(header[1])<<8 | uint32(header[2])
+}
+
+// http2FrameConn follows frames received over HTTP/2 connection,
+// after TLS, if any, is stripped, counting those of interest.
+type http2FrameConn struct {
+	net.Conn
+	stats *http2Stats
+	cc    *countingConn
+
+	// in is only used from the read loop of the connection, so it
+	// doesn't need synchronization
+	in     http2Framing
+	goAway uint32
+	failed uint32
+}
+
+func newHTTP2FrameConn(conn net.Conn, stats *http2Stats) *http2FrameConn {
+	return &http2FrameConn{
+		Conn:  conn,
+		stats: stats,
+		cc:    countingConnOf(conn),
+	}
+}
+
+func (fc *http2FrameConn) Read(b []byte) (n int, err error) {
+	n, err = fc.Conn.Read(b)
+	fc.follow(b[:n])
+	if err != nil {
+		atomic.StoreUint32(&fc.failed, 1)
+	}
+	return
+}
+
+func (fc *http2FrameConn) follow(b []byte) {
+	for len(b) > 0 {
+		var header []byte
+		if b, header = fc.in.next(b); header == nil {
+			return
+		}
+		switch header[3] {
+		case http2FrameRSTStream:
+			atomic.AddUint64(&fc.stats.streamResets, 1)
+		case http2FrameGoAway:
+			atomic.AddUint64(&fc.stats.goAways, 1)
+			atomic.StoreUint32(&fc.goAway, 1)
+			if fc.cc != nil {
+				fc.cc.closeAnnounced()
+			}
+		}
+	}
+}
+
+func (fc *http2FrameConn) Write(b []byte) (int, error) {
+	n, err := fc.Conn.Write(b)
+	if err != nil {
+		atomic.StoreUint32(&fc.failed, 1)
+	}
+	return n, err
+}
+
+func (fc *http2FrameConn) gotGoAway() bool {
+	return atomic.LoadUint32(&fc.goAway) == 1
+}
+
+// broken tells whether reading from or writing to the connection
+// failed.
+func (fc *http2FrameConn) broken() bool {
+	return atomic.LoadUint32(&fc.failed) == 1
+}
+
+// http2Conn is a connection of http2Pool along with the number of
+// streams the pool sent over it, that are still in flight.
+type http2Conn struct {
+	cc     *http2.ClientConn
+	frames *http2FrameConn
+	active uint64
+	// retired tells whether the connection is closed, once streams
+	// in flight are done
+	retired bool
+}
+
+func (c *http2Conn) usable() bool {
+	return !c.frames.broken() && !c.frames.gotGoAway()
+}
+
+// canOpenStreams tells whether the server lets one more stream be
+// opened, on top of those the pool sent over the connection.
+func (c *http2Conn) canOpenStreams() bool {
+	// Unlike ClientConn.CanTakeNewRequest, streams about to be
+	// opened are counted as well
+	max := uint64(c.cc.State().MaxConcurrentStreams)
+	if max == 0 {
+		max = http2DefaultMaxStreams
+	}
+	return c.active < max && c.cc.CanTakeNewRequest()
+}
+
+// http2Pool spreads requests over a fixed number of HTTP/2
+// connections, keeping at most maxStreams of them in flight over
+// each one (in addition to the limit set by the server). Connections
+// that become unusable are replaced.
+type http2Pool struct {
+	tr          *http2.Transport
+	dialContext func(context.Context, string, string) (net.Conn, error)
+	tlsConfig   *tls.Config
+	addr        string
+	maxStreams  uint64
+	stats       *http2Stats
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	conns   []*http2Conn
+	dialing []bool
+}
+
+func newHTTP2Pool(opts *clientOpts, u *url.URL) *http2Pool {
+	o := opts.http2
+	numConns := o.Connections
+	if numConns == 0 {
+		numConns = 1
+	}
+	p := &http2Pool{
+		tr:          newHTTP2Transport(o),
+		dialContext: httpDialContextFunc(opts),
+		addr:        hostPort(u),
+		maxStreams:  o.MaxConcurrentStreams,
+		stats:       opts.http2Stats,
+		conns:       make([]*http2Conn, numConns),
+		dialing:     make([]bool, numConns),
+	}
+	p.cond = sync.NewCond(&p.mu)
+	if p.stats == nil {
+		p.stats = new(http2Stats)
+	}
+
+	// TLS is negotiated by the pool, rather than the transport, so
+	// that frames could be followed
+	if u.Scheme == "https" {
+		p.tlsConfig = &tls.Config{}
+		if opts.tlsConfig != nil {
+			p.tlsConfig = opts.tlsConfig.Clone()
+		}
+		p.tlsConfig.NextProtos = []string{"h2"}
+		if p.tlsConfig.ServerName == "" {
+			p.tlsConfig.ServerName = u.Hostname()
+		}
+	}
+	return p
+}
+
+// newHTTP2Transport returns transport with initial windows set, if
+// they are.
+func newHTTP2Transport(o HTTP2Options) *http2.Transport {
+	t1 := &http.Transport{HTTP2: &http.HTTP2Config{
+		MaxReceiveBufferPerStream:     int(o.StreamWindowSize),
+		MaxReceiveBufferPerConnection: int(o.ConnWindowSize),
+	}}
+	tr, err := http2.ConfigureTransports(t1)
+	if err != nil {
+		// Only happens, if t1 already uses HTTP/2
+		panic(err)
+	}
+	return tr
+}
+
+func hostPort(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+func (p *http2Pool) dial(ctx context.Context) (*http2Conn, error) {
+	conn, err := p.dialContext(ctx, "tcp", p.addr)
+	if err != nil {
+		return nil, err
+	}
+	fc := newHTTP2FrameConn(conn, p.stats)
+	if p.tlsConfig != nil {
+		tlsConn := tls.Client(conn, p.tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		if tlsConn.ConnectionState().NegotiatedProtocol != "h2" {
+			_ = conn.Close()
+			return nil, errHTTP2NotNegotiated
+		}
+		fc.Conn = tlsConn
+	}
+	cc, err := p.tr.NewClientConn(fc)
+	if err != nil {
+		_ = fc.Close()
+		return nil, err
+	}
+	atomic.AddUint64(&p.stats.conns, 1)
+	return &http2Conn{cc: cc, frames: fc}, nil
+}
+
+// acquire returns connection, that can take one more stream, waiting
+// for one, if necessary.
+func (p *http2Pool) acquire(ctx context.Context) (*http2Conn, error) {
+	if done := ctx.Done(); done != nil {
+		acquired := make(chan struct{})
+		defer close(acquired)
+		go func() {
+			select {
+			case <-done:
+				p.mu.Lock()
+				p.cond.Broadcast()
+				p.mu.Unlock()
+			case <-acquired:
+			}
+		}()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		var best *http2Conn
+		for i, c := range p.conns {
+			if c != nil && !c.usable() {
+				// Streams in flight are let to finish
+				p.retire(c)
+				p.conns[i], c = nil, nil
+			}
+			if c == nil {
+				if p.dialing[i] {
+					continue
+				}
+				p.dialing[i] = true
+				p.mu.Unlock()
+				nc, err := p.dial(ctx)
+				p.mu.Lock()
+				p.dialing[i] = false
+				p.cond.Broadcast()
+				if err != nil {
+					return nil, err
+				}
+				p.conns[i], c = nc, nc
+			}
+			if (p.maxStreams > 0 && c.active >= p.maxStreams) ||
+				!c.canOpenStreams() {
+				continue
+			}
+			if best == nil || c.active < best.active {
+				best = c
+			}
+		}
+		if best != nil {
+			best.active++
+			return best, nil
+		}
+		p.cond.Wait()
+	}
+}
+
+// retire closes the connection, once streams in flight are done.
+// It's called with mu held.
+func (p *http2Pool) retire(c *http2Conn) {
+	c.retired = true
+	if c.active == 0 {
+		_ = c.frames.Close()
+	}
+}
+
+func (p *http2Pool) release(c *http2Conn) {
+	p.mu.Lock()
+	c.active--
+	if c.retired && c.active == 0 {
+		_ = c.frames.Close()
+	}
+	p.cond.Broadcast()
+	p.mu.Unlock()
+}
+
+func (p *http2Pool) RoundTrip(req *http.Request) (*http.Response, error) {
+	c, err := p.acquire(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	atomic.AddUint64(&p.stats.streams, 1)
+	if cc := c.frames.cc; cc != nil {
+		cc.startRequest()
+	}
+	resp, err := c.cc.RoundTrip(req)
+	if err != nil {
+		p.release(c)
+		return nil, err
+	}
+	resp.Body = &http2Body{ReadCloser: resp.Body, release: func() {
+		p.release(c)
+	}}
+	return resp, nil
+}
+
+// http2Body releases the stream once the response is read.
+type http2Body struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *http2Body) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
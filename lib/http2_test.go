@@ -0,0 +1,298 @@
+package lib
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+func newHTTP2TestBombardier(
+	t *testing.T, url string, numConns, numReqs uint64, opts HTTP2Options,
+) *Bombardier {
+	b, e := NewBombardier(Config{
+		NumConns:   numConns,
+		NumReqs:    &numReqs,
+		Url:        url,
+		Headers:    new(HeadersList),
+		Timeout:    defaultTimeout,
+		Method:     "GET",
+		ClientType: nhttp2,
+		Insecure:   true,
+		HTTP2:      opts,
+		Format:     knownFormat("plain-text"),
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	b.disableOutput()
+	return b
+}
+
+func newHTTP2TestServer(h http.HandlerFunc) *httptest.Server {
+	s := httptest.NewUnstartedServer(h)
+	s.EnableHTTP2 = true
+	s.StartTLS()
+	return s
+}
+
+// newH2CTestServer returns server, that takes HTTP/2 over cleartext
+// connections with prior knowledge.
+func newH2CTestServer(h http.HandlerFunc) *httptest.Server {
+	s := httptest.NewUnstartedServer(h)
+	s.Config.Protocols = new(http.Protocols)
+	s.Config.Protocols.SetUnencryptedHTTP2(true)
+	s.Start()
+	return s
+}
+
+func TestBombardierHTTP2ConnectionsAndStreams(t *testing.T) {
+	var (
+		mu        sync.Mutex
+		inFlight  = make(map[string]int)
+		maxFlight = make(map[string]int)
+		protoErr  bool
+	)
+	s := newHTTP2TestServer(func(rw http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		if r.ProtoMajor != 2 {
+			protoErr = true
+		}
+		inFlight[r.RemoteAddr]++
+		if inFlight[r.RemoteAddr] > maxFlight[r.RemoteAddr] {
+			maxFlight[r.RemoteAddr] = inFlight[r.RemoteAddr]
+		}
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		inFlight[r.RemoteAddr]--
+		mu.Unlock()
+	})
+	defer s.Close()
+	b := newHTTP2TestBombardier(t, s.URL, 10, 50, HTTP2Options{
+		Connections:          2,
+		MaxConcurrentStreams: 3,
+	})
+	b.Bombard()
+
+	if b.req2xx != 50 {
+		t.Errorf("Expected 50 2xx, but got %v, errors: %v",
+			b.req2xx, b.errors.byFrequency())
+	}
+	if protoErr {
+		t.Error("Expected all requests to use HTTP/2")
+	}
+	if len(maxFlight) != 2 {
+		t.Errorf("Expected 2 connections, but got %v", len(maxFlight))
+	}
+	for addr, max := range maxFlight {
+		if max > 3 {
+			t.Errorf("Expected at most 3 streams over %v, but got %v",
+				addr, max)
+		}
+	}
+	stats := b.http2.snapshot()
+	if stats.Connections != 2 || stats.Streams != 50 {
+		t.Errorf("Expected 2 connections and 50 streams, but got %+v",
+			*stats)
+	}
+	if spc := stats.StreamsPerConnection(); spc != 25 {
+		t.Errorf("Expected 25 streams per connection, but got %v", spc)
+	}
+}
+
+func TestBombardierH2C(t *testing.T) {
+	var protoErr bool
+	s := newH2CTestServer(func(rw http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 {
+			protoErr = true
+		}
+	})
+	defer s.Close()
+	b := newHTTP2TestBombardier(t, s.URL, 1, 10, HTTP2Options{H2C: true})
+	b.Bombard()
+
+	if b.req2xx != 10 {
+		t.Errorf("Expected 10 2xx, but got %v, errors: %v",
+			b.req2xx, b.errors.byFrequency())
+	}
+	if protoErr {
+		t.Error("Expected all requests to use HTTP/2")
+	}
+	if stats := b.http2.snapshot(); stats.Connections != 1 {
+		t.Errorf("Expected 1 connection, but got %+v", *stats)
+	}
+}
+
+func TestBombardierHTTP2NotNegotiated(t *testing.T) {
+	s := httptest.NewUnstartedServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}),
+	)
+	s.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	s.StartTLS()
+	defer s.Close()
+	b := newHTTP2TestBombardier(t, s.URL, 1, 3, HTTP2Options{Connections: 1})
+	b.Bombard()
+
+	if b.req2xx != 0 || b.errors.sum() != 3 {
+		t.Errorf("Expected all requests to fail, but got %v 2xx, errors: %v",
+			b.req2xx, b.errors.byFrequency())
+	}
+}
+
+func TestBombardierHTTP2StreamResets(t *testing.T) {
+	s := newHTTP2TestServer(func(rw http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+	defer s.Close()
+	b := newHTTP2TestBombardier(t, s.URL, 1, 5, HTTP2Options{Connections: 1})
+	b.Bombard()
+
+	if b.errors.sum() != 5 {
+		t.Errorf("Expected 5 errors, but got %v", b.errors.byFrequency())
+	}
+	stats := b.http2.snapshot()
+	if stats.StreamResets < 5 || stats.Connections != 1 {
+		t.Errorf("Expected 5 streams reset over 1 connection, but got %+v",
+			*stats)
+	}
+}
+
+func TestBombardierHTTP2GoAway(t *testing.T) {
+	s := httptest.NewUnstartedServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}),
+	)
+	s.EnableHTTP2 = true
+	s.Config.SetKeepAlivesEnabled(false)
+	s.StartTLS()
+	defer s.Close()
+	b := newHTTP2TestBombardier(t, s.URL, 1, 5, HTTP2Options{Connections: 1})
+	b.Bombard()
+
+	if b.req2xx != 5 {
+		t.Errorf("Expected 5 2xx, but got %v, errors: %v",
+			b.req2xx, b.errors.byFrequency())
+	}
+	stats := b.http2.snapshot()
+	if stats.GoAways == 0 || stats.Connections < 2 {
+		t.Errorf("Expected connections to be replaced after GOAWAY, "+
+			"but got %+v", *stats)
+	}
+	if open := atomic.LoadInt64(&b.openConns); open > 1 {
+		t.Errorf("Expected replaced connections to be closed, "+
+			"but %v are open", open)
+	}
+}
+
+func TestBombardierHTTP2Windows(t *testing.T) {
+	body := bytes.Repeat([]byte("a"), 1<<20)
+	for _, h2c := range []bool{false, true} {
+		handler := func(rw http.ResponseWriter, r *http.Request) {
+			_, _ = rw.Write(body)
+		}
+		var s *httptest.Server
+		if h2c {
+			s = newH2CTestServer(handler)
+		} else {
+			s = newHTTP2TestServer(handler)
+		}
+		b := newHTTP2TestBombardier(t, s.URL, 4, 8, HTTP2Options{
+			H2C:              h2c,
+			StreamWindowSize: http2MinStreamWindowSize,
+			ConnWindowSize:   http2MinConnWindowSize,
+		})
+		b.Bombard()
+		s.Close()
+
+		if b.req2xx != 8 {
+			t.Errorf("h2c %v: expected 8 2xx, but got %v, errors: %v",
+				h2c, b.req2xx, b.errors.byFrequency())
+		}
+		if read := atomic.LoadInt64(&b.bytesRead); read < 8<<20 {
+			t.Errorf("h2c %v: expected whole responses to be read, "+
+				"but got %v bytes", h2c, read)
+		}
+	}
+}
+
+func TestHTTP2FrameConnFollowsFrames(t *testing.T) {
+	frame := func(typ byte, payload int) []byte {
+		f := []byte{
+			byte(payload >> 16), byte(payload >> 8), byte(payload),
+			typ, 0, 0, 0, 0, 1,
+		}
+		return append(f, make([]byte, payload)...)
+	}
+	var stream bytes.Buffer
+	stream.Write(frame(0x4, 18))    // SETTINGS
+	stream.Write(frame(0x0, 70000)) // DATA
+	stream.Write(frame(http2FrameRSTStream, 4))
+	stream.Write(frame(0x1, 0)) // HEADERS
+	stream.Write(frame(http2FrameRSTStream, 4))
+	stream.Write(frame(http2FrameGoAway, 8))
+	stream.Write(frame(0x6, 8)) // PING
+	data := stream.Bytes()
+
+	for _, chunk := range []int{1, 5, 9, 100, len(data)} {
+		stats := new(http2Stats)
+		fc := &http2FrameConn{stats: stats}
+		for b := data; len(b) > 0; {
+			k := chunk
+			if k > len(b) {
+				k = len(b)
+			}
+			fc.follow(b[:k])
+			b = b[k:]
+		}
+		if stats.streamResets != 2 || stats.goAways != 1 ||
+			!fc.gotGoAway() {
+			t.Errorf("chunk %v: expected 2 resets and 1 GOAWAY, "+
+				"but got %+v", chunk, *stats)
+		}
+	}
+}
+
+func TestHTTP2TransportSetsWindows(t *testing.T) {
+	tr := newHTTP2Transport(HTTP2Options{
+		StreamWindowSize: 32 << 10,
+		ConnWindowSize:   1 << 20,
+	})
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	go func() {
+		_, _ = tr.NewClientConn(client)
+	}()
+
+	preface := make([]byte, len(http2.ClientPreface))
+	if _, err := io.ReadFull(server, preface); err != nil {
+		t.Fatal(err)
+	}
+	fr := http2.NewFramer(nil, server)
+	f, err := fr.ReadFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := f.(*http2.SettingsFrame).Value(
+		http2.SettingInitialWindowSize,
+	); v != 32<<10 {
+		t.Errorf("Expected stream window of 32KiB, but got %v", v)
+	}
+	if f, err = fr.ReadFrame(); err != nil {
+		t.Fatal(err)
+	}
+	// The transport extends the default window of the connection
+	if inc := f.(*http2.WindowUpdateFrame).Increment; inc != 1<<20 {
+		t.Errorf("Expected connection window to be extended by 1MiB, "+
+			"but got %v", inc)
+	}
+}
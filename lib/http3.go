@@ -0,0 +1,183 @@
+package lib
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	uhist "github.com/codesenberg/concurrent/uint64/histogram"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/tony24681379/bombardier/internal"
+)
+
+// quicStats holds numbers of QUIC handshakes completed by the HTTP/3
+// client and of those, that resumed sessions using 0-RTT.
+type quicStats struct {
+	handshakes, zeroRTT uint64
+	// handshakeTimes holds time taken to complete handshakes, in
+	// microseconds
+	handshakeTimes *uhist.Histogram
+}
+
+func newQUICStats() *quicStats {
+	return &quicStats{handshakeTimes: uhist.Default()}
+}
+
+func (s *quicStats) snapshot() *internal.QUICStats {
+	return &internal.QUICStats{
+		Handshakes: atomic.LoadUint64(&s.handshakes),
+		ZeroRTT:    atomic.LoadUint64(&s.zeroRTT),
+	}
+}
+
+func (s *quicStats) handshake(taken time.Duration, usedZeroRTT bool) {
+	s.handshakeTimes.Increment(uint64(taken.Nanoseconds() / 1000))
+	if usedZeroRTT {
+		atomic.AddUint64(&s.zeroRTT, 1)
+	}
+	atomic.AddUint64(&s.handshakes, 1)
+}
+
+// countingPacketConn is the UDP counterpart of countingConn. It only
+// exposes ReadFrom and WriteTo of the socket, since quic-go would
+// otherwise use batched reads and segmentation offload, that bypass
+// them.
+type countingPacketConn struct {
+	net.PacketConn
+	udp                     *net.UDPConn
+	bytesRead, bytesWritten *int64
+
+	// openConns is optional and may be nil
+	openConns *int64
+	closeOnce sync.Once
+}
+
+func newCountingPacketConn(
+	conn *net.UDPConn, opts *clientOpts,
+) *countingPacketConn {
+	if opts.openConns != nil {
+		atomic.AddInt64(opts.openConns, 1)
+	}
+	return &countingPacketConn{
+		PacketConn:   conn,
+		udp:          conn,
+		bytesRead:    opts.bytesRead,
+		bytesWritten: opts.bytesWritten,
+		openConns:    opts.openConns,
+	}
+}
+
+func (cc *countingPacketConn) ReadFrom(
+	b []byte,
+) (n int, addr net.Addr, err error) {
+	n, addr, err = cc.PacketConn.ReadFrom(b)
+
+	if err == nil {
+		atomic.AddInt64(cc.bytesRead, int64(n))
+	}
+
+	return
+}
+
+func (cc *countingPacketConn) WriteTo(
+	b []byte, addr net.Addr,
+) (n int, err error) {
+	n, err = cc.PacketConn.WriteTo(b, addr)
+
+	if err == nil {
+		atomic.AddInt64(cc.bytesWritten, int64(n))
+	}
+
+	return
+}
+
+// SetReadBuffer and SetWriteBuffer let quic-go enlarge buffers of the
+// socket.
+func (cc *countingPacketConn) SetReadBuffer(bytes int) error {
+	return cc.udp.SetReadBuffer(bytes)
+}
+
+func (cc *countingPacketConn) SetWriteBuffer(bytes int) error {
+	return cc.udp.SetWriteBuffer(bytes)
+}
+
+func (cc *countingPacketConn) Close() error {
+	cc.closeOnce.Do(func() {
+		if cc.openConns != nil {
+			atomic.AddInt64(cc.openConns, -1)
+		}
+	})
+	return cc.PacketConn.Close()
+}
+
+// http3Dialer opens a UDP socket for each QUIC connection, which is
+// closed along with the connection.
+type http3Dialer struct {
+	opts *clientOpts
+	// stats is optional and may be nil
+	stats *quicStats
+}
+
+func newHTTP3Transport(opts *clientOpts) *http3.Transport {
+	tlsConfig := opts.tlsConfig.Clone()
+	if tlsConfig == nil {
+		tlsConfig = new(tls.Config)
+	}
+	if tlsConfig.ClientSessionCache == nil {
+		// Resumed sessions are needed for 0-RTT
+		tlsConfig.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+	}
+	d := &http3Dialer{opts: opts, stats: opts.quicStats}
+	return &http3.Transport{
+		TLSClientConfig: tlsConfig,
+		Dial:            d.dial,
+	}
+}
+
+func (d *http3Dialer) dial(
+	ctx context.Context, addr string, tlsConfig *tls.Config, cfg *quic.Config,
+) (*quic.Conn, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	udp, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return nil, err
+	}
+	tr := &quic.Transport{Conn: newCountingPacketConn(udp, d.opts)}
+	start := time.Now()
+	conn, err := tr.DialEarly(ctx, udpAddr, tlsConfig, cfg)
+	if err != nil {
+		_ = tr.Close()
+		_ = tr.Conn.Close()
+		return nil, err
+	}
+	go d.track(conn, tr, start)
+	return conn, nil
+}
+
+// track records the handshake of the connection, once it's complete,
+// and closes the socket after the connection is closed. Connections,
+// that resume sessions, are returned by dial before handshakes are
+// complete, so that they could send 0-RTT data.
+func (d *http3Dialer) track(
+	conn *quic.Conn, tr *quic.Transport, start time.Time,
+) {
+	select {
+	case <-conn.HandshakeComplete():
+		if d.stats != nil {
+			d.stats.handshake(
+				time.Since(start), conn.ConnectionState().Used0RTT,
+			)
+		}
+	case <-conn.Context().Done():
+	}
+	<-conn.Context().Done()
+	_ = tr.Close()
+	_ = tr.Conn.Close()
+}
@@ -0,0 +1,158 @@
+package lib
+
+import (
+	"bytes"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+// newHTTP3TestServer serves HTTP/3 on a local UDP port and returns
+// its URL along with the function, that stops it.
+func newHTTP3TestServer(
+	t *testing.T, h http.HandlerFunc,
+) (string, func()) {
+	cert, err := tls.LoadX509KeyPair("testserver.cert", "testserver.key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{
+		IP: net.IPv4(127, 0, 0, 1),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &http3.Server{
+		Handler: h,
+		TLSConfig: http3.ConfigureTLSConfig(&tls.Config{
+			Certificates: []tls.Certificate{cert},
+		}),
+	}
+	go func() {
+		_ = s.Serve(conn)
+	}()
+	return "https://" + conn.LocalAddr().String(), func() {
+		_ = s.Close()
+		_ = conn.Close()
+	}
+}
+
+// waitForHandshakes waits until the number of handshakes reaches n,
+// since they are recorded after requests are sent.
+func waitForHandshakes(s *quicStats, n uint64) {
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadUint64(&s.handshakes) < n &&
+		time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestBombardierHTTP3(t *testing.T) {
+	protoErr := uint32(0)
+	url, stop := newHTTP3TestServer(t, func(
+		rw http.ResponseWriter, r *http.Request,
+	) {
+		if r.ProtoMajor != 3 {
+			atomic.StoreUint32(&protoErr, 1)
+		}
+		_, _ = rw.Write([]byte("hello"))
+	})
+	defer stop()
+	numReqs := uint64(20)
+	b, e := NewBombardier(Config{
+		NumConns:   5,
+		NumReqs:    &numReqs,
+		Url:        url,
+		Headers:    new(HeadersList),
+		Timeout:    defaultTimeout,
+		Method:     "GET",
+		ClientType: nhttp3,
+		Insecure:   true,
+		Format:     knownFormat("json"),
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	out := new(bytes.Buffer)
+	b.redirectOutputTo(out)
+	b.Conf.PrintProgress = false
+	b.Bombard()
+	waitForHandshakes(b.quic, 1)
+	b.PrintStats()
+
+	if b.req2xx != numReqs {
+		t.Errorf("Expected %v 2xx, but got %v, errors: %v",
+			numReqs, b.req2xx, b.errors.byFrequency())
+	}
+	if atomic.LoadUint32(&protoErr) != 0 {
+		t.Error("Expected all requests to use HTTP/3")
+	}
+	info := b.gatherInfo()
+	if info.Result.BytesRead == 0 || info.Result.BytesWritten == 0 {
+		t.Errorf("Expected bytes to be counted, but got %v read, %v written",
+			info.Result.BytesRead, info.Result.BytesWritten)
+	}
+	if q := info.Result.QUIC; q == nil ||
+		q.Handshakes != 1 || q.ZeroRTT != 0 {
+		t.Errorf("Expected 1 handshake without 0-RTT, but got %+v", q)
+	}
+	if info.Result.QUICHandshakeStats([]float64{0.5}) == nil {
+		t.Error("Expected handshake times to be recorded")
+	}
+	for _, exp := range []string{
+		`"client":"http3"`,
+		`"quic":{"handshakes":1,"zeroRTT":0}`,
+		`"quicHandshake":{"mean":`,
+	} {
+		if !bytes.Contains(out.Bytes(), []byte(exp)) {
+			t.Errorf("Expected %v in output, but got %v",
+				exp, out.String())
+		}
+	}
+}
+
+func TestHTTP3ResumesWithZeroRTT(t *testing.T) {
+	url, stop := newHTTP3TestServer(t, func(
+		http.ResponseWriter, *http.Request,
+	) {
+	})
+	defer stop()
+	var bytesRead, bytesWritten, openConns int64
+	stats := newQUICStats()
+	c := makeHTTPClient(nhttp3, &clientOpts{
+		timeout:      defaultTimeout,
+		tlsConfig:    &tls.Config{InsecureSkipVerify: true},
+		headers:      new(HeadersList),
+		url:          url,
+		method:       "GET",
+		body:         new(string),
+		bytesRead:    &bytesRead,
+		bytesWritten: &bytesWritten,
+		openConns:    &openConns,
+		quicStats:    stats,
+	}).(*httpClient)
+	tr := c.client.Transport.(*http3.Transport)
+	defer func() {
+		_ = tr.Close()
+	}()
+	for i := uint64(1); i <= 2; i++ {
+		code, _, err := c.do()
+		if err != nil || code != http.StatusOK {
+			t.Fatalf("Expected 200, but got (%v, %v)", code, err)
+		}
+		waitForHandshakes(stats, i)
+		if open := atomic.LoadInt64(&openConns); open != 1 {
+			t.Errorf("Expected 1 open connection, but got %v", open)
+		}
+		tr.CloseIdleConnections()
+	}
+	if s := stats.snapshot(); s.Handshakes != 2 || s.ZeroRTT != 1 {
+		t.Errorf("Expected second of 2 handshakes to use 0-RTT, but got %+v",
+			*s)
+	}
+}
@@ -0,0 +1,184 @@
+package lib
+
+import (
+	"math/rand"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// slowReadChunk is the most bytes read at once in slow-read mode.
+const slowReadChunk = 128
+
+// ImpairmentOptions simulate poor network on the client side. Zero
+// values disable corresponding impairments.
+type ImpairmentOptions struct {
+	// Latency delays each write by up to Jitter more or less. Only
+	// traffic sent by the client is delayed, reads aren't.
+	Latency, Jitter time.Duration
+	// Bandwidth caps bytes per second sent and received over each
+	// connection.
+	Bandwidth uint64
+	// DropProbability is the probability of dropping the connection
+	// halfway through the first write of each request. Writes, that
+	// follow reads, are taken for new requests.
+	DropProbability float64
+	// SlowRead is the pause before each read of up to slowReadChunk
+	// bytes.
+	SlowRead time.Duration
+}
+
+func (o ImpairmentOptions) isSet() bool {
+	return o != ImpairmentOptions{}
+}
+
+// impair wraps the connection, if any impairments are set.
+func impair(cc *countingConn, opts ImpairmentOptions) net.Conn {
+	if !opts.isSet() {
+		return cc
+	}
+	ic := &impairedConn{Conn: cc, cc: cc, opts: opts}
+	if opts.Bandwidth > 0 {
+		ic.read = &pacer{bandwidth: opts.Bandwidth}
+		ic.write = &pacer{bandwidth: opts.Bandwidth}
+	}
+	return ic
+}
+
+// impairedConn delays, throttles or drops traffic of the connection.
+// Pauses end early once the connection is closed.
+type impairedConn struct {
+	net.Conn
+	cc   *countingConn
+	opts ImpairmentOptions
+
+	// read and write are nil unless bandwidth is capped
+	read, write *pacer
+	// rolled tells whether dropping the connection was decided for
+	// the request being written, it's reset by reads
+	rolled uint32
+}
+
+func (ic *impairedConn) Read(b []byte) (int, error) {
+	if ic.opts.SlowRead > 0 {
+		if len(b) > slowReadChunk {
+			b = b[:slowReadChunk]
+		}
+		if err := ic.sleep(ic.opts.SlowRead); err != nil {
+			return 0, err
+		}
+	}
+	if ic.read != nil {
+		if c := ic.read.chunk(); len(b) > c {
+			b = b[:c]
+		}
+	}
+	n, err := ic.Conn.Read(b)
+	if n > 0 && ic.opts.DropProbability > 0 {
+		atomic.StoreUint32(&ic.rolled, 0)
+	}
+	if n > 0 && ic.read != nil {
+		if serr := ic.sleep(ic.read.take(n)); err == nil {
+			err = serr
+		}
+	}
+	return n, err
+}
+
+func (ic *impairedConn) Write(b []byte) (int, error) {
+	if ic.opts.Latency > 0 || ic.opts.Jitter > 0 {
+		if err := ic.sleep(ic.delay()); err != nil {
+			return 0, err
+		}
+	}
+	if p := ic.opts.DropProbability; p > 0 &&
+		atomic.CompareAndSwapUint32(&ic.rolled, 0, 1) &&
+		rand.Float64() < p {
+		n, _ := ic.writeThrottled(b[:len(b)/2])
+		_ = ic.Close()
+		return n, errConnDropped
+	}
+	return ic.writeThrottled(b)
+}
+
+func (ic *impairedConn) writeThrottled(b []byte) (int, error) {
+	if ic.write == nil {
+		return ic.Conn.Write(b)
+	}
+	written := 0
+	for len(b) > 0 {
+		chunk := b
+		if c := ic.write.chunk(); len(chunk) > c {
+			chunk = chunk[:c]
+		}
+		if err := ic.sleep(ic.write.take(len(chunk))); err != nil {
+			return written, err
+		}
+		n, err := ic.Conn.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		b = b[n:]
+	}
+	return written, nil
+}
+
+// delay returns latency with random jitter applied.
+func (ic *impairedConn) delay() time.Duration {
+	d := ic.opts.Latency
+	if j := int64(ic.opts.Jitter); j > 0 {
+		d += time.Duration(rand.Int63n(2*j+1) - j)
+	}
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+func (ic *impairedConn) sleep(d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ic.cc.closed:
+		return net.ErrClosed
+	}
+}
+
+// pacer spaces transfers out, so that they don't exceed bandwidth.
+type pacer struct {
+	bandwidth uint64
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// chunk returns the number of bytes transferred in 10ms, so that
+// traffic flows evenly rather than in bursts.
+func (p *pacer) chunk() int {
+	if c := int(p.bandwidth / 100); c > 0 {
+		return c
+	}
+	return 1
+}
+
+// take reserves the link for n bytes and returns time to wait until
+// they are transferred.
+func (p *pacer) take(n int) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	if p.next.Before(now) {
+		p.next = now
+	}
+	p.next = p.next.Add(
+		time.Duration(uint64(n) * uint64(time.Second) / p.bandwidth),
+	)
+	return p.next.Sub(now)
+}
@@ -0,0 +1,282 @@
+package lib
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// impairedPipe returns client side of TCP connection, impaired
+// according to opts, and its server side.
+func impairedPipe(
+	t *testing.T, opts ImpairmentOptions,
+) (net.Conn, net.Conn) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, _ := l.Accept()
+		accepted <- conn
+	}()
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var read, written int64
+	cc := newCountingConn(conn, &clientOpts{
+		bytesRead: &read, bytesWritten: &written,
+	})
+	server := <-accepted
+	if server == nil {
+		t.Fatal("Failed to accept connection")
+	}
+	return impair(cc, opts), server
+}
+
+func TestImpairLeavesConnIntact(t *testing.T) {
+	client, server := impairedPipe(t, ImpairmentOptions{})
+	defer client.Close()
+	defer server.Close()
+	if _, ok := client.(*countingConn); !ok {
+		t.Errorf("Expected countingConn, but got %T", client)
+	}
+}
+
+func TestImpairedConnLatency(t *testing.T) {
+	const latency = 50 * time.Millisecond
+	client, server := impairedPipe(t, ImpairmentOptions{Latency: latency})
+	defer client.Close()
+	defer server.Close()
+	if countingConnOf(client) == nil {
+		t.Error("Expected countingConn to be found under impairments")
+	}
+
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		if _, err := client.Write([]byte("ping")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if took := time.Since(start); took < 2*latency {
+		t.Errorf("Expected writes to take at least %v, but took %v",
+			2*latency, took)
+	}
+}
+
+func TestImpairedConnJitter(t *testing.T) {
+	ic := &impairedConn{opts: ImpairmentOptions{
+		Latency: 10 * time.Millisecond,
+		Jitter:  20 * time.Millisecond,
+	}}
+	for i := 0; i < 1000; i++ {
+		if d := ic.delay(); d < 0 || d > 30*time.Millisecond {
+			t.Fatalf("Expected delay within [0, 30ms], but got %v", d)
+		}
+	}
+}
+
+func TestImpairedConnBandwidth(t *testing.T) {
+	const bandwidth = 100 << 10
+	client, server := impairedPipe(t, ImpairmentOptions{
+		Bandwidth: bandwidth,
+	})
+	defer client.Close()
+	defer server.Close()
+	payload := bytes.Repeat([]byte("a"), bandwidth/5)
+
+	go func() {
+		_, _ = server.Write(payload)
+	}()
+	start := time.Now()
+	buf := make([]byte, len(payload))
+	if _, err := io.ReadFull(client, buf); err != nil {
+		t.Fatal(err)
+	}
+	if took := time.Since(start); took < 150*time.Millisecond {
+		t.Errorf("Expected reading 20KiB at 100KiB/s to take about 200ms,"+
+			" but took %v", took)
+	}
+
+	start = time.Now()
+	go func() {
+		_, _ = io.CopyN(ioutil.Discard, server, int64(len(payload)))
+	}()
+	if _, err := client.Write(payload); err != nil {
+		t.Fatal(err)
+	}
+	if took := time.Since(start); took < 150*time.Millisecond {
+		t.Errorf("Expected writing 20KiB at 100KiB/s to take about 200ms,"+
+			" but took %v", took)
+	}
+}
+
+func TestImpairedConnSlowRead(t *testing.T) {
+	const pause = 20 * time.Millisecond
+	client, server := impairedPipe(t, ImpairmentOptions{SlowRead: pause})
+	defer client.Close()
+	defer server.Close()
+	if _, err := server.Write(make([]byte, 4*slowReadChunk)); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	buf := make([]byte, 4*slowReadChunk)
+	reads := 0
+	for read := 0; read < len(buf); reads++ {
+		n, err := client.Read(buf[read:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n > slowReadChunk {
+			t.Fatalf("Expected reads of up to %v bytes, but got %v",
+				slowReadChunk, n)
+		}
+		read += n
+	}
+	if took := time.Since(start); took < time.Duration(reads)*pause {
+		t.Errorf("Expected %v reads to take at least %v, but took %v",
+			reads, time.Duration(reads)*pause, took)
+	}
+}
+
+func TestImpairedConnDrop(t *testing.T) {
+	client, server := impairedPipe(t, ImpairmentOptions{
+		DropProbability: 1,
+	})
+	defer server.Close()
+
+	n, err := client.Write([]byte("abracadabra"))
+	if n != 5 || err != errConnDropped {
+		t.Errorf("Expected (5, %v), but got (%v, %v)", errConnDropped, n, err)
+	}
+	received, _ := ioutil.ReadAll(server)
+	if string(received) != "abrac" {
+		t.Errorf("Expected %q to be received, but got %q", "abrac", received)
+	}
+}
+
+func TestImpairedConnDropsOncePerRequest(t *testing.T) {
+	client, server := impairedPipe(t, ImpairmentOptions{
+		DropProbability: 1,
+	})
+	defer server.Close()
+	// Dropping the connection was decided against for the first
+	// request already, so the rest of its writes go through
+	atomic.StoreUint32(&client.(*impairedConn).rolled, 1)
+	for _, w := range []string{"pi", "ng"} {
+		if _, err := client.Write([]byte(w)); err != nil {
+			t.Fatalf("Expected writes of request to go through, "+
+				"but got %v", err)
+		}
+	}
+	if _, err := server.Write([]byte("pong")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadFull(client, make([]byte, 4)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Write([]byte("ping")); err != errConnDropped {
+		t.Errorf("Expected next request to be dropped, but got %v", err)
+	}
+}
+
+func TestImpairedConnPausesEndOnClose(t *testing.T) {
+	client, server := impairedPipe(t, ImpairmentOptions{
+		Latency: time.Hour,
+	})
+	defer server.Close()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = client.Close()
+	}()
+	if _, err := client.Write([]byte("ping")); err != net.ErrClosed {
+		t.Errorf("Expected %v, but got %v", net.ErrClosed, err)
+	}
+}
+
+func TestBombardierImpairsConnections(t *testing.T) {
+	testAllClients(t, func(clientType clientTyp, t *testing.T) {
+		s := httptest.NewServer(
+			http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}),
+		)
+		defer s.Close()
+
+		const latency = 50 * time.Millisecond
+		numReqs := uint64(4)
+		b, e := NewBombardier(Config{
+			NumConns:   1,
+			NumReqs:    &numReqs,
+			Url:        s.URL,
+			Headers:    new(HeadersList),
+			Timeout:    defaultTimeout,
+			Method:     "GET",
+			Impairment: ImpairmentOptions{Latency: latency},
+			ClientType: clientType,
+			Connections: ConnOptions{
+				IdleTimeout: time.Minute,
+			},
+			Format: knownFormat("plain-text"),
+		})
+		if e != nil {
+			t.Fatal(e)
+		}
+		b.disableOutput()
+		start := time.Now()
+		b.Bombard()
+
+		if b.req2xx != numReqs {
+			t.Errorf("Expected %v 2xx, but got %v 2xx, errors: %v",
+				numReqs, b.req2xx, b.errors.byFrequency())
+		}
+		min := time.Duration(numReqs) * latency
+		if took := time.Since(start); took < min {
+			t.Errorf("Expected test to take at least %v, but took %v",
+				min, took)
+		}
+		if b.conns.opened != 1 {
+			t.Errorf("Expected connection to be reused, but %v were opened",
+				b.conns.opened)
+		}
+	})
+}
+
+func TestBombardierDropsConnections(t *testing.T) {
+	testAllClients(t, func(clientType clientTyp, t *testing.T) {
+		s := httptest.NewServer(
+			http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}),
+		)
+		defer s.Close()
+
+		numReqs := uint64(4)
+		b, e := NewBombardier(Config{
+			NumConns:   1,
+			NumReqs:    &numReqs,
+			Url:        s.URL,
+			Headers:    new(HeadersList),
+			Timeout:    defaultTimeout,
+			Method:     "GET",
+			Impairment: ImpairmentOptions{DropProbability: 1},
+			ClientType: clientType,
+			Format:     knownFormat("plain-text"),
+		})
+		if e != nil {
+			t.Fatal(e)
+		}
+		b.disableOutput()
+		b.Bombard()
+
+		if b.req2xx != 0 || b.errors.sum() != numReqs {
+			t.Errorf("Expected all requests to fail, but got %v 2xx, "+
+				"errors: %v", b.req2xx, b.errors.byFrequency())
+		}
+	})
+}
@@ -0,0 +1,194 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/tony24681379/bombardier/internal"
+)
+
+// maxLocalAddrs limits the number of addresses a CIDR may expand to.
+const maxLocalAddrs = 1 << 16
+
+// LocalAddrList is the list of local IP addresses or CIDRs, that
+// connections are made from.
+type LocalAddrList []string
+
+// localAddrValue is the value of --local-addr flag, which may hold
+// several comma-separated addresses.
+type localAddrValue struct {
+	list *LocalAddrList
+}
+
+func (v localAddrValue) String() string {
+	return fmt.Sprint(*v.list)
+}
+
+func (v localAddrValue) IsCumulative() bool {
+	return true
+}
+
+func (v localAddrValue) Set(value string) error {
+	for _, addr := range strings.Split(value, ",") {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			return errInvalidLocalAddr
+		}
+		*v.list = append(*v.list, addr)
+	}
+	return nil
+}
+
+// expandLocalAddrs returns addresses from the list, with CIDRs expanded
+// to addresses of their hosts.
+func expandLocalAddrs(list LocalAddrList) ([]net.IP, error) {
+	var ips []net.IP
+	for _, addr := range list {
+		if !strings.Contains(addr, "/") {
+			ip := net.ParseIP(addr)
+			if ip == nil {
+				return nil, errInvalidLocalAddr
+			}
+			ips = append(ips, ip)
+			continue
+		}
+		ip, ipnet, err := net.ParseCIDR(addr)
+		if err != nil {
+			return nil, errInvalidLocalAddr
+		}
+		ones, bits := ipnet.Mask.Size()
+		if bits-ones > 16 {
+			return nil, errTooManyLocalAddrs
+		}
+		first, last := 0, 1<<uint(bits-ones)
+		if ip.To4() != nil && ones < 31 {
+			// Skip network and broadcast addresses
+			first, last = 1, last-1
+		}
+		for i := first; i < last; i++ {
+			host := append(net.IP(nil), ipnet.IP...)
+			for j, carry := len(host)-1, i; carry > 0; j-- {
+				sum := int(host[j]) + carry
+				host[j], carry = byte(sum), sum>>8
+			}
+			ips = append(ips, host)
+		}
+	}
+	if len(ips) > maxLocalAddrs {
+		return nil, errTooManyLocalAddrs
+	}
+	return ips, nil
+}
+
+// localAddrs spreads connections across local addresses round-robin.
+type localAddrs struct {
+	addrs []*net.TCPAddr
+	next  uint64
+
+	sources *sourceStats
+}
+
+func newLocalAddrs(ips []net.IP, sources *sourceStats) *localAddrs {
+	la := &localAddrs{sources: sources}
+	for _, ip := range ips {
+		la.addrs = append(la.addrs, &net.TCPAddr{IP: ip})
+	}
+	return la
+}
+
+// dialContext returns dial function, that binds each connection to
+// the next local address.
+func (la *localAddrs) dialContext(
+	dialer *net.Dialer,
+) func(context.Context, string, string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		i := atomic.AddUint64(&la.next, 1) - 1
+		local := la.addrs[i%uint64(len(la.addrs))]
+		d := *dialer
+		d.LocalAddr = local
+		conn, err := d.DialContext(ctx, network, address)
+		la.sources.record(local.IP.String(), err)
+		return conn, err
+	}
+}
+
+// sourceStats holds numbers of connections made from each local
+// address and errors, that occurred while connecting.
+type sourceStats struct {
+	mu sync.RWMutex
+	m  map[string]*sourceCounters
+}
+
+type sourceCounters struct {
+	conns  uint64
+	errors *errorMap
+}
+
+func newSourceStats() *sourceStats {
+	return &sourceStats{m: make(map[string]*sourceCounters)}
+}
+
+func (s *sourceStats) get(ip string) *sourceCounters {
+	s.mu.RLock()
+	sc, ok := s.m[ip]
+	s.mu.RUnlock()
+	if ok {
+		return sc
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sc, ok = s.m[ip]; !ok {
+		sc = &sourceCounters{errors: newErrorMap()}
+		s.m[ip] = sc
+	}
+	return sc
+}
+
+func (s *sourceStats) record(ip string, err error) {
+	sc := s.get(ip)
+	if err != nil {
+		sc.errors.add(dialErrorKind(err))
+		return
+	}
+	atomic.AddUint64(&sc.conns, 1)
+}
+
+// dialErrorKind strips addresses from the error, so that errors of
+// all connections from the source could be counted together (i.e.
+// EADDRNOTAVAIL shows up as "cannot assign requested address").
+func dialErrorKind(err error) error {
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		return errno
+	}
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return errDialTimeout
+	}
+	return err
+}
+
+func (s *sourceStats) snapshot() map[string]internal.SourceStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := make(map[string]internal.SourceStats, len(s.m))
+	for ip, sc := range s.m {
+		ss := internal.SourceStats{
+			Connections: atomic.LoadUint64(&sc.conns),
+		}
+		for _, ewc := range sc.errors.byFrequency() {
+			ss.Errors = append(ss.Errors, internal.ErrorWithCount{
+				Error: ewc.error,
+				Count: ewc.count,
+			})
+		}
+		res[ip] = ss
+	}
+	return res
+}
@@ -0,0 +1,161 @@
+package lib
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"syscall"
+	"testing"
+)
+
+func TestLocalAddrValue(t *testing.T) {
+	var list LocalAddrList
+	v := localAddrValue{&list}
+	for _, in := range []string{"10.0.0.1", "10.0.1.0/24, fd00::1"} {
+		if err := v.Set(in); err != nil {
+			t.Errorf("%q: expected no error, but got %v", in, err)
+		}
+	}
+	if err := v.Set("10.0.0.1,,10.0.0.2"); err != errInvalidLocalAddr {
+		t.Errorf("Expected %v, but got %v", errInvalidLocalAddr, err)
+	}
+	exp := LocalAddrList{"10.0.0.1", "10.0.1.0/24", "fd00::1", "10.0.0.1"}
+	if !reflect.DeepEqual(list, exp) {
+		t.Errorf("Expected %v, but got %v", exp, list)
+	}
+}
+
+func TestExpandLocalAddrs(t *testing.T) {
+	expectations := []struct {
+		in    LocalAddrList
+		count int
+		first string
+		last  string
+		err   error
+	}{
+		{LocalAddrList{"10.0.0.1"}, 1, "10.0.0.1", "10.0.0.1", nil},
+		{LocalAddrList{"10.0.0.0/30"}, 2, "10.0.0.1", "10.0.0.2", nil},
+		{LocalAddrList{"10.0.0.0/31"}, 2, "10.0.0.0", "10.0.0.1", nil},
+		{LocalAddrList{"10.0.0.5/32"}, 1, "10.0.0.5", "10.0.0.5", nil},
+		{LocalAddrList{"10.0.0.7/23"}, 510, "10.0.0.1", "10.0.1.254", nil},
+		{LocalAddrList{"fd00::/126"}, 4, "fd00::", "fd00::3", nil},
+		{
+			LocalAddrList{"10.0.0.1", "10.0.1.0/24"},
+			255, "10.0.0.1", "10.0.1.254", nil,
+		},
+		{LocalAddrList{"10.0.0.0/8"}, 0, "", "", errTooManyLocalAddrs},
+		{
+			LocalAddrList{"10.0.0.0/16", "10.1.0.0/16"},
+			0, "", "", errTooManyLocalAddrs,
+		},
+		{LocalAddrList{"local"}, 0, "", "", errInvalidLocalAddr},
+		{LocalAddrList{"10.0.0.0/33"}, 0, "", "", errInvalidLocalAddr},
+	}
+	for _, exp := range expectations {
+		ips, err := expandLocalAddrs(exp.in)
+		if err != exp.err || len(ips) != exp.count {
+			t.Errorf("%v: expected %v addresses and %v, but got %v and %v",
+				exp.in, exp.count, exp.err, len(ips), err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		first, last := ips[0].String(), ips[len(ips)-1].String()
+		if first != exp.first || last != exp.last {
+			t.Errorf("%v: expected %v..%v, but got %v..%v",
+				exp.in, exp.first, exp.last, first, last)
+		}
+	}
+}
+
+func TestBombardierBindsLocalAddrs(t *testing.T) {
+	testAllClients(t, testBombardierBindsLocalAddrs)
+}
+
+func testBombardierBindsLocalAddrs(clientType clientTyp, t *testing.T) {
+	var mu sync.Mutex
+	counts := make(map[string]int)
+	s := httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			host, _, _ := net.SplitHostPort(r.RemoteAddr)
+			mu.Lock()
+			counts[host]++
+			mu.Unlock()
+		}),
+	)
+	defer s.Close()
+
+	numReqs := uint64(6)
+	b, e := NewBombardier(Config{
+		NumConns:    1,
+		NumReqs:     &numReqs,
+		Url:         s.URL,
+		Headers:     new(HeadersList),
+		Timeout:     defaultTimeout,
+		Method:      "GET",
+		LocalAddrs:  &LocalAddrList{"127.0.0.2", "127.0.0.3"},
+		Connections: ConnOptions{DisableKeepAlive: true},
+		ClientType:  clientType,
+		Format:      knownFormat("plain-text"),
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	b.disableOutput()
+	b.Bombard()
+
+	if b.req2xx != numReqs {
+		t.Errorf("Expected %v 2xx, but got %v 2xx, errors: %v",
+			numReqs, b.req2xx, b.errors.byFrequency())
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	exp := map[string]int{"127.0.0.2": 3, "127.0.0.3": 3}
+	if !reflect.DeepEqual(counts, exp) {
+		t.Errorf("Expected requests spread as %v, but got %v", exp, counts)
+	}
+	sources := b.sources.snapshot()
+	for ip := range exp {
+		if ss := sources[ip]; ss.Connections != 3 || len(ss.Errors) != 0 {
+			t.Errorf("Unexpected stats of %v: %+v", ip, ss)
+		}
+	}
+}
+
+func TestBombardierReportsUnavailableLocalAddr(t *testing.T) {
+	s := httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}),
+	)
+	defer s.Close()
+
+	numReqs := uint64(2)
+	b, e := NewBombardier(Config{
+		NumConns: 1,
+		NumReqs:  &numReqs,
+		Url:      s.URL,
+		Headers:  new(HeadersList),
+		Timeout:  defaultTimeout,
+		Method:   "GET",
+		// Documentation address, that isn't assigned to any interface
+		LocalAddrs: &LocalAddrList{"192.0.2.1"},
+		ClientType: fhttp,
+		Format:     knownFormat("plain-text"),
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	b.disableOutput()
+	b.Bombard()
+
+	if b.req2xx != 0 {
+		t.Errorf("Expected no 2xx, but got %v", b.req2xx)
+	}
+	ss := b.sources.snapshot()["192.0.2.1"]
+	if ss.Connections != 0 || len(ss.Errors) != 1 ||
+		ss.Errors[0].Error != syscall.EADDRNOTAVAIL.Error() {
+		t.Errorf("Expected EADDRNOTAVAIL to be reported, but got %+v", ss)
+	}
+}
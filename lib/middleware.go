@@ -0,0 +1,195 @@
+package lib
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Request is the request as seen by Middleware.
+type Request interface {
+	Method() string
+	URL() string
+	Header(key string) string
+	SetHeader(key, value string)
+	// Body returns nil if the body is streamed.
+	Body() []byte
+	SetBody(body []byte)
+}
+
+// Response is the response as seen by Middleware.
+type Response interface {
+	StatusCode() int
+	Header(key string) string
+	Body() []byte
+}
+
+// Middleware hooks into the lifecycle of each request performed by
+// built-in clients. Any of the hooks may be nil. Hooks are called
+// concurrently from all connections, so they must be safe for
+// concurrent use. Time spent in hooks is not included in latencies.
+type Middleware struct {
+	// BeforeRequest is called before the request is sent and may
+	// modify it. Returned error fails the request without sending
+	// it.
+	BeforeRequest func(Request) error
+	// AfterResponse is called once the response is received in
+	// full. Returned error marks the request as failed and is
+	// recorded alongside other errors, while the status code is
+	// still recorded as is.
+	AfterResponse func(Request, Response) error
+	// OnError is called when the request fails, either because it
+	// couldn't be performed or because AfterResponse marked it as
+	// failed.
+	OnError func(Request, error)
+}
+
+// WithMiddleware adds middleware to built-in clients. Hooks of
+// several middlewares are called in the order they were provided.
+func WithMiddleware(mw ...Middleware) Option {
+	return func(o *options) {
+		o.middleware = append(o.middleware, mw...)
+	}
+}
+
+type middlewareChain []Middleware
+
+func (mc middlewareChain) beforeRequest(req Request) error {
+	for _, m := range mc {
+		if m.BeforeRequest == nil {
+			continue
+		}
+		if err := m.BeforeRequest(req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (mc middlewareChain) afterResponse(req Request, resp Response) error {
+	for _, m := range mc {
+		if m.AfterResponse == nil {
+			continue
+		}
+		if err := m.AfterResponse(req, resp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (mc middlewareChain) onError(req Request, err error) {
+	for _, m := range mc {
+		if m.OnError != nil {
+			m.OnError(req, err)
+		}
+	}
+}
+
+func (mc middlewareChain) inspectsResponses() bool {
+	for _, m := range mc {
+		if m.AfterResponse != nil {
+			return true
+		}
+	}
+	return false
+}
+
+type fasthttpRequest struct {
+	req      *fasthttp.Request
+	streamed bool
+}
+
+func (r *fasthttpRequest) Method() string {
+	return string(r.req.Header.Method())
+}
+
+func (r *fasthttpRequest) URL() string {
+	return r.req.URI().String()
+}
+
+func (r *fasthttpRequest) Header(key string) string {
+	return string(r.req.Header.Peek(key))
+}
+
+func (r *fasthttpRequest) SetHeader(key, value string) {
+	r.req.Header.Set(key, value)
+}
+
+func (r *fasthttpRequest) Body() []byte {
+	if r.streamed {
+		return nil
+	}
+	return r.req.Body()
+}
+
+func (r *fasthttpRequest) SetBody(body []byte) {
+	r.req.SetBody(body)
+	r.streamed = false
+}
+
+type fasthttpResponse struct {
+	resp *fasthttp.Response
+}
+
+func (r *fasthttpResponse) StatusCode() int {
+	return r.resp.StatusCode()
+}
+
+func (r *fasthttpResponse) Header(key string) string {
+	return string(r.resp.Header.Peek(key))
+}
+
+func (r *fasthttpResponse) Body() []byte {
+	return r.resp.Body()
+}
+
+type httpRequest struct {
+	req  *http.Request
+	body []byte
+}
+
+func (r *httpRequest) Method() string {
+	return r.req.Method
+}
+
+func (r *httpRequest) URL() string {
+	return r.req.URL.String()
+}
+
+func (r *httpRequest) Header(key string) string {
+	return r.req.Header.Get(key)
+}
+
+func (r *httpRequest) SetHeader(key, value string) {
+	r.req.Header.Set(key, value)
+}
+
+func (r *httpRequest) Body() []byte {
+	return r.body
+}
+
+func (r *httpRequest) SetBody(body []byte) {
+	r.body = body
+	r.req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	r.req.ContentLength = int64(len(body))
+}
+
+type httpResponse struct {
+	resp *http.Response
+	body []byte
+}
+
+func (r *httpResponse) StatusCode() int {
+	return r.resp.StatusCode
+}
+
+func (r *httpResponse) Header(key string) string {
+	return r.resp.Header.Get(key)
+}
+
+func (r *httpResponse) Body() []byte {
+	return r.body
+}
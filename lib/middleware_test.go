@@ -0,0 +1,145 @@
+package lib
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestBombardierMiddleware(t *testing.T) {
+	testAllClients(t, testBombardierMiddleware)
+}
+
+func testBombardierMiddleware(clientType clientTyp, t *testing.T) {
+	s := httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-Signature") != "signed:abracadabra" {
+				rw.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			rw.Header().Set("X-Check", "passed")
+			_, err := rw.Write([]byte("OK"))
+			if err != nil {
+				t.Error(err)
+			}
+		}),
+	)
+	defer s.Close()
+	errUnexpectedBody := errors.New("unexpected body")
+	var calls []string
+	var (
+		afterCalls, errorCalls uint64
+	)
+	signing := Middleware{
+		BeforeRequest: func(r Request) error {
+			r.SetHeader("X-Signature", "signed:"+string(r.Body()))
+			return nil
+		},
+	}
+	checking := Middleware{
+		AfterResponse: func(r Request, resp Response) error {
+			atomic.AddUint64(&afterCalls, 1)
+			if resp.StatusCode() != http.StatusOK ||
+				resp.Header("X-Check") != "passed" {
+				t.Errorf("Unexpected response: %v", resp.StatusCode())
+			}
+			if string(resp.Body()) != "NOT OK" {
+				return errUnexpectedBody
+			}
+			return nil
+		},
+		OnError: func(r Request, err error) {
+			if err != errUnexpectedBody {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			atomic.AddUint64(&errorCalls, 1)
+		},
+	}
+	ordering := Middleware{
+		BeforeRequest: func(r Request) error {
+			// Requests are sent one at a time, so there is no race
+			if r.Header("X-Signature") == "" {
+				t.Error("Middlewares were called out of order")
+			}
+			calls = append(calls, r.Method()+" "+r.URL())
+			return nil
+		},
+	}
+	numReqs := uint64(10)
+	b, e := NewBombardier(Config{
+		NumConns:   1,
+		NumReqs:    &numReqs,
+		Url:        s.URL,
+		Headers:    new(HeadersList),
+		Timeout:    defaultTimeout,
+		Method:     "POST",
+		Body:       "abracadabra",
+		ClientType: clientType,
+		Format:     knownFormat("plain-text"),
+	}, WithMiddleware(signing, checking, ordering))
+	if e != nil {
+		t.Error(e)
+		return
+	}
+	b.disableOutput()
+	b.Bombard()
+	if b.req2xx != numReqs {
+		t.Errorf("Expected %v 2xx, but got %v", numReqs, b.req2xx)
+	}
+	if afterCalls != numReqs || errorCalls != numReqs {
+		t.Errorf("Unexpected number of calls: %v, %v", afterCalls, errorCalls)
+	}
+	if c := b.errors.get(errUnexpectedBody); c != numReqs {
+		t.Errorf("Expected %v errors, but got %v", numReqs, c)
+	}
+	if uint64(len(calls)) != numReqs || calls[0] != "POST "+s.URL+"/" &&
+		calls[0] != "POST "+s.URL {
+		t.Errorf("Unexpected calls: %v", calls)
+	}
+}
+
+func TestBombardierMiddlewareCanFailRequests(t *testing.T) {
+	testAllClients(t, testBombardierMiddlewareCanFailRequests)
+}
+
+func testBombardierMiddlewareCanFailRequests(
+	clientType clientTyp, t *testing.T,
+) {
+	reqsReceived := uint64(0)
+	s := httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			atomic.AddUint64(&reqsReceived, 1)
+		}),
+	)
+	defer s.Close()
+	errRefused := errors.New("refused")
+	numReqs := uint64(10)
+	b, e := NewBombardier(Config{
+		NumConns:   defaultNumberOfConns,
+		NumReqs:    &numReqs,
+		Url:        s.URL,
+		Headers:    new(HeadersList),
+		Timeout:    defaultTimeout,
+		Method:     "GET",
+		ClientType: clientType,
+		Format:     knownFormat("plain-text"),
+	}, WithMiddleware(Middleware{
+		BeforeRequest: func(Request) error {
+			return errRefused
+		},
+	}))
+	if e != nil {
+		t.Error(e)
+		return
+	}
+	b.disableOutput()
+	b.Bombard()
+	if reqsReceived != 0 {
+		t.Errorf("No requests should be sent, but got %v", reqsReceived)
+	}
+	if c := b.errors.get(errRefused); c != numReqs {
+		t.Errorf("Expected %v errors, but got %v", numReqs, c)
+	}
+}
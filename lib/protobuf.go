@@ -0,0 +1,142 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// descriptors holds protobuf types and gRPC methods, that were read
+// from FileDescriptorProtos, either from a descriptor set or using
+// server reflection.
+type descriptors struct {
+	files *protoregistry.Files
+	// types resolve messages packed into google.protobuf.Any
+	types *dynamicpb.Types
+}
+
+// newDescriptors builds descriptors from the set, which must contain
+// every file that its files import.
+func newDescriptors(
+	set *descriptorpb.FileDescriptorSet,
+) (*descriptors, error) {
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, err
+	}
+	return &descriptors{files: files, types: dynamicpb.NewTypes(files)}, nil
+}
+
+// readProtoset reads descriptors from FileDescriptorSet, i.e. one
+// produced by protoc --include_imports --descriptor_set_out.
+func readProtoset(path string) (*descriptors, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	set := new(descriptorpb.FileDescriptorSet)
+	if err = proto.Unmarshal(data, set); err != nil {
+		return nil, err
+	}
+	return newDescriptors(set)
+}
+
+// method looks up the method by its name, i.e. package.Service/Method.
+func (d *descriptors) method(
+	name string,
+) (protoreflect.MethodDescriptor, error) {
+	service := grpcServiceOf(name)
+	method := strings.TrimPrefix(name[len(service):], "/")
+	desc, err := d.files.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return nil, &unknownGRPCMethodError{name}
+	}
+	s, ok := desc.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, &unknownGRPCMethodError{name}
+	}
+	m := s.Methods().ByName(protoreflect.Name(method))
+	if m == nil {
+		return nil, &unknownGRPCMethodError{name}
+	}
+	return m, nil
+}
+
+type unknownGRPCMethodError struct {
+	name string
+}
+
+func (u *unknownGRPCMethodError) Error() string {
+	return fmt.Sprintf("Unknown gRPC method: %v", u.name)
+}
+
+// invalidJSONMessageError tells why JSON message couldn't be encoded.
+type invalidJSONMessageError struct {
+	err error
+}
+
+func (i *invalidJSONMessageError) Error() string {
+	return fmt.Sprintf("Invalid JSON message: %v", i.err)
+}
+
+// encodeJSON encodes JSON message as protobuf message of the given
+// type, following protobuf's JSON mapping. Empty JSON is an empty
+// message.
+func (d *descriptors) encodeJSON(
+	desc protoreflect.MessageDescriptor, msg []byte,
+) ([]byte, error) {
+	if len(bytes.TrimSpace(msg)) == 0 {
+		return nil, nil
+	}
+	m := dynamicpb.NewMessage(desc)
+	opts := protojson.UnmarshalOptions{Resolver: d.types}
+	if err := opts.Unmarshal(msg, m); err != nil {
+		return nil, &invalidJSONMessageError{err}
+	}
+	return proto.MarshalOptions{Deterministic: true}.Marshal(m)
+}
+
+// forEachProtoField calls f for each field of the encoded message
+// with its number and either its varint value or its bytes,
+// depending on the wire type. Fields of other types are skipped.
+func forEachProtoField(
+	msg []byte, f func(num protowire.Number, v uint64, b []byte) error,
+) error {
+	for len(msg) > 0 {
+		num, typ, n := protowire.ConsumeTag(msg)
+		if n < 0 {
+			return errInvalidProtobuf
+		}
+		msg = msg[n:]
+		var (
+			v uint64
+			b []byte
+		)
+		switch typ {
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(msg)
+		case protowire.BytesType:
+			b, n = protowire.ConsumeBytes(msg)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, msg)
+		}
+		if n < 0 {
+			return errInvalidProtobuf
+		}
+		msg = msg[n:]
+		if err := f(num, v, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,318 @@
+package lib
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// protoMsg encodes message from pairs of field numbers and values,
+// that are either strings, encoded messages or varints.
+func protoMsg(fields ...interface{}) []byte {
+	var b []byte
+	for i := 0; i < len(fields); i += 2 {
+		num := protowire.Number(fields[i].(int))
+		switch v := fields[i+1].(type) {
+		case string:
+			b = protowire.AppendTag(b, num, protowire.BytesType)
+			b = protowire.AppendString(b, v)
+		case []byte:
+			b = protowire.AppendTag(b, num, protowire.BytesType)
+			b = protowire.AppendBytes(b, v)
+		case int:
+			b = protowire.AppendTag(b, num, protowire.VarintType)
+			b = protowire.AppendVarint(b, uint64(v))
+		}
+	}
+	return b
+}
+
+func protoField(
+	name string, num int32, typ descriptorpb.FieldDescriptorProto_Type,
+) *descriptorpb.FieldDescriptorProto {
+	return &descriptorpb.FieldDescriptorProto{
+		Name:   proto.String(name),
+		Number: proto.Int32(num),
+		Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:   typ.Enum(),
+	}
+}
+
+func protoMessageField(
+	name string, num int32, typeName string,
+) *descriptorpb.FieldDescriptorProto {
+	f := protoField(name, num, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE)
+	f.TypeName = proto.String(typeName)
+	return f
+}
+
+func repeated(
+	f *descriptorpb.FieldDescriptorProto,
+) *descriptorpb.FieldDescriptorProto {
+	f.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	return f
+}
+
+// testProtoFile returns descriptor of the following file:
+//
+//	syntax = "proto3";
+//	package test;
+//	import "google/protobuf/timestamp.proto";
+//	enum Kind { KIND_UNKNOWN = 0; KIND_FIRST = 1; }
+//	message Inner { string note = 1; }
+//	message Request {
+//	  string name = 1;
+//	  int32 count = 2;
+//	  repeated int64 ids = 3;
+//	  Inner inner = 4;
+//	  map<string, int32> tags = 5;
+//	  Kind kind = 6;
+//	  bytes data = 7;
+//	  string user_name = 8;
+//	  google.protobuf.Timestamp at = 9;
+//	}
+//	service Service { rpc Method(Request) returns (Inner); }
+func testProtoFile() *descriptorpb.FileDescriptorProto {
+	kind := protoField("kind", 6, descriptorpb.FieldDescriptorProto_TYPE_ENUM)
+	kind.TypeName = proto.String(".test.Kind")
+	userName := protoField("user_name", 8,
+		descriptorpb.FieldDescriptorProto_TYPE_STRING)
+	userName.JsonName = proto.String("userName")
+	return &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("test.proto"),
+		Package:    proto.String("test"),
+		Dependency: []string{"google/protobuf/timestamp.proto"},
+		Syntax:     proto.String("proto3"),
+		EnumType: []*descriptorpb.EnumDescriptorProto{{
+			Name: proto.String("Kind"),
+			Value: []*descriptorpb.EnumValueDescriptorProto{
+				{Name: proto.String("KIND_UNKNOWN"), Number: proto.Int32(0)},
+				{Name: proto.String("KIND_FIRST"), Number: proto.Int32(1)},
+			},
+		}},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Inner"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				protoField("note", 1,
+					descriptorpb.FieldDescriptorProto_TYPE_STRING),
+			},
+		}, {
+			Name: proto.String("Request"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				protoField("name", 1,
+					descriptorpb.FieldDescriptorProto_TYPE_STRING),
+				protoField("count", 2,
+					descriptorpb.FieldDescriptorProto_TYPE_INT32),
+				repeated(protoField("ids", 3,
+					descriptorpb.FieldDescriptorProto_TYPE_INT64)),
+				protoMessageField("inner", 4, ".test.Inner"),
+				repeated(protoMessageField("tags", 5,
+					".test.Request.TagsEntry")),
+				kind,
+				protoField("data", 7,
+					descriptorpb.FieldDescriptorProto_TYPE_BYTES),
+				userName,
+				protoMessageField("at", 9, ".google.protobuf.Timestamp"),
+			},
+			NestedType: []*descriptorpb.DescriptorProto{{
+				Name: proto.String("TagsEntry"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					protoField("key", 1,
+						descriptorpb.FieldDescriptorProto_TYPE_STRING),
+					protoField("value", 2,
+						descriptorpb.FieldDescriptorProto_TYPE_INT32),
+				},
+				Options: &descriptorpb.MessageOptions{
+					MapEntry: proto.Bool(true),
+				},
+			}},
+		}},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Service"),
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:       proto.String("Method"),
+				InputType:  proto.String(".test.Request"),
+				OutputType: proto.String(".test.Inner"),
+			}},
+		}},
+	}
+}
+
+func testTimestampFile() *descriptorpb.FileDescriptorProto {
+	return &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("google/protobuf/timestamp.proto"),
+		Package: proto.String("google.protobuf"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Timestamp"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				protoField("seconds", 1,
+					descriptorpb.FieldDescriptorProto_TYPE_INT64),
+				protoField("nanos", 2,
+					descriptorpb.FieldDescriptorProto_TYPE_INT32),
+			},
+		}},
+	}
+}
+
+func testProtoset() *descriptorpb.FileDescriptorSet {
+	return &descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{
+			testTimestampFile(), testProtoFile(),
+		},
+	}
+}
+
+// writeTestProtoset writes descriptor set of test.proto and returns
+// path to it.
+func writeTestProtoset(t *testing.T) string {
+	f, err := ioutil.TempFile("", "protoset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	set, err := proto.Marshal(testProtoset())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.Write(set); err != nil {
+		t.Fatal(err)
+	}
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return f.Name()
+}
+
+func TestReadProtoset(t *testing.T) {
+	path := writeTestProtoset(t)
+	defer func() {
+		_ = os.Remove(path)
+	}()
+	d, err := readProtoset(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := d.method("test.Service/Method")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if in := m.Input().FullName(); in != "test.Request" {
+		t.Errorf("Expected test.Request input, but got %v", in)
+	}
+	if out := m.Output().FullName(); out != "test.Inner" {
+		t.Errorf("Expected test.Inner output, but got %v", out)
+	}
+	for _, name := range []string{
+		"test.Service/Other", "test.Request/Method", "other.Service/Method",
+	} {
+		_, err = d.method(name)
+		if _, ok := err.(*unknownGRPCMethodError); !ok {
+			t.Errorf("%v: expected unknown method, but got %v", name, err)
+		}
+	}
+}
+
+func TestReadProtosetErrors(t *testing.T) {
+	f, err := ioutil.TempFile("", "protoset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.Remove(f.Name())
+	}()
+	// test.proto without the file it imports
+	set, err := proto.Marshal(&descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{testProtoFile()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.Write(set); err != nil {
+		t.Fatal(err)
+	}
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = readProtoset(f.Name()); err == nil {
+		t.Error("Expected an error for missing import")
+	}
+	if _, err = readProtoset(f.Name() + ".missing"); err == nil {
+		t.Error("Expected an error for missing file")
+	}
+}
+
+func TestEncodeJSON(t *testing.T) {
+	d, err := newDescriptors(testProtoset())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := d.method("test.Service/Method")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectations := []struct {
+		in  string
+		out []byte
+		err bool
+	}{
+		{"", nil, false},
+		{" {} ", nil, false},
+		{`{"name":"x"}`, []byte{0x0a, 0x01, 'x'}, false},
+		{`{"name":null}`, nil, false},
+		{`{"count":"2"}`, []byte{0x10, 0x02}, false},
+		{`{"ids":[1,"300"]}`, []byte{0x1a, 0x03, 0x01, 0xac, 0x02}, false},
+		{`{"inner":{"note":"n"}}`, []byte{0x22, 0x03, 0x0a, 0x01, 'n'}, false},
+		{
+			`{"tags":{"b":2,"a":1}}`,
+			[]byte{
+				0x2a, 0x05, 0x0a, 0x01, 'a', 0x10, 0x01,
+				0x2a, 0x05, 0x0a, 0x01, 'b', 0x10, 0x02,
+			},
+			false,
+		},
+		{`{"kind":"KIND_FIRST"}`, []byte{0x30, 0x01}, false},
+		{`{"data":"AQI="}`, []byte{0x3a, 0x02, 0x01, 0x02}, false},
+		{`{"userName":"u"}`, []byte{0x42, 0x01, 'u'}, false},
+		{`{"user_name":"u"}`, []byte{0x42, 0x01, 'u'}, false},
+		{
+			`{"at":"1970-01-01T00:00:01.5Z"}`,
+			[]byte{0x4a, 0x08, 0x08, 0x01, 0x10, 0x80, 0xca, 0xb5, 0xee, 0x01},
+			false,
+		},
+		{`{"unknown":1}`, nil, true},
+		{`{"name":1}`, nil, true},
+		{`{"count":1.5}`, nil, true},
+		{`{"kind":"KIND_OTHER"}`, nil, true},
+		{`{"at":"yesterday"}`, nil, true},
+		{`[]`, nil, true},
+		{`{} {}`, nil, true},
+	}
+	for _, exp := range expectations {
+		out, err := d.encodeJSON(m.Input(), []byte(exp.in))
+		if _, ok := err.(*invalidJSONMessageError); ok != exp.err ||
+			!bytes.Equal(out, exp.out) {
+			t.Errorf("%v: expected %x, but got (%x, %v)",
+				exp.in, exp.out, out, err)
+		}
+	}
+}
+
+func TestForEachProtoFieldRejectsTruncatedMessages(t *testing.T) {
+	msg := protoMsg(1, "string")
+	for i := 1; i < len(msg); i++ {
+		err := forEachProtoField(msg[:i], func(
+			protowire.Number, uint64, []byte,
+		) error {
+			return nil
+		})
+		if err != errInvalidProtobuf {
+			t.Errorf("%x: expected %v, but got %v",
+				msg[:i], errInvalidProtobuf, err)
+		}
+	}
+}
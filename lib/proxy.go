@@ -0,0 +1,327 @@
+package lib
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	uhist "github.com/codesenberg/concurrent/uint64/histogram"
+)
+
+var defaultProxyPorts = map[string]string{
+	"http":    "80",
+	"https":   "443",
+	"socks5":  "1080",
+	"socks5h": "1080",
+}
+
+// proxyDialer establishes tunnels to targets through HTTP(S) proxies,
+// using CONNECT method, or SOCKS5 proxies.
+type proxyDialer struct {
+	dial func(context.Context, string, string) (net.Conn, error)
+
+	proxy *url.URL
+	addr  string
+	// tlsConfig is nil unless connections to the proxy itself are
+	// encrypted
+	tlsConfig *tls.Config
+	timeout   time.Duration
+
+	// connects holds time taken to establish tunnels, in
+	// microseconds, if not nil
+	connects *uhist.Histogram
+}
+
+func newProxyDialer(
+	opts *clientOpts,
+	dial func(context.Context, string, string) (net.Conn, error),
+) *proxyDialer {
+	d := &proxyDialer{
+		dial:     dial,
+		proxy:    opts.proxy,
+		timeout:  opts.timeout,
+		connects: opts.proxyConnects,
+	}
+	port := d.proxy.Port()
+	if port == "" {
+		port = defaultProxyPorts[d.proxy.Scheme]
+	}
+	d.addr = net.JoinHostPort(d.proxy.Hostname(), port)
+	if d.proxy.Scheme == "https" {
+		d.tlsConfig = &tls.Config{ServerName: d.proxy.Hostname()}
+		if opts.tlsConfig != nil {
+			d.tlsConfig.InsecureSkipVerify = opts.tlsConfig.InsecureSkipVerify
+		}
+	}
+	return d
+}
+
+func (d *proxyDialer) dialContext(
+	ctx context.Context, _, address string,
+) (net.Conn, error) {
+	start := time.Now()
+	conn, err := d.dial(ctx, "tcp", d.addr)
+	if err != nil {
+		return nil, err
+	}
+	tunnel, err := d.handshake(ctx, conn, address)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if d.connects != nil {
+		d.connects.Increment(usSince(start, time.Now()))
+	}
+	return tunnel, nil
+}
+
+// handshake establishes tunnel to address over conn, which is wrapped
+// into TLS connection, if the proxy requires so.
+func (d *proxyDialer) handshake(
+	ctx context.Context, conn net.Conn, address string,
+) (net.Conn, error) {
+	raw := conn
+	if d.timeout > 0 {
+		_ = raw.SetDeadline(time.Now().Add(d.timeout))
+	}
+	// Unblock the handshake, if the test is aborted meanwhile
+	done, exited := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+			_ = raw.SetDeadline(time.Unix(1, 0))
+		case <-done:
+		}
+	}()
+	defer func() {
+		// The deadline is cleared only once it can't be set anymore
+		close(done)
+		<-exited
+		_ = raw.SetDeadline(time.Time{})
+	}()
+
+	if d.tlsConfig != nil {
+		tlsConn := tls.Client(conn, d.tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			return nil, err
+		}
+		conn = tlsConn
+	}
+	var err error
+	switch d.proxy.Scheme {
+	case "http", "https":
+		err = d.connectHTTP(conn, address)
+	default:
+		err = d.connectSOCKS5(ctx, conn, address)
+	}
+	return conn, err
+}
+
+type proxyStatusError struct {
+	status string
+}
+
+func (p *proxyStatusError) Error() string {
+	return fmt.Sprintf("Proxy refused to connect: %v", p.status)
+}
+
+func (d *proxyDialer) connectHTTP(conn net.Conn, address string) error {
+	req := &http.Request{
+		Method: "CONNECT",
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: make(http.Header),
+	}
+	if user := d.proxy.User; user != nil {
+		password, _ := user.Password()
+		credentials := base64.StdEncoding.EncodeToString(
+			[]byte(user.Username() + ":" + password),
+		)
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		return err
+	}
+	// Nothing is sent through the tunnel before the client speaks,
+	// so buffered reader can be safely discarded afterwards
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return &proxyStatusError{resp.Status}
+	}
+	return nil
+}
+
+const (
+	socks5Version = 5
+
+	socks5NoAuth       = 0
+	socks5UserPassAuth = 2
+	socks5NoMethods    = 0xff
+
+	socks5Connect = 1
+
+	socks5IPv4   = 1
+	socks5Domain = 3
+	socks5IPv6   = 4
+)
+
+var socks5Replies = [...]string{
+	"succeeded",
+	"general SOCKS server failure",
+	"connection not allowed by ruleset",
+	"network unreachable",
+	"host unreachable",
+	"connection refused",
+	"TTL expired",
+	"command not supported",
+	"address type not supported",
+}
+
+type socks5ReplyError struct {
+	code byte
+}
+
+func (s *socks5ReplyError) Error() string {
+	reply := "unknown error"
+	if int(s.code) < len(socks5Replies) {
+		reply = socks5Replies[s.code]
+	}
+	return fmt.Sprintf("SOCKS5 proxy refused to connect: %v", reply)
+}
+
+// connectSOCKS5 performs SOCKS5 handshake, as described in RFC 1928,
+// authenticating with username and password (RFC 1929), if the proxy
+// asks for it. Host names are resolved by the proxy, unless socks5
+// scheme is used.
+func (d *proxyDialer) connectSOCKS5(
+	ctx context.Context, conn net.Conn, address string,
+) error {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil && d.proxy.Scheme == "socks5" {
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return err
+		}
+		ip = addrs[0].IP
+	}
+
+	methods := []byte{socks5NoAuth}
+	if d.proxy.User != nil {
+		methods = append(methods, socks5UserPassAuth)
+	}
+	greeting := append([]byte{socks5Version, byte(len(methods))}, methods...)
+	if _, err := conn.Write(greeting); err != nil {
+		return err
+	}
+	var choice [2]byte
+	if _, err := io.ReadFull(conn, choice[:]); err != nil {
+		return err
+	}
+	if choice[0] != socks5Version {
+		return errInvalidSOCKS5Response
+	}
+	switch choice[1] {
+	case socks5NoAuth:
+	case socks5UserPassAuth:
+		if err := d.authenticateSOCKS5(conn); err != nil {
+			return err
+		}
+	case socks5NoMethods:
+		return errSOCKS5AuthRequired
+	default:
+		return errInvalidSOCKS5Response
+	}
+
+	req := []byte{socks5Version, socks5Connect, 0}
+	switch {
+	case ip.To4() != nil:
+		req = append(req, socks5IPv4)
+		req = append(req, ip.To4()...)
+	case ip != nil:
+		req = append(req, socks5IPv6)
+		req = append(req, ip.To16()...)
+	default:
+		if len(host) > 255 {
+			return errInvalidSOCKS5Address
+		}
+		req = append(req, socks5Domain, byte(len(host)))
+		req = append(req, host...)
+	}
+	req = append(req, byte(port>>8), byte(port))
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	var reply [4]byte
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		return err
+	}
+	if reply[0] != socks5Version {
+		return errInvalidSOCKS5Response
+	}
+	if reply[1] != 0 {
+		return &socks5ReplyError{reply[1]}
+	}
+	// Skip the bound address and port, that are of no use here
+	var boundLen int
+	switch reply[3] {
+	case socks5IPv4:
+		boundLen = net.IPv4len
+	case socks5IPv6:
+		boundLen = net.IPv6len
+	case socks5Domain:
+		var l [1]byte
+		if _, err := io.ReadFull(conn, l[:]); err != nil {
+			return err
+		}
+		boundLen = int(l[0])
+	default:
+		return errInvalidSOCKS5Response
+	}
+	_, err = io.ReadFull(conn, make([]byte, boundLen+2))
+	return err
+}
+
+func (d *proxyDialer) authenticateSOCKS5(conn net.Conn) error {
+	username := d.proxy.User.Username()
+	password, _ := d.proxy.User.Password()
+	if len(username) > 255 || len(password) > 255 {
+		return errInvalidSOCKS5Credentials
+	}
+	req := []byte{1, byte(len(username))}
+	req = append(req, username...)
+	req = append(req, byte(len(password)))
+	req = append(req, password...)
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+	var resp [2]byte
+	if _, err := io.ReadFull(conn, resp[:]); err != nil {
+		return err
+	}
+	if resp[1] != 0 {
+		return errSOCKS5AuthFailed
+	}
+	return nil
+}
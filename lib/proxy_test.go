@@ -0,0 +1,323 @@
+package lib
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// testProxy is a minimal HTTP CONNECT or SOCKS5 proxy, that requires
+// authentication, if username is set.
+type testProxy struct {
+	t        *testing.T
+	listener net.Listener
+	socks5   bool
+
+	username, password string
+
+	tunnels uint64
+	ml      sync.Mutex
+	// targets holds addresses requested by clients
+	targets []string
+}
+
+func newTestProxy(t *testing.T, socks5 bool) *testProxy {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &testProxy{t: t, listener: l, socks5: socks5}
+	go p.serve()
+	return p
+}
+
+func (p *testProxy) url() string {
+	scheme := "http"
+	if p.socks5 {
+		scheme = "socks5h"
+	}
+	userinfo := ""
+	if p.username != "" {
+		userinfo = p.username + ":" + p.password + "@"
+	}
+	return scheme + "://" + userinfo + p.listener.Addr().String()
+}
+
+func (p *testProxy) serve() {
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			return
+		}
+		go p.handle(conn)
+	}
+}
+
+func (p *testProxy) handle(conn net.Conn) {
+	defer func() {
+		_ = conn.Close()
+	}()
+	br := bufio.NewReader(conn)
+	var target string
+	var ok bool
+	if p.socks5 {
+		target, ok = p.handshakeSOCKS5(conn, br)
+	} else {
+		target, ok = p.handshakeHTTP(conn, br)
+	}
+	if !ok {
+		return
+	}
+	p.ml.Lock()
+	p.targets = append(p.targets, target)
+	p.ml.Unlock()
+	// Test targets are always local
+	host, port, _ := net.SplitHostPort(target)
+	if host == "localhost" {
+		target = net.JoinHostPort("127.0.0.1", port)
+	}
+	upstream, err := net.Dial("tcp", target)
+	if err != nil {
+		p.t.Error(err)
+		return
+	}
+	defer func() {
+		_ = upstream.Close()
+	}()
+	atomic.AddUint64(&p.tunnels, 1)
+	if p.socks5 {
+		_, _ = conn.Write([]byte{5, 0, 0, 1, 127, 0, 0, 1, 0, 0})
+	} else {
+		_, _ = io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+	}
+	go func() {
+		_, _ = io.Copy(upstream, br)
+		_ = upstream.Close()
+	}()
+	_, _ = io.Copy(conn, upstream)
+}
+
+func (p *testProxy) handshakeHTTP(
+	conn net.Conn, br *bufio.Reader,
+) (string, bool) {
+	req, err := http.ReadRequest(br)
+	if err != nil {
+		return "", false
+	}
+	if req.Method != "CONNECT" {
+		p.t.Errorf("Expected CONNECT, but got %v", req.Method)
+		return "", false
+	}
+	if p.username != "" {
+		exp := "Basic " + base64.StdEncoding.EncodeToString(
+			[]byte(p.username+":"+p.password),
+		)
+		if req.Header.Get("Proxy-Authorization") != exp {
+			_, _ = io.WriteString(conn,
+				"HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+			return "", false
+		}
+	}
+	return req.Host, true
+}
+
+func (p *testProxy) handshakeSOCKS5(
+	conn net.Conn, br *bufio.Reader,
+) (string, bool) {
+	var greeting [2]byte
+	if _, err := io.ReadFull(br, greeting[:]); err != nil {
+		return "", false
+	}
+	methods := make([]byte, greeting[1])
+	if _, err := io.ReadFull(br, methods); err != nil {
+		return "", false
+	}
+	if p.username == "" {
+		_, _ = conn.Write([]byte{5, 0})
+	} else {
+		if !strings.Contains(string(methods), "\x02") {
+			_, _ = conn.Write([]byte{5, 0xff})
+			return "", false
+		}
+		_, _ = conn.Write([]byte{5, 2})
+		var ver, l [1]byte
+		_, _ = io.ReadFull(br, ver[:])
+		_, _ = io.ReadFull(br, l[:])
+		username := make([]byte, l[0])
+		_, _ = io.ReadFull(br, username)
+		_, _ = io.ReadFull(br, l[:])
+		password := make([]byte, l[0])
+		_, _ = io.ReadFull(br, password)
+		if string(username) != p.username || string(password) != p.password {
+			_, _ = conn.Write([]byte{1, 1})
+			return "", false
+		}
+		_, _ = conn.Write([]byte{1, 0})
+	}
+	var req [4]byte
+	if _, err := io.ReadFull(br, req[:]); err != nil {
+		return "", false
+	}
+	var host string
+	switch req[3] {
+	case socks5IPv4:
+		ip := make([]byte, net.IPv4len)
+		_, _ = io.ReadFull(br, ip)
+		host = net.IP(ip).String()
+	case socks5Domain:
+		var l [1]byte
+		_, _ = io.ReadFull(br, l[:])
+		name := make([]byte, l[0])
+		_, _ = io.ReadFull(br, name)
+		host = string(name)
+	default:
+		_, _ = conn.Write([]byte{5, 8, 0, 1, 0, 0, 0, 0, 0, 0})
+		return "", false
+	}
+	var port [2]byte
+	if _, err := io.ReadFull(br, port[:]); err != nil {
+		return "", false
+	}
+	portStr := strconv.Itoa(int(binary.BigEndian.Uint16(port[:])))
+	return net.JoinHostPort(host, portStr), true
+}
+
+func (p *testProxy) Close() {
+	_ = p.listener.Close()
+}
+
+func TestBombardierHTTPProxy(t *testing.T) {
+	testAllClients(t, func(clientType clientTyp, t *testing.T) {
+		testBombardierProxy(clientType, t, false)
+	})
+}
+
+func TestBombardierSOCKS5Proxy(t *testing.T) {
+	testAllClients(t, func(clientType clientTyp, t *testing.T) {
+		testBombardierProxy(clientType, t, true)
+	})
+}
+
+func testBombardierProxy(clientType clientTyp, t *testing.T, socks5 bool) {
+	s := httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}),
+	)
+	defer s.Close()
+	p := newTestProxy(t, socks5)
+	p.username, p.password = "user", "secret"
+	defer p.Close()
+	// Let the proxy resolve the host name
+	url := strings.Replace(s.URL, "127.0.0.1", "localhost", 1)
+	numReqs := uint64(10)
+	b, e := NewBombardier(Config{
+		NumConns:   2,
+		NumReqs:    &numReqs,
+		Url:        url,
+		Headers:    new(HeadersList),
+		Timeout:    defaultTimeout,
+		Method:     "GET",
+		ClientType: clientType,
+		Proxy:      p.url(),
+		Format:     knownFormat("plain-text"),
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	b.disableOutput()
+	b.Bombard()
+
+	if b.req2xx != numReqs {
+		t.Errorf("Expected %v 2xx, but got %v, errors: %v",
+			numReqs, b.req2xx, b.errors.byFrequency())
+	}
+	tunnels := atomic.LoadUint64(&p.tunnels)
+	if tunnels == 0 || tunnels > b.Conf.NumConns {
+		t.Errorf("Expected up to %v tunnels, but got %v",
+			b.Conf.NumConns, tunnels)
+	}
+	p.ml.Lock()
+	for _, target := range p.targets {
+		if !strings.HasPrefix(target, "localhost:") {
+			t.Errorf("Expected host name to be sent, but got %v", target)
+		}
+	}
+	p.ml.Unlock()
+	info := b.gatherInfo()
+	if c := info.Result.ProxyConnects.Count(); c != tunnels {
+		t.Errorf("Expected %v proxy connects, but got %v", tunnels, c)
+	}
+	if strings.Contains(info.Spec.Proxy, "secret") {
+		t.Errorf("Expected password to be redacted, but got %v",
+			info.Spec.Proxy)
+	}
+}
+
+func TestBombardierProxyRejectsCredentials(t *testing.T) {
+	expectations := []struct {
+		socks5 bool
+		err    string
+	}{
+		{false, "Proxy refused to connect: " +
+			"407 Proxy Authentication Required"},
+		{true, errSOCKS5AuthFailed.Error()},
+	}
+	for _, exp := range expectations {
+		p := newTestProxy(t, exp.socks5)
+		p.username, p.password = "user", "secret"
+		url := strings.Replace(p.url(), "secret", "wrong", 1)
+		numReqs := uint64(3)
+		b, e := NewBombardier(Config{
+			NumConns:   1,
+			NumReqs:    &numReqs,
+			Url:        "http://localhost:8080",
+			Headers:    new(HeadersList),
+			Timeout:    defaultTimeout,
+			Method:     "GET",
+			ClientType: nhttp1,
+			Proxy:      url,
+			Format:     knownFormat("plain-text"),
+		})
+		if e != nil {
+			t.Fatal(e)
+		}
+		b.disableOutput()
+		b.Bombard()
+		p.Close()
+
+		if b.others != numReqs {
+			t.Errorf("Expected %v failures, but got %v", numReqs, b.others)
+		}
+		errs := b.errors.byFrequency()
+		if len(errs) != 1 || !strings.Contains(errs[0].error, exp.err) {
+			t.Errorf("Expected %q, but got %v", exp.err, errs)
+		}
+		if c := b.gatherInfo().Result.ProxyConnects.Count(); c != 0 {
+			t.Errorf("Expected failed connects not to be recorded, "+
+				"but got %v", c)
+		}
+	}
+}
+
+func TestSOCKS5ReplyError(t *testing.T) {
+	expectations := []struct {
+		code byte
+		out  string
+	}{
+		{5, "SOCKS5 proxy refused to connect: connection refused"},
+		{42, "SOCKS5 proxy refused to connect: unknown error"},
+	}
+	for _, exp := range expectations {
+		if act := (&socks5ReplyError{exp.code}).Error(); act != exp.out {
+			t.Errorf("Expected %q, but got %q", exp.out, act)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package lib
+
+import (
+	crand "crypto/rand"
+	"io"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	uhist "github.com/codesenberg/concurrent/uint64/histogram"
+)
+
+type sizeDistribution int
+
+const (
+	fixedSize sizeDistribution = iota
+	uniformSize
+	logNormalSize
+)
+
+const defaultSizeSigma = 1.0
+
+// maxSizeFactor caps log-normally distributed sizes at the multiple of
+// the median, so that rare outliers of its long tail don't turn into
+// bodies of gigabytes.
+const maxSizeFactor = 16
+
+// maxRandomBodySize is the limit of Size, that keeps capped sizes
+// within int64.
+const maxRandomBodySize = 1 << 40
+
+func (d sizeDistribution) String() string {
+	switch d {
+	case uniformSize:
+		return "uniform"
+	case logNormalSize:
+		return "lognormal"
+	}
+	return "fixed"
+}
+
+// RandomBodyOptions describe bodies generated for each request.
+type RandomBodyOptions struct {
+	// Size is the size of bodies, if their distribution is fixed,
+	// the median of log-normal distribution, that is capped at
+	// maxSizeFactor times the median, or the upper bound of uniform
+	// one, with MinSize being its lower bound. It must be positive and
+	// can't exceed 1TiB.
+	Size, MinSize uint64
+	Distribution  sizeDistribution
+	// Sigma is the standard deviation of logarithm of sizes
+	// distributed log-normally.
+	Sigma float64
+	// Compressible bodies consist of repeated text, random bytes are
+	// used otherwise.
+	Compressible bool
+}
+
+func (o RandomBodyOptions) isSet() bool {
+	return o != RandomBodyOptions{}
+}
+
+var byteSizeUnits = map[string]uint64{
+	"":    1,
+	"b":   1,
+	"kb":  1000,
+	"mb":  1000 * 1000,
+	"gb":  1000 * 1000 * 1000,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+}
+
+// parseByteSize parses sizes like 512, 64KiB or 1.5MB.
+func parseByteSize(s string) (uint64, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i < 0 {
+		i = len(s)
+	}
+	unit, ok := byteSizeUnits[strings.ToLower(s[i:])]
+	if !ok || i == 0 {
+		return 0, errInvalidByteSize
+	}
+	n, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil {
+		return 0, errInvalidByteSize
+	}
+	return uint64(n * float64(unit)), nil
+}
+
+// parseByteSizeRange parses either size or range of sizes (i.e.
+// 1KiB-1MiB), min equals max in the former case.
+func parseByteSizeRange(s string) (min, max uint64, isRange bool, err error) {
+	bounds := strings.SplitN(s, "-", 2)
+	max, err = parseByteSize(bounds[len(bounds)-1])
+	if err != nil || len(bounds) == 1 {
+		return max, max, false, err
+	}
+	min, err = parseByteSize(bounds[0])
+	return min, max, true, err
+}
+
+// parseRandomBodySpec parses the value of --body-random flag, which is
+// either size or range of sizes (i.e. 1KiB-1MiB), and the value of
+// --body-size-dist flag, which is the name of distribution optionally
+// followed by sigma (i.e. lognormal:0.5).
+func parseRandomBodySpec(size, dist string) (RandomBodyOptions, error) {
+	var opts RandomBodyOptions
+	min, max, isRange, err := parseByteSizeRange(size)
+	if err != nil {
+		return opts, err
+	}
+	if max == 0 {
+		return opts, errZeroRandomBodySize
+	}
+	opts.Size = max
+	if isRange {
+		opts.MinSize = min
+		opts.Distribution = uniformSize
+	}
+	if dist == "" {
+		return opts, nil
+	}
+	name, sigma := dist, ""
+	if i := strings.IndexByte(dist, ':'); i >= 0 {
+		name, sigma = dist[:i], dist[i+1:]
+	}
+	switch name {
+	case "fixed":
+		opts.Distribution = fixedSize
+	case "uniform":
+		opts.Distribution = uniformSize
+	case "lognormal":
+		opts.Distribution = logNormalSize
+		opts.Sigma = defaultSizeSigma
+	default:
+		return opts, errInvalidSizeDistribution
+	}
+	if sigma != "" {
+		if opts.Distribution != logNormalSize {
+			return opts, errInvalidSizeDistribution
+		}
+		opts.Sigma, err = strconv.ParseFloat(sigma, 64)
+		if err != nil {
+			return opts, errInvalidSizeDistribution
+		}
+	}
+	return opts, nil
+}
+
+const randomBlockSize = 1 << 20
+
+// compressibleText is repeated to generate compressible bodies.
+var compressibleText = []byte(strings.Repeat(
+	"Lorem ipsum dolor sit amet, consectetur adipiscing elit, sed do "+
+		"eiusmod tempor incididunt ut labore et dolore magna aliqua. ", 64,
+))
+
+// randomBody generates bodies of random size. Incompressible bodies
+// are read from a block of random bytes, starting at random offset,
+// that is large enough to be out of reach of compression windows.
+type randomBody struct {
+	opts  RandomBodyOptions
+	block []byte
+	// sizes holds sizes of generated bodies
+	sizes *uhist.Histogram
+}
+
+func newRandomBody(opts RandomBodyOptions) (*randomBody, error) {
+	rb := &randomBody{
+		opts:  opts,
+		block: compressibleText,
+		sizes: uhist.Default(),
+	}
+	if !opts.Compressible {
+		rb.block = make([]byte, randomBlockSize)
+		if _, err := crand.Read(rb.block); err != nil {
+			return nil, err
+		}
+	}
+	return rb, nil
+}
+
+func (rb *randomBody) size() uint64 {
+	o := rb.opts
+	switch o.Distribution {
+	case uniformSize:
+		return o.MinSize + uint64(rand.Int63n(int64(o.Size-o.MinSize+1)))
+	case logNormalSize:
+		s := math.Exp(math.Log(float64(o.Size)) + o.Sigma*rand.NormFloat64())
+		return uint64(math.Min(math.Round(s), float64(o.Size*maxSizeFactor)))
+	}
+	return o.Size
+}
+
+// produce is bodyStreamProducer. Bodies it returns are sizedBody, so
+// that they're sent along with their size.
+func (rb *randomBody) produce() (io.ReadCloser, error) {
+	n := rb.size()
+	rb.sizes.Increment(n)
+	r := &cyclicReader{
+		block: rb.block,
+		off:   rand.Intn(len(rb.block)),
+	}
+	return &sizedBody{
+		Reader: io.LimitReader(r, int64(n)),
+		size:   int64(n),
+	}, nil
+}
+
+// sizedBody is the body, that is known to be size bytes long.
+type sizedBody struct {
+	io.Reader
+	size int64
+}
+
+func (sb *sizedBody) Close() error {
+	return nil
+}
+
+// bodySize returns the size of the body produced by
+// bodyStreamProducer, or -1 if it's unknown.
+func bodySize(body io.ReadCloser) int64 {
+	if sb, ok := body.(*sizedBody); ok {
+		return sb.size
+	}
+	return -1
+}
+
+// cyclicReader reads the block over and over again.
+type cyclicReader struct {
+	block []byte
+	off   int
+}
+
+func (cr *cyclicReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		k := copy(p[n:], cr.block[cr.off:])
+		n += k
+		cr.off = (cr.off + k) % len(cr.block)
+	}
+	return n, nil
+}
@@ -0,0 +1,254 @@
+package lib
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestParseByteSize(t *testing.T) {
+	expectations := []struct {
+		in  string
+		out uint64
+		err error
+	}{
+		{"512", 512, nil},
+		{"512B", 512, nil},
+		{"64KiB", 64 << 10, nil},
+		{"1MiB", 1 << 20, nil},
+		{"1mib", 1 << 20, nil},
+		{"1.5MB", 1500 * 1000, nil},
+		{"2GB", 2 * 1000 * 1000 * 1000, nil},
+		{"1GiB", 1 << 30, nil},
+		{"", 0, errInvalidByteSize},
+		{"MiB", 0, errInvalidByteSize},
+		{"1TiB", 0, errInvalidByteSize},
+		{"1.2.3KB", 0, errInvalidByteSize},
+	}
+	for _, exp := range expectations {
+		out, err := parseByteSize(exp.in)
+		if out != exp.out || err != exp.err {
+			t.Errorf("%q: expected (%v, %v), but got (%v, %v)",
+				exp.in, exp.out, exp.err, out, err)
+		}
+	}
+}
+
+func TestParseRandomBodySpec(t *testing.T) {
+	expectations := []struct {
+		size, dist string
+		out        RandomBodyOptions
+		err        error
+	}{
+		{"1MiB", "", RandomBodyOptions{Size: 1 << 20}, nil},
+		{"1MiB", "fixed", RandomBodyOptions{Size: 1 << 20}, nil},
+		{
+			"1KiB-1MiB", "",
+			RandomBodyOptions{
+				Size: 1 << 20, MinSize: 1 << 10, Distribution: uniformSize,
+			},
+			nil,
+		},
+		{
+			"1MiB", "uniform",
+			RandomBodyOptions{Size: 1 << 20, Distribution: uniformSize},
+			nil,
+		},
+		{
+			"1MiB", "lognormal",
+			RandomBodyOptions{
+				Size: 1 << 20, Distribution: logNormalSize, Sigma: 1,
+			},
+			nil,
+		},
+		{
+			"1MiB", "lognormal:0.25",
+			RandomBodyOptions{
+				Size: 1 << 20, Distribution: logNormalSize, Sigma: 0.25,
+			},
+			nil,
+		},
+		{"1MiB", "normal", RandomBodyOptions{}, errInvalidSizeDistribution},
+		{"1MiB", "uniform:2", RandomBodyOptions{}, errInvalidSizeDistribution},
+		{"1MiB", "lognormal:x", RandomBodyOptions{}, errInvalidSizeDistribution},
+		{"lots", "", RandomBodyOptions{}, errInvalidByteSize},
+		{"lots-1MiB", "", RandomBodyOptions{}, errInvalidByteSize},
+		{"0", "", RandomBodyOptions{}, errZeroRandomBodySize},
+		{"0KiB-0MiB", "", RandomBodyOptions{}, errZeroRandomBodySize},
+	}
+	for _, exp := range expectations {
+		out, err := parseRandomBodySpec(exp.size, exp.dist)
+		if err != exp.err || (err == nil && out != exp.out) {
+			t.Errorf("%q, %q: expected (%+v, %v), but got (%+v, %v)",
+				exp.size, exp.dist, exp.out, exp.err, out, err)
+		}
+	}
+}
+
+func TestRandomBodySizes(t *testing.T) {
+	const samples = 10000
+	uniform, err := newRandomBody(RandomBodyOptions{
+		Size: 200, MinSize: 100, Distribution: uniformSize,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < samples; i++ {
+		if s := uniform.size(); s < 100 || s > 200 {
+			t.Fatalf("Expected size within [100, 200], but got %v", s)
+		}
+	}
+
+	logNormal, err := newRandomBody(RandomBodyOptions{
+		Size: 1000, Distribution: logNormalSize, Sigma: 0.5,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	below := 0
+	for i := 0; i < samples; i++ {
+		if logNormal.size() < 1000 {
+			below++
+		}
+	}
+	// size is the median of log-normal distribution
+	if below < samples*45/100 || below > samples*55/100 {
+		t.Errorf("Expected about half of sizes below median, but got %v/%v",
+			below, samples)
+	}
+}
+
+func TestRandomBodyCapsLogNormalSizes(t *testing.T) {
+	const samples = 10000
+	rb, err := newRandomBody(RandomBodyOptions{
+		Size: 1000, Distribution: logNormalSize, Sigma: 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	capped := 0
+	for i := 0; i < samples; i++ {
+		s := rb.size()
+		if s > 1000*maxSizeFactor {
+			t.Fatalf("Expected size of at most %v, but got %v",
+				1000*maxSizeFactor, s)
+		}
+		if s == 1000*maxSizeFactor {
+			capped++
+		}
+	}
+	// about 39% of sizes exceed the cap with sigma of 10
+	if capped < samples/4 {
+		t.Errorf("Expected sizes to be capped, but only %v/%v were",
+			capped, samples)
+	}
+}
+
+func TestRandomBodyCompressibility(t *testing.T) {
+	const size = 256 << 10
+	ratio := func(compressible bool) float64 {
+		rb, err := newRandomBody(RandomBodyOptions{
+			Size: size, Compressible: compressible,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := rb.produce()
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(body) != size {
+			t.Fatalf("Expected %v bytes, but got %v", size, len(body))
+		}
+		compressed, err := compressBody(body, encodingGzip)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return float64(len(compressed)) / float64(len(body))
+	}
+	if r := ratio(false); r < 0.99 {
+		t.Errorf("Expected random bytes to be incompressible, but got %v", r)
+	}
+	if r := ratio(true); r > 0.1 {
+		t.Errorf("Expected text to be compressible, but got %v", r)
+	}
+}
+
+func TestCyclicReaderWrapsAround(t *testing.T) {
+	cr := &cyclicReader{block: []byte("abc"), off: 2}
+	p := make([]byte, 7)
+	if n, err := cr.Read(p); n != len(p) || err != nil {
+		t.Fatalf("Expected (%v, nil), but got (%v, %v)", len(p), n, err)
+	}
+	if !bytes.Equal(p, []byte("cabcabc")) {
+		t.Errorf("Expected %q, but got %q", "cabcabc", p)
+	}
+}
+
+func TestBombardierSendsRandomBodies(t *testing.T) {
+	clients := []clientTyp{fhttp, nhttp1, nhttp2, pipelined}
+	for _, clientType := range clients {
+		t.Run(clientType.String(), func(t *testing.T) {
+			testBombardierSendsRandomBodies(t, clientType)
+		})
+	}
+}
+
+func testBombardierSendsRandomBodies(t *testing.T, clientType clientTyp) {
+	const minSize, maxSize = 1 << 10, 64 << 10
+	var received uint64
+	s := httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			body, err := ioutil.ReadAll(r.Body)
+			// Bodies are expected to be sent with their length
+			if err != nil || len(body) < minSize || len(body) > maxSize ||
+				r.ContentLength != int64(len(body)) {
+				rw.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			atomic.AddUint64(&received, uint64(len(body)))
+		}),
+	)
+	defer s.Close()
+	numReqs := uint64(20)
+	b, e := NewBombardier(Config{
+		NumConns: 2,
+		NumReqs:  &numReqs,
+		Url:      s.URL,
+		Headers:  new(HeadersList),
+		Timeout:  defaultTimeout,
+		Method:   "POST",
+		RandomBody: RandomBodyOptions{
+			Size: maxSize, MinSize: minSize, Distribution: uniformSize,
+		},
+		ClientType: clientType,
+		Format:     knownFormat("plain-text"),
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	b.disableOutput()
+	b.Bombard()
+
+	if b.req2xx != numReqs {
+		t.Errorf("Expected %v 2xx, but got %v 2xx, %v 4xx, errors: %v",
+			numReqs, b.req2xx, b.req4xx, b.errors.byFrequency())
+	}
+	count, sum := uint64(0), uint64(0)
+	b.bodySizes.VisitAll(func(size, n uint64) bool {
+		count += n
+		sum += size * n
+		return true
+	})
+	if count != numReqs || sum != atomic.LoadUint64(&received) {
+		t.Errorf("Expected %v bodies of %v bytes, but got %v of %v bytes",
+			numReqs, received, count, sum)
+	}
+}
@@ -0,0 +1,116 @@
+package lib
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	uhist "github.com/codesenberg/concurrent/uint64/histogram"
+	"github.com/tony24681379/bombardier/internal"
+)
+
+// redirectCodes are status codes of responses, that are followed.
+var redirectCodes = [...]int{
+	http.StatusMovedPermanently,
+	http.StatusFound,
+	http.StatusSeeOther,
+	http.StatusTemporaryRedirect,
+	http.StatusPermanentRedirect,
+}
+
+func redirectIndex(code int) int {
+	for i, rc := range redirectCodes {
+		if rc == code {
+			return i
+		}
+	}
+	return -1
+}
+
+// redirectMethod returns method of the request, that follows response
+// with the given code, the same way net/http does.
+func redirectMethod(code int, method string) string {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound:
+		if method == http.MethodPost {
+			return http.MethodGet
+		}
+	case http.StatusSeeOther:
+		if method != http.MethodHead {
+			return http.MethodGet
+		}
+	}
+	return method
+}
+
+// redirectStats holds numbers of followed redirects and latencies of
+// each hop of redirected requests, in microseconds.
+type redirectStats struct {
+	// chains is the number of requests redirected at least once
+	chains uint64
+	// followed holds numbers of responses followed by code, in the
+	// order of redirectCodes
+	followed [len(redirectCodes)]uint64
+	hops     *uhist.Histogram
+}
+
+func newRedirectStats() *redirectStats {
+	return &redirectStats{
+		hops: uhist.Default(),
+	}
+}
+
+func (s *redirectStats) snapshot() *internal.RedirectStats {
+	res := &internal.RedirectStats{
+		Chains: atomic.LoadUint64(&s.chains),
+		Codes:  make(map[int]uint64),
+	}
+	for i, code := range redirectCodes {
+		if n := atomic.LoadUint64(&s.followed[i]); n > 0 {
+			res.Codes[code] = n
+		}
+	}
+	return res
+}
+
+// redirectChain records hops of a single request.
+type redirectChain struct {
+	stats    *redirectStats
+	hopStart time.Time
+	hops     int
+}
+
+func (s *redirectStats) newChain(start time.Time) *redirectChain {
+	return &redirectChain{stats: s, hopStart: start}
+}
+
+// follow records hop that ended with redirect response with the given
+// code.
+func (rc *redirectChain) follow(code int) {
+	now := time.Now()
+	if i := redirectIndex(code); i >= 0 {
+		atomic.AddUint64(&rc.stats.followed[i], 1)
+	}
+	rc.stats.hops.Increment(usSince(rc.hopStart, now))
+	rc.hopStart = now
+	rc.hops++
+}
+
+// done records the final hop, if the request was redirected.
+func (rc *redirectChain) done() {
+	if rc.hops == 0 {
+		return
+	}
+	atomic.AddUint64(&rc.stats.chains, 1)
+	rc.stats.hops.Increment(usSince(rc.hopStart, time.Now()))
+}
+
+// redirectChainKey is the context key of *redirectChain, that passes
+// it to http.Client's CheckRedirect.
+type redirectChainKey struct{}
+
+func redirectChainFrom(ctx context.Context) *redirectChain {
+	rc, _ := ctx.Value(redirectChainKey{}).(*redirectChain)
+	return rc
+}
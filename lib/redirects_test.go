@@ -0,0 +1,172 @@
+package lib
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func newRedirectTestBombardier(
+	t *testing.T, url, method, body string, clientType clientTyp,
+	numReqs, maxRedirects uint64,
+) *Bombardier {
+	b, e := NewBombardier(Config{
+		NumConns:     1,
+		NumReqs:      &numReqs,
+		Url:          url,
+		Headers:      new(HeadersList),
+		Timeout:      defaultTimeout,
+		Method:       method,
+		Body:         body,
+		ClientType:   clientType,
+		MaxRedirects: maxRedirects,
+		Format:       knownFormat("plain-text"),
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	b.disableOutput()
+	return b
+}
+
+func TestBombardierFollowRedirects(t *testing.T) {
+	testAllClients(t, func(clientType clientTyp, t *testing.T) {
+		var s *httptest.Server
+		other := httptest.NewServer(
+			http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				http.Redirect(rw, r, s.URL+"/end", http.StatusFound)
+			}),
+		)
+		defer other.Close()
+		s = httptest.NewServer(
+			http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/start":
+					http.Redirect(rw, r, other.URL+"/middle",
+						http.StatusMovedPermanently)
+				case "/end":
+					rw.WriteHeader(http.StatusNoContent)
+				default:
+					rw.WriteHeader(http.StatusNotFound)
+				}
+			}),
+		)
+		defer s.Close()
+		b := newRedirectTestBombardier(
+			t, s.URL+"/start", "GET", "", clientType, 10, 5,
+		)
+		b.Bombard()
+
+		if b.req2xx != 10 {
+			t.Errorf("Expected 10 2xx, but got %v 2xx, %v 3xx, %v 4xx, "+
+				"errors: %v", b.req2xx, b.req3xx, b.req4xx,
+				b.errors.byFrequency())
+		}
+		expectRedirects(t, b, 10, map[int]uint64{301: 10, 302: 10}, 30)
+	})
+}
+
+func TestBombardierRedirectLimit(t *testing.T) {
+	testAllClients(t, func(clientType clientTyp, t *testing.T) {
+		s := httptest.NewServer(
+			http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				http.Redirect(rw, r, "/loop", http.StatusFound)
+			}),
+		)
+		defer s.Close()
+		b := newRedirectTestBombardier(
+			t, s.URL, "GET", "", clientType, 5, 3,
+		)
+		b.Bombard()
+
+		if b.req3xx != 5 {
+			t.Errorf("Expected 5 3xx, but got %v, errors: %v",
+				b.req3xx, b.errors.byFrequency())
+		}
+		expectRedirects(t, b, 5, map[int]uint64{302: 15}, 20)
+	})
+}
+
+func TestBombardierRedirectMethods(t *testing.T) {
+	testAllClients(t, func(clientType clientTyp, t *testing.T) {
+		s := httptest.NewServer(
+			http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				body, _ := ioutil.ReadAll(r.Body)
+				switch r.URL.Path {
+				case "/":
+					http.Redirect(rw, r, "/temporary",
+						http.StatusTemporaryRedirect)
+				case "/temporary":
+					if r.Method != "POST" || string(body) != "payload" {
+						rw.WriteHeader(http.StatusBadRequest)
+						return
+					}
+					http.Redirect(rw, r, "/see-other", http.StatusSeeOther)
+				case "/see-other":
+					if r.Method != "GET" || len(body) != 0 {
+						rw.WriteHeader(http.StatusBadRequest)
+					}
+				}
+			}),
+		)
+		defer s.Close()
+		b := newRedirectTestBombardier(
+			t, s.URL, "POST", "payload", clientType, 5, 2,
+		)
+		b.Bombard()
+
+		if b.req2xx != 5 {
+			t.Errorf("Expected 5 2xx, but got %v 2xx, %v 4xx, errors: %v",
+				b.req2xx, b.req4xx, b.errors.byFrequency())
+		}
+		expectRedirects(t, b, 5, map[int]uint64{303: 5, 307: 5}, 15)
+	})
+}
+
+func TestBombardierRedirectsNotFollowedByDefault(t *testing.T) {
+	testAllClients(t, func(clientType clientTyp, t *testing.T) {
+		s := httptest.NewServer(
+			http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				http.Redirect(rw, r, "/loop", http.StatusFound)
+			}),
+		)
+		defer s.Close()
+		b := newRedirectTestBombardier(
+			t, s.URL, "GET", "", clientType, 5, 0,
+		)
+		b.Bombard()
+
+		if b.req3xx != 5 {
+			t.Errorf("Expected 5 3xx, but got %v, errors: %v",
+				b.req3xx, b.errors.byFrequency())
+		}
+		if b.redirects != nil {
+			t.Error("Expected redirect stats not to be gathered")
+		}
+	})
+}
+
+func expectRedirects(
+	t *testing.T, b *Bombardier,
+	chains uint64, codes map[int]uint64, hops uint64,
+) {
+	t.Helper()
+	if b.redirects == nil {
+		t.Fatal("Expected redirect stats to be gathered")
+	}
+	stats := b.redirects.snapshot()
+	if stats.Chains != chains || !reflect.DeepEqual(stats.Codes, codes) {
+		t.Errorf("Expected %v chains and %v codes, but got %+v",
+			chains, codes, *stats)
+	}
+	n := uint64(0)
+	b.redirects.hops.VisitAll(func(_ uint64, count uint64) bool {
+		n += count
+		return true
+	})
+	if n != hops {
+		t.Errorf("Expected %v hops, but got %v", hops, n)
+	}
+}
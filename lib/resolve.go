@@ -0,0 +1,247 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/tony24681379/bombardier/internal"
+)
+
+const (
+	resolveRoundRobin = "round-robin"
+	resolveRandom     = "random"
+)
+
+// ResolveEntry overrides addresses of Host:Port, like curl's --resolve.
+type ResolveEntry struct {
+	Host, Port string
+	Addrs      []string
+}
+
+// ResolveList is the list of overrides of addresses.
+type ResolveList []ResolveEntry
+
+// resolveValue is the value of --resolve flag.
+type resolveValue struct {
+	list *ResolveList
+}
+
+func (v resolveValue) String() string {
+	return fmt.Sprint(*v.list)
+}
+
+func (v resolveValue) IsCumulative() bool {
+	return true
+}
+
+func (v resolveValue) Set(value string) error {
+	entry, err := parseResolveEntry(value)
+	if err != nil {
+		return err
+	}
+	*v.list = append(*v.list, entry)
+	return nil
+}
+
+// parseResolveEntry parses entries like example.com:443:10.0.0.1,10.0.0.2,
+// IPv6 addresses must be enclosed in brackets.
+func parseResolveEntry(s string) (ResolveEntry, error) {
+	res := strings.SplitN(s, ":", 3)
+	if len(res) != 3 || res[0] == "" || res[2] == "" {
+		return ResolveEntry{}, errInvalidResolveEntry
+	}
+	if p, err := strconv.ParseUint(res[1], 10, 16); err != nil || p == 0 {
+		return ResolveEntry{}, errInvalidResolveEntry
+	}
+	entry := ResolveEntry{Host: strings.ToLower(res[0]), Port: res[1]}
+	for _, addr := range strings.Split(res[2], ",") {
+		addr = strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			return ResolveEntry{}, errInvalidResolveEntry
+		}
+		entry.Addrs = append(entry.Addrs, ip.String())
+	}
+	return entry, nil
+}
+
+// dnsServerAddr appends the default port to address of DNS server, if
+// it has none.
+func dnsServerAddr(server string) string {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		return net.JoinHostPort(strings.Trim(server, "[]"), "53")
+	}
+	return server
+}
+
+// resolver picks addresses to dial, either from overrides, or looked
+// up using the DNS server, spreading connections across them.
+// Addresses of hosts it doesn't know about are left to the dialer.
+type resolver struct {
+	overrides map[string][]string
+	// dns is nil unless the DNS server were set
+	dns    *net.Resolver
+	random bool
+
+	mu   sync.Mutex
+	next map[string]int
+
+	backends *backendStats
+}
+
+func newResolver(
+	list *ResolveList, dnsServer, policy string, backends *backendStats,
+) *resolver {
+	r := &resolver{
+		overrides: make(map[string][]string),
+		random:    policy == resolveRandom,
+		next:      make(map[string]int),
+		backends:  backends,
+	}
+	if list != nil {
+		for _, e := range *list {
+			key := net.JoinHostPort(e.Host, e.Port)
+			r.overrides[key] = append(r.overrides[key], e.Addrs...)
+		}
+	}
+	if dnsServer != "" {
+		server := dnsServerAddr(dnsServer)
+		r.dns = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, server)
+			},
+		}
+	}
+	return r
+}
+
+// resolve returns IP address to connect to instead of host, or empty
+// string if the dialer should resolve it by itself.
+func (r *resolver) resolve(
+	ctx context.Context, host, port string,
+) (string, error) {
+	key := net.JoinHostPort(strings.ToLower(host), port)
+	addrs, ok := r.overrides[key]
+	if !ok {
+		if r.dns == nil || net.ParseIP(host) != nil {
+			return "", nil
+		}
+		var err error
+		addrs, err = r.dns.LookupHost(ctx, host)
+		if err != nil {
+			return "", err
+		}
+	}
+	return r.pick(key, addrs), nil
+}
+
+func (r *resolver) pick(key string, addrs []string) string {
+	if r.random {
+		return addrs[rand.Intn(len(addrs))]
+	}
+	r.mu.Lock()
+	i := r.next[key] % len(addrs)
+	r.next[key] = i + 1
+	r.mu.Unlock()
+	return addrs[i]
+}
+
+// dialContext returns dial function, that connects to resolved
+// addresses, leaving the original host name to TLS and HTTP.
+func (r *resolver) dialContext(
+	dial func(context.Context, string, string) (net.Conn, error),
+) func(context.Context, string, string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		ip, err := r.resolve(ctx, host, port)
+		if err != nil {
+			return nil, err
+		}
+		if ip == "" {
+			return dial(ctx, network, address)
+		}
+		conn, err := dial(ctx, network, net.JoinHostPort(ip, port))
+		b := r.backends.get(ip)
+		if err != nil {
+			atomic.AddUint64(&b.dialFailures, 1)
+			return nil, err
+		}
+		atomic.AddUint64(&b.conns, 1)
+		return &backendConn{Conn: conn, b: b}, nil
+	}
+}
+
+type backendCounters struct {
+	conns, dialFailures, bytesRead, bytesWritten uint64
+}
+
+// backendStats holds counters of each address connections were
+// established to.
+type backendStats struct {
+	mu sync.RWMutex
+	m  map[string]*backendCounters
+}
+
+func newBackendStats() *backendStats {
+	return &backendStats{m: make(map[string]*backendCounters)}
+}
+
+func (s *backendStats) get(ip string) *backendCounters {
+	s.mu.RLock()
+	b, ok := s.m[ip]
+	s.mu.RUnlock()
+	if ok {
+		return b
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if b, ok = s.m[ip]; !ok {
+		b = new(backendCounters)
+		s.m[ip] = b
+	}
+	return b
+}
+
+func (s *backendStats) snapshot() map[string]internal.BackendStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := make(map[string]internal.BackendStats, len(s.m))
+	for ip, b := range s.m {
+		res[ip] = internal.BackendStats{
+			Connections:  atomic.LoadUint64(&b.conns),
+			DialFailures: atomic.LoadUint64(&b.dialFailures),
+			BytesRead:    atomic.LoadUint64(&b.bytesRead),
+			BytesWritten: atomic.LoadUint64(&b.bytesWritten),
+		}
+	}
+	return res
+}
+
+// backendConn counts bytes sent over the connection to the backend.
+type backendConn struct {
+	net.Conn
+	b *backendCounters
+}
+
+func (bc *backendConn) Read(p []byte) (int, error) {
+	n, err := bc.Conn.Read(p)
+	atomic.AddUint64(&bc.b.bytesRead, uint64(n))
+	return n, err
+}
+
+func (bc *backendConn) Write(p []byte) (int, error) {
+	n, err := bc.Conn.Write(p)
+	atomic.AddUint64(&bc.b.bytesWritten, uint64(n))
+	return n, err
+}
@@ -0,0 +1,295 @@
+package lib
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestParseResolveEntry(t *testing.T) {
+	expectations := []struct {
+		in  string
+		out ResolveEntry
+		err error
+	}{
+		{
+			"example.com:443:10.0.0.1",
+			ResolveEntry{"example.com", "443", []string{"10.0.0.1"}},
+			nil,
+		},
+		{
+			"Example.COM:80:10.0.0.1,10.0.0.2",
+			ResolveEntry{"example.com", "80", []string{"10.0.0.1", "10.0.0.2"}},
+			nil,
+		},
+		{
+			"example.com:443:[::1],10.0.0.1",
+			ResolveEntry{"example.com", "443", []string{"::1", "10.0.0.1"}},
+			nil,
+		},
+		{"example.com:443", ResolveEntry{}, errInvalidResolveEntry},
+		{"example.com:443:", ResolveEntry{}, errInvalidResolveEntry},
+		{":443:10.0.0.1", ResolveEntry{}, errInvalidResolveEntry},
+		{"example.com:https:10.0.0.1", ResolveEntry{}, errInvalidResolveEntry},
+		{"example.com:0:10.0.0.1", ResolveEntry{}, errInvalidResolveEntry},
+		{"example.com:443:pod-1", ResolveEntry{}, errInvalidResolveEntry},
+	}
+	for _, exp := range expectations {
+		out, err := parseResolveEntry(exp.in)
+		if err != exp.err || !reflect.DeepEqual(out, exp.out) {
+			t.Errorf("%q: expected (%v, %v), but got (%v, %v)",
+				exp.in, exp.out, exp.err, out, err)
+		}
+	}
+}
+
+func TestResolverSpreadsAddresses(t *testing.T) {
+	list := &ResolveList{
+		{"example.com", "80", []string{"10.0.0.1", "10.0.0.2"}},
+		{"example.com", "80", []string{"10.0.0.3"}},
+	}
+	ctx := context.Background()
+	r := newResolver(list, "", resolveRoundRobin, newBackendStats())
+	var picked []string
+	for i := 0; i < 6; i++ {
+		ip, err := r.resolve(ctx, "EXAMPLE.com", "80")
+		if err != nil {
+			t.Fatal(err)
+		}
+		picked = append(picked, ip)
+	}
+	exp := []string{
+		"10.0.0.1", "10.0.0.2", "10.0.0.3",
+		"10.0.0.1", "10.0.0.2", "10.0.0.3",
+	}
+	if !reflect.DeepEqual(picked, exp) {
+		t.Errorf("Expected %v, but got %v", exp, picked)
+	}
+	if ip, err := r.resolve(ctx, "example.com", "443"); ip != "" || err != nil {
+		t.Errorf("Expected other ports to be left to the dialer, but got"+
+			" (%q, %v)", ip, err)
+	}
+
+	r = newResolver(list, "", resolveRandom, newBackendStats())
+	for i := 0; i < 100; i++ {
+		ip, _ := r.resolve(ctx, "example.com", "80")
+		if ip != "10.0.0.1" && ip != "10.0.0.2" && ip != "10.0.0.3" {
+			t.Fatalf("Unexpected address %q", ip)
+		}
+	}
+}
+
+// newLoopbackServer returns server listening on all loopback addresses,
+// that counts requests by the address they were sent to.
+func newLoopbackServer(
+	t *testing.T, handler http.HandlerFunc,
+) (*httptest.Server, map[string]int, *sync.Mutex) {
+	l, err := net.Listen("tcp", "0.0.0.0:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	counts := make(map[string]int)
+	s := httptest.NewUnstartedServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			addr := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+			mu.Lock()
+			counts[addr.(*net.TCPAddr).IP.String()]++
+			mu.Unlock()
+			handler(rw, r)
+		}),
+	)
+	_ = s.Listener.Close()
+	s.Listener = l
+	return s, counts, &mu
+}
+
+func TestBombardierResolvesAddresses(t *testing.T) {
+	testAllClients(t, testBombardierResolvesAddresses)
+}
+
+func testBombardierResolvesAddresses(clientType clientTyp, t *testing.T) {
+	var host string
+	s, counts, mu := newLoopbackServer(t,
+		func(rw http.ResponseWriter, r *http.Request) {
+			if r.Host != host {
+				rw.WriteHeader(http.StatusBadRequest)
+			}
+		},
+	)
+	s.Start()
+	defer s.Close()
+	port := strconv.Itoa(s.Listener.Addr().(*net.TCPAddr).Port)
+	host = net.JoinHostPort("bombardier.test", port)
+
+	numReqs := uint64(6)
+	b, e := NewBombardier(Config{
+		NumConns: 1,
+		NumReqs:  &numReqs,
+		Url:      "http://" + host,
+		Headers:  new(HeadersList),
+		Timeout:  defaultTimeout,
+		Method:   "GET",
+		Resolve: &ResolveList{
+			{"bombardier.test", port, []string{"127.0.0.1", "127.0.0.2"}},
+		},
+		Connections: ConnOptions{DisableKeepAlive: true},
+		ClientType:  clientType,
+		Format:      knownFormat("plain-text"),
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	b.disableOutput()
+	b.Bombard()
+
+	if b.req2xx != numReqs {
+		t.Errorf("Expected %v 2xx, but got %v 2xx, %v 4xx, errors: %v",
+			numReqs, b.req2xx, b.req4xx, b.errors.byFrequency())
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	exp := map[string]int{"127.0.0.1": 3, "127.0.0.2": 3}
+	if !reflect.DeepEqual(counts, exp) {
+		t.Errorf("Expected requests spread as %v, but got %v", exp, counts)
+	}
+	backends := b.backends.snapshot()
+	for ip := range exp {
+		bs := backends[ip]
+		if bs.Connections != 3 || bs.BytesRead == 0 || bs.BytesWritten == 0 {
+			t.Errorf("Unexpected stats of %v: %+v", ip, bs)
+		}
+	}
+}
+
+func TestBombardierResolveKeepsServerName(t *testing.T) {
+	testAllClients(t, func(clientType clientTyp, t *testing.T) {
+		s := httptest.NewTLSServer(
+			http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				if r.TLS == nil || r.TLS.ServerName != "bombardier.test" {
+					rw.WriteHeader(http.StatusBadRequest)
+				}
+			}),
+		)
+		defer s.Close()
+		port := strconv.Itoa(s.Listener.Addr().(*net.TCPAddr).Port)
+
+		numReqs := uint64(2)
+		b, e := NewBombardier(Config{
+			NumConns: 1,
+			NumReqs:  &numReqs,
+			Url:      "https://bombardier.test:" + port,
+			Headers:  new(HeadersList),
+			Timeout:  defaultTimeout,
+			Method:   "GET",
+			Insecure: true,
+			Resolve: &ResolveList{
+				{"bombardier.test", port, []string{"127.0.0.1"}},
+			},
+			ClientType: clientType,
+			Format:     knownFormat("plain-text"),
+		})
+		if e != nil {
+			t.Fatal(e)
+		}
+		b.disableOutput()
+		b.Bombard()
+
+		if b.req2xx != numReqs {
+			t.Errorf("Expected %v 2xx, but got %v 2xx, %v 4xx, errors: %v",
+				numReqs, b.req2xx, b.req4xx, b.errors.byFrequency())
+		}
+	})
+}
+
+// serveDNS answers A queries with the given addresses, and other
+// queries with no records.
+func serveDNS(pc net.PacketConn, addrs ...net.IP) {
+	buf := make([]byte, 512)
+	for {
+		n, from, err := pc.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		q := buf[:n]
+		// Skip the header and the name of the only question
+		end := 12
+		for end < len(q) && q[end] != 0 {
+			end += int(q[end]) + 1
+		}
+		end += 5
+		if end > len(q) {
+			continue
+		}
+		qtype := binary.BigEndian.Uint16(q[end-4:])
+		resp := append([]byte(nil), q[:end]...)
+		binary.BigEndian.PutUint16(resp[2:], 0x8180)
+		answers := addrs
+		if qtype != 1 {
+			answers = nil
+		}
+		binary.BigEndian.PutUint16(resp[6:], uint16(len(answers)))
+		for _, ip := range answers {
+			resp = append(resp,
+				0xc0, 12, // pointer to the name of the question
+				0, 1, 0, 1, // A, IN
+				0, 0, 0, 60, // TTL
+				0, 4,
+			)
+			resp = append(resp, ip.To4()...)
+		}
+		_, _ = pc.WriteTo(resp, from)
+	}
+}
+
+func TestBombardierUsesDNSServer(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	go serveDNS(pc, net.ParseIP("127.0.0.1"), net.ParseIP("127.0.0.2"))
+
+	s, counts, mu := newLoopbackServer(t,
+		func(rw http.ResponseWriter, r *http.Request) {},
+	)
+	s.Start()
+	defer s.Close()
+	port := strconv.Itoa(s.Listener.Addr().(*net.TCPAddr).Port)
+
+	numReqs := uint64(4)
+	b, e := NewBombardier(Config{
+		NumConns:    1,
+		NumReqs:     &numReqs,
+		Url:         "http://bombardier.test:" + port,
+		Headers:     new(HeadersList),
+		Timeout:     defaultTimeout,
+		Method:      "GET",
+		DNSServer:   pc.LocalAddr().String(),
+		Connections: ConnOptions{DisableKeepAlive: true},
+		ClientType:  fhttp,
+		Format:      knownFormat("plain-text"),
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	b.disableOutput()
+	b.Bombard()
+
+	if b.req2xx != numReqs {
+		t.Errorf("Expected %v 2xx, but got %v 2xx, errors: %v",
+			numReqs, b.req2xx, b.errors.byFrequency())
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	exp := map[string]int{"127.0.0.1": 2, "127.0.0.2": 2}
+	if !reflect.DeepEqual(counts, exp) {
+		t.Errorf("Expected requests spread as %v, but got %v", exp, counts)
+	}
+}
@@ -0,0 +1,127 @@
+package lib
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"hash"
+	"io"
+	"strings"
+
+	uhist "github.com/codesenberg/concurrent/uint64/histogram"
+)
+
+// ResponseChecks describe responses considered valid, other responses
+// are reported as errors.
+type ResponseChecks struct {
+	// CheckSize enables checking that sizes of bodies are within
+	// [MinSize, MaxSize].
+	CheckSize        bool
+	MinSize, MaxSize uint64
+	// Checksum is the digest of bodies prefixed by its algorithm (i.e.
+	// sha256:<hex>), it isn't checked if empty.
+	Checksum string
+}
+
+func (rc ResponseChecks) isSet() bool {
+	return rc.CheckSize || rc.Checksum != ""
+}
+
+var checksumAlgorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// parseChecksum parses checksums like sha256:<hex>.
+func parseChecksum(s string) (func() hash.Hash, []byte, error) {
+	res := strings.SplitN(s, ":", 2)
+	if len(res) != 2 {
+		return nil, nil, errInvalidChecksum
+	}
+	newHash, ok := checksumAlgorithms[strings.ToLower(res[0])]
+	if !ok {
+		return nil, nil, errInvalidChecksum
+	}
+	digest, err := hex.DecodeString(res[1])
+	if err != nil || len(digest) != newHash().Size() {
+		return nil, nil, errInvalidChecksum
+	}
+	return newHash, digest, nil
+}
+
+// responseVerifier records sizes of response bodies and checks them.
+type responseVerifier struct {
+	sizes  *uhist.Histogram
+	checks ResponseChecks
+	// newHash is nil unless checksums are checked
+	newHash func() hash.Hash
+	digest  []byte
+}
+
+func newResponseVerifier(
+	sizes *uhist.Histogram, checks ResponseChecks,
+) *responseVerifier {
+	v := &responseVerifier{sizes: sizes, checks: checks}
+	if checks.Checksum != "" {
+		// Validated by Config.checkArgs
+		v.newHash, v.digest, _ = parseChecksum(checks.Checksum)
+	}
+	return v
+}
+
+// verify checks the body read into memory.
+func (v *responseVerifier) verify(body []byte) error {
+	var h hash.Hash
+	if v.newHash != nil {
+		h = v.newHash()
+		_, _ = h.Write(body)
+	}
+	return v.check(uint64(len(body)), h)
+}
+
+// reader returns reader of r, that checks the body once it's read.
+func (v *responseVerifier) reader(r io.Reader) *verifyingReader {
+	vr := &verifyingReader{v: v, r: r}
+	if v.newHash != nil {
+		vr.h = v.newHash()
+	}
+	return vr
+}
+
+func (v *responseVerifier) check(size uint64, h hash.Hash) error {
+	v.sizes.Increment(size)
+	if v.checks.CheckSize &&
+		(size < v.checks.MinSize || size > v.checks.MaxSize) {
+		return errUnexpectedResponseSize
+	}
+	if h != nil && !bytes.Equal(h.Sum(nil), v.digest) {
+		return errChecksumMismatch
+	}
+	return nil
+}
+
+// verifyingReader counts and hashes the body as it's read.
+type verifyingReader struct {
+	v *responseVerifier
+	r io.Reader
+	n uint64
+	h hash.Hash
+}
+
+func (vr *verifyingReader) Read(p []byte) (int, error) {
+	n, err := vr.r.Read(p)
+	vr.n += uint64(n)
+	if vr.h != nil {
+		_, _ = vr.h.Write(p[:n])
+	}
+	return n, err
+}
+
+func (vr *verifyingReader) verify() error {
+	return vr.v.check(vr.n, vr.h)
+}
@@ -0,0 +1,166 @@
+package lib
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	uhist "github.com/codesenberg/concurrent/uint64/histogram"
+)
+
+func TestParseChecksum(t *testing.T) {
+	sum := sha256.Sum256([]byte("bombardier"))
+	digest := hex.EncodeToString(sum[:])
+	expectations := []struct {
+		in  string
+		err error
+	}{
+		{"sha256:" + digest, nil},
+		{"SHA256:" + digest, nil},
+		{"md5:d41d8cd98f00b204e9800998ecf8427e", nil},
+		{"sha1:da39a3ee5e6b4b0d3255bfef95601890afd80709", nil},
+		{digest, errInvalidChecksum},
+		{"crc32:" + digest, errInvalidChecksum},
+		{"sha256:" + digest[2:], errInvalidChecksum},
+		{"sha256:xyz", errInvalidChecksum},
+		{"md5:" + digest, errInvalidChecksum},
+	}
+	for _, exp := range expectations {
+		if _, _, err := parseChecksum(exp.in); err != exp.err {
+			t.Errorf("%q: expected %v, but got %v", exp.in, exp.err, err)
+		}
+	}
+}
+
+func TestResponseVerifierChecks(t *testing.T) {
+	sum := sha256.Sum256([]byte("abracadabra"))
+	checks := ResponseChecks{
+		CheckSize: true,
+		MinSize:   4,
+		MaxSize:   11,
+		Checksum:  "sha256:" + hex.EncodeToString(sum[:]),
+	}
+	v := newResponseVerifier(uhist.Default(), checks)
+	expectations := []struct {
+		body string
+		err  error
+	}{
+		{"abracadabra", nil},
+		{"abracadabrx", errChecksumMismatch},
+		{"abracadabraabracadabra", errUnexpectedResponseSize},
+		{"abr", errUnexpectedResponseSize},
+	}
+	for _, exp := range expectations {
+		if err := v.verify([]byte(exp.body)); err != exp.err {
+			t.Errorf("%q: expected %v, but got %v", exp.body, exp.err, err)
+		}
+	}
+	if v.sizes.Count() != 3 {
+		t.Errorf("Expected 3 distinct sizes, but got %v", v.sizes.Count())
+	}
+}
+
+func TestBombardierChecksResponses(t *testing.T) {
+	clients := []clientTyp{fhttp, nhttp1, nhttp2, pipelined}
+	for _, clientType := range clients {
+		t.Run(clientType.String(), func(t *testing.T) {
+			testBombardierChecksResponses(t, clientType)
+		})
+	}
+}
+
+func testBombardierChecksResponses(t *testing.T, clientType clientTyp) {
+	bodies := []string{"abracadabra", "abra", "abracadabrx"}
+	var served uint64
+	s := httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			i := atomic.AddUint64(&served, 1) - 1
+			_, _ = rw.Write([]byte(bodies[i%uint64(len(bodies))]))
+		}),
+	)
+	defer s.Close()
+	sum := sha256.Sum256([]byte(bodies[0]))
+	numReqs := uint64(6)
+	b, e := NewBombardier(Config{
+		NumConns: 1,
+		NumReqs:  &numReqs,
+		Url:      s.URL,
+		Headers:  new(HeadersList),
+		Timeout:  defaultTimeout,
+		Method:   "GET",
+		Expect: ResponseChecks{
+			CheckSize: true,
+			MinSize:   11,
+			MaxSize:   11,
+			Checksum:  "sha256:" + hex.EncodeToString(sum[:]),
+		},
+		ClientType: clientType,
+		Format:     knownFormat("plain-text"),
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	b.disableOutput()
+	b.Bombard()
+
+	if b.req2xx != numReqs {
+		t.Errorf("Expected %v 2xx, but got %v", numReqs, b.req2xx)
+	}
+	if n := b.errors.get(errUnexpectedResponseSize); n != 2 {
+		t.Errorf("Expected 2 responses of unexpected size, but got %v", n)
+	}
+	if n := b.errors.get(errChecksumMismatch); n != 2 {
+		t.Errorf("Expected 2 checksum mismatches, but got %v", n)
+	}
+	sizes := map[uint64]uint64{}
+	b.responseSizes.VisitAll(func(size, n uint64) bool {
+		sizes[size] = n
+		return true
+	})
+	if len(sizes) != 2 || sizes[11] != 4 || sizes[4] != 2 {
+		t.Errorf("Unexpected sizes of responses: %v", sizes)
+	}
+}
+
+func TestBombardierMeasuresResponses(t *testing.T) {
+	testAllClients(t, func(clientType clientTyp, t *testing.T) {
+		s := httptest.NewServer(
+			http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				_, _ = rw.Write([]byte("abracadabra"))
+			}),
+		)
+		defer s.Close()
+		numReqs := uint64(2)
+		b, e := NewBombardier(Config{
+			NumConns:   1,
+			NumReqs:    &numReqs,
+			Url:        s.URL,
+			Headers:    new(HeadersList),
+			Timeout:    defaultTimeout,
+			Method:     "GET",
+			ClientType: clientType,
+			Format:     knownFormat("plain-text"),
+		})
+		if e != nil {
+			t.Fatal(e)
+		}
+		b.disableOutput()
+		b.Bombard()
+
+		if b.errors.sum() != 0 {
+			t.Errorf("Expected no errors, but got %v",
+				b.errors.byFrequency())
+		}
+		sizes := b.gatherInfo().Result.ResponseSizes
+		if sizes == nil {
+			t.Fatal("Expected sizes of responses to be reported")
+		}
+		if n := sizes.Get(11); n != numReqs {
+			t.Errorf("Expected %v responses of 11 bytes, but got %v",
+				numReqs, n)
+		}
+	})
+}
@@ -0,0 +1,404 @@
+package lib
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/tony24681379/bombardier/internal"
+)
+
+var defaultPercentiles = []float64{0.5, 0.75, 0.9, 0.99}
+
+type options struct {
+	out         io.Writer
+	percentiles []float64
+	middleware  middlewareChain
+}
+
+func newOptions(opts ...Option) *options {
+	o := &options{
+		percentiles: defaultPercentiles,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// Option configures how the test is run.
+type Option func(*options)
+
+// WithOutput sets the writer, that intro, progress bar and results
+// are written to (subject to Config.PrintIntro, Config.PrintProgress
+// and Config.PrintResult). Defaults to os.Stdout.
+func WithOutput(w io.Writer) Option {
+	return func(o *options) {
+		o.out = w
+	}
+}
+
+// WithPercentiles sets percentiles (0.0 <= p <= 1.0), that are
+// calculated for the Result. Defaults to 0.5, 0.75, 0.9 and 0.99.
+func WithPercentiles(ps ...float64) Option {
+	return func(o *options) {
+		o.percentiles = ps
+	}
+}
+
+// Result holds results of the test performed by Run. It mirrors the
+// information available to output templates (see
+// github.com/tony24681379/bombardier/internal.TestInfo), but with
+// statistics already calculated.
+type Result struct {
+	// Config is the configuration used to perform the test,
+	// with defaults filled in.
+	Config Config
+
+	BytesRead, BytesWritten int64
+	// TimeTaken is the effective duration of the test.
+	TimeTaken time.Duration
+	// Cancelled tells whether the test was stopped before its
+	// completion.
+	Cancelled bool
+
+	Req1XX, Req2XX, Req3XX, Req4XX, Req5XX uint64
+	Others                                 uint64
+	// GRPCCodes maps names of gRPC codes to number of times they
+	// occurred, it's nil unless GRPCClient were used.
+	GRPCCodes map[string]uint64
+	// WebSocket is nil unless WebSocketClient were used.
+	WebSocket *WebSocketStats
+	// TCPResponses is the number of responses received by
+	// RawTCPClient.
+	TCPResponses uint64
+	// Connections is nil unless Config.Connections were set and
+	// FastHTTPClient, HTTP1Client or HTTP2Client were used.
+	Connections *ConnectionStats
+	// HTTP2 is nil unless Config.HTTP2 were set.
+	HTTP2 *HTTP2Stats
+	// QUIC is nil unless HTTP3Client were used.
+	QUIC *QUICStats
+	// Redirects is nil unless Config.MaxRedirects were set, codes
+	// above only count final responses.
+	Redirects *RedirectStats
+	// Compression is nil unless Config.Decompress were set.
+	Compression *CompressionStats
+	// Backends maps addresses connections were made to, it's nil
+	// unless Config.Resolve or Config.DNSServer were set.
+	Backends map[string]BackendStats
+	// Sources maps local addresses connections were made from, it's
+	// nil unless Config.LocalAddrs were set.
+	Sources map[string]SourceStats
+
+	// Errors are sorted by frequency, most frequent first.
+	Errors []ErrorWithCount
+
+	// Latencies and Requests are nil if there wasn't enough
+	// data to compute them.
+	Latencies *LatenciesStats
+	Requests  *RequestsStats
+
+	// Streams is nil unless Config.StreamEvents were set.
+	Streams *StreamStats
+	// ProxyConnects describes time taken to establish tunnels
+	// through the proxy, it's nil unless Config.Proxy were set.
+	ProxyConnects *LatenciesStats
+	// QUICHandshakes describes time taken to complete QUIC
+	// handshakes, it's nil unless HTTP3Client were used.
+	QUICHandshakes *LatenciesStats
+	// RedirectHops describes latencies of each hop of redirected
+	// requests, it's nil unless Config.MaxRedirects were set.
+	RedirectHops *LatenciesStats
+	// BodySizes describes sizes of generated request bodies, in
+	// bytes, it's nil unless Config.RandomBody were set.
+	BodySizes *LatenciesStats
+	// ResponseSizes describes sizes of response bodies, in bytes, it's
+	// nil unless HTTP clients were used.
+	ResponseSizes *LatenciesStats
+}
+
+// Throughput returns total throughput (read + write) in bytes per
+// second, or 0 if the test was cancelled before it started.
+func (r Result) Throughput() float64 {
+	if r.TimeTaken <= 0 {
+		return 0
+	}
+	return float64(r.BytesRead+r.BytesWritten) / r.TimeTaken.Seconds()
+}
+
+// ErrorWithCount contains error description alongside with number of
+// times this error occurred.
+type ErrorWithCount struct {
+	Error string
+	Count uint64
+}
+
+// WebSocketStats contains WebSocket-specific results.
+type WebSocketStats struct {
+	// Connects and ConnectFailures are numbers of successful and
+	// failed opening handshakes.
+	Connects, ConnectFailures uint64
+	// Messages is the number of messages echoed by the server.
+	Messages uint64
+	// CloseCodes maps codes of closing handshakes initiated by the
+	// server to number of times they occurred.
+	CloseCodes map[int]uint64
+}
+
+// ConnectionStats contains numbers of connections opened, requests
+// sent over previously used connections and connections closed by
+// the server.
+type ConnectionStats struct {
+	Opened, Reused, ClosedByPeer uint64
+}
+
+// HTTP2Stats contains numbers of HTTP/2 connections, streams sent over
+// them, GOAWAY and RST_STREAM frames received from the server.
+type HTTP2Stats struct {
+	Connections, Streams, GoAways, StreamResets uint64
+}
+
+// QUICStats contains numbers of QUIC handshakes completed by
+// HTTP3Client and of those, that resumed sessions using 0-RTT.
+type QUICStats struct {
+	Handshakes, ZeroRTT uint64
+}
+
+// RedirectStats contains number of requests redirected at least once
+// and numbers of intermediate responses followed by status code.
+type RedirectStats struct {
+	Chains uint64
+	Codes  map[int]uint64
+}
+
+// CompressionStats contains number of decoded responses and their
+// sizes before and after decoding.
+type CompressionStats struct {
+	Responses                          uint64
+	CompressedBytes, DecompressedBytes uint64
+}
+
+// BackendStats contains numbers of connections established to an
+// address of the target, failures to connect to it and bytes sent
+// over those connections.
+type BackendStats struct {
+	Connections, DialFailures, BytesRead, BytesWritten uint64
+}
+
+// SourceStats contains number of connections made from a local
+// address and errors, that occurred while connecting from it.
+type SourceStats struct {
+	Connections uint64
+	// Errors are sorted by frequency, most frequent first.
+	Errors []ErrorWithCount
+}
+
+// LatenciesStats contains statistical information about latencies.
+type LatenciesStats struct {
+	// These are in microseconds
+	Mean   float64
+	Stddev float64
+	Max    float64
+
+	// This is map[0.0 <= p <= 1.0 (percentile)]microseconds
+	Percentiles map[float64]uint64
+}
+
+// StreamStats contains statistical information about streams of
+// events. Each of the fields is nil if there wasn't enough data to
+// compute it.
+type StreamStats struct {
+	TimeToFirstByte  *LatenciesStats
+	TimeToFirstEvent *LatenciesStats
+	InterEventGaps   *LatenciesStats
+	Durations        *LatenciesStats
+	// This one is in events, rather than microseconds.
+	EventsPerStream *LatenciesStats
+}
+
+// RequestsStats contains statistical information about requests.
+type RequestsStats struct {
+	// These are in requests per second.
+	Mean   float64
+	Stddev float64
+	Max    float64
+
+	// This is map[0.0 <= p <= 1.0 (percentile)](req-s per second)
+	Percentiles map[float64]float64
+}
+
+// Run performs the test described by c and returns its results.
+// Unlike NewBombardier, it fills in defaults for the number of
+// connections, method, headers and format, if they aren't set.
+// Cancelling ctx aborts the test, in which case results obtained so
+// far are returned.
+func Run(ctx context.Context, c Config, opts ...Option) (Result, error) {
+	c.setLibraryDefaults()
+	b, err := NewBombardier(c, opts...)
+	if err != nil {
+		return Result{}, err
+	}
+
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-ctx.Done():
+			b.Abort()
+		case <-finished:
+		}
+	}()
+
+	b.Bombard()
+	if b.Conf.PrintResult {
+		b.PrintStats()
+	}
+	return b.result(), nil
+}
+
+func (c *Config) setLibraryDefaults() {
+	if c.NumConns == 0 {
+		c.NumConns = defaultNumberOfConns
+	}
+	if c.Method == "" {
+		c.Method = "GET"
+	}
+	if c.Headers == nil {
+		c.Headers = new(HeadersList)
+	}
+	if c.Format == nil {
+		c.Format = knownFormat("plain-text")
+	}
+}
+
+func (b *Bombardier) result() Result {
+	info := b.gatherInfo()
+	res := Result{
+		Config: b.Conf,
+
+		BytesRead:    info.Result.BytesRead,
+		BytesWritten: info.Result.BytesWritten,
+		TimeTaken:    info.Result.TimeTaken,
+		Cancelled:    info.Result.Cancelled,
+
+		Req1XX: info.Result.Req1XX,
+		Req2XX: info.Result.Req2XX,
+		Req3XX: info.Result.Req3XX,
+		Req4XX: info.Result.Req4XX,
+		Req5XX: info.Result.Req5XX,
+		Others: info.Result.Others,
+
+		GRPCCodes:    info.Result.GRPCCodes,
+		TCPResponses: info.Result.TCPResponses,
+	}
+	if ws := info.Result.WebSocket; ws != nil {
+		res.WebSocket = &WebSocketStats{
+			Connects:        ws.Connects,
+			ConnectFailures: ws.ConnectFailures,
+			Messages:        ws.Messages,
+			CloseCodes:      ws.CloseCodes,
+		}
+	}
+	if cs := info.Result.Connections; cs != nil {
+		res.Connections = &ConnectionStats{
+			Opened:       cs.Opened,
+			Reused:       cs.Reused,
+			ClosedByPeer: cs.ClosedByPeer,
+		}
+	}
+	if hs := info.Result.HTTP2; hs != nil {
+		res.HTTP2 = &HTTP2Stats{
+			Connections:  hs.Connections,
+			Streams:      hs.Streams,
+			GoAways:      hs.GoAways,
+			StreamResets: hs.StreamResets,
+		}
+	}
+	if qs := info.Result.QUIC; qs != nil {
+		res.QUIC = &QUICStats{
+			Handshakes: qs.Handshakes,
+			ZeroRTT:    qs.ZeroRTT,
+		}
+	}
+	if rs := info.Result.Redirects; rs != nil {
+		res.Redirects = &RedirectStats{
+			Chains: rs.Chains,
+			Codes:  rs.Codes,
+		}
+	}
+	if cs := info.Result.Compression; cs != nil {
+		res.Compression = &CompressionStats{
+			Responses:         cs.Responses,
+			CompressedBytes:   cs.CompressedBytes,
+			DecompressedBytes: cs.DecompressedBytes,
+		}
+	}
+	if bs := info.Result.Backends; bs != nil {
+		res.Backends = make(map[string]BackendStats, len(bs))
+		for addr, s := range bs {
+			res.Backends[addr] = BackendStats(s)
+		}
+	}
+	if ss := info.Result.Sources; ss != nil {
+		res.Sources = make(map[string]SourceStats, len(ss))
+		for addr, s := range ss {
+			src := SourceStats{Connections: s.Connections}
+			for _, ewc := range s.Errors {
+				src.Errors = append(src.Errors, ErrorWithCount(ewc))
+			}
+			res.Sources[addr] = src
+		}
+	}
+	for _, ewc := range info.Result.Errors {
+		res.Errors = append(res.Errors, ErrorWithCount(ewc))
+	}
+	res.Latencies = latenciesStats(
+		info.Result.LatenciesStats(b.options.percentiles),
+	)
+	if ss := info.Result.StreamStats(b.options.percentiles); ss != nil {
+		res.Streams = &StreamStats{
+			TimeToFirstByte:  latenciesStats(ss.TimeToFirstByte),
+			TimeToFirstEvent: latenciesStats(ss.TimeToFirstEvent),
+			InterEventGaps:   latenciesStats(ss.InterEventGaps),
+			Durations:        latenciesStats(ss.Durations),
+			EventsPerStream:  latenciesStats(ss.EventsPerStream),
+		}
+	}
+	res.ProxyConnects = latenciesStats(
+		info.Result.ProxyConnectStats(b.options.percentiles),
+	)
+	res.QUICHandshakes = latenciesStats(
+		info.Result.QUICHandshakeStats(b.options.percentiles),
+	)
+	res.RedirectHops = latenciesStats(
+		info.Result.RedirectHopStats(b.options.percentiles),
+	)
+	res.BodySizes = latenciesStats(
+		info.Result.BodySizeStats(b.options.percentiles),
+	)
+	res.ResponseSizes = latenciesStats(
+		info.Result.ResponseSizeStats(b.options.percentiles),
+	)
+	if rs := info.Result.RequestsStats(b.options.percentiles); rs != nil {
+		res.Requests = &RequestsStats{
+			Mean:        rs.Mean,
+			Stddev:      rs.Stddev,
+			Max:         rs.Max,
+			Percentiles: rs.Percentiles,
+		}
+	}
+	return res
+}
+
+func latenciesStats(ls *internal.LatenciesStats) *LatenciesStats {
+	if ls == nil {
+		return nil
+	}
+	return &LatenciesStats{
+		Mean:        ls.Mean,
+		Stddev:      ls.Stddev,
+		Max:         ls.Max,
+		Percentiles: ls.Percentiles,
+	}
+}
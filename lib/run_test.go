@@ -0,0 +1,129 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	s := httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-Test") != "value" {
+				rw.WriteHeader(http.StatusBadRequest)
+			}
+		}),
+	)
+	defer s.Close()
+	headers := new(HeadersList)
+	if err := headers.Set("X-Test: value"); err != nil {
+		t.Fatal(err)
+	}
+	numReqs := uint64(100)
+	out := new(bytes.Buffer)
+	res, err := Run(context.Background(), Config{
+		NumReqs:     &numReqs,
+		Url:         s.URL,
+		Headers:     headers,
+		Timeout:     defaultTimeout,
+		ClientType:  HTTP1Client,
+		PrintResult: true,
+	}, WithOutput(out), WithPercentiles(0.5, 0.95))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Req2XX != numReqs {
+		t.Errorf("Expected %v 2xx responses, but got %v", numReqs, res.Req2XX)
+	}
+	if res.Cancelled {
+		t.Error("Test shouldn't be cancelled")
+	}
+	if res.Config.NumConns != defaultNumberOfConns ||
+		res.Config.Method != "GET" {
+		t.Errorf("Defaults weren't filled in: %+v", res.Config)
+	}
+	if res.Latencies == nil {
+		t.Fatal("Latencies should be computed")
+	}
+	if _, ok := res.Latencies.Percentiles[0.95]; !ok {
+		t.Errorf("Missing requested percentile: %v", res.Latencies.Percentiles)
+	}
+	if _, ok := res.Latencies.Percentiles[0.75]; ok {
+		t.Errorf("Unexpected percentile: %v", res.Latencies.Percentiles)
+	}
+	if res.Throughput() <= 0 {
+		t.Errorf("Unexpected throughput: %v", res.Throughput())
+	}
+	if out.Len() == 0 {
+		t.Error("Results weren't written to the provided writer")
+	}
+}
+
+func TestRunWithCancelledContext(t *testing.T) {
+	s := httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			rw.WriteHeader(http.StatusOK)
+		}),
+	)
+	defer s.Close()
+	testDuration := 10 * time.Second
+	ctx, cancel := context.WithTimeout(
+		context.Background(), 200*time.Millisecond,
+	)
+	defer cancel()
+	start := time.Now()
+	res, err := Run(ctx, Config{
+		Duration: &testDuration,
+		Url:      s.URL,
+		Timeout:  defaultTimeout,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if taken := time.Since(start); taken > 2*time.Second {
+		t.Errorf("Test took too long to stop: %v", taken)
+	}
+	if !res.Cancelled {
+		t.Error("Test should be marked as cancelled")
+	}
+	if res.Req2XX == 0 {
+		t.Error("Results obtained before cancellation should be returned")
+	}
+}
+
+func TestRunWithContextCancelledBeforehand(t *testing.T) {
+	s := httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}),
+	)
+	defer s.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	testDuration := 10 * time.Second
+	res, err := Run(ctx, Config{
+		Duration: &testDuration,
+		Url:      s.URL,
+		Timeout:  defaultTimeout,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tp := res.Throughput(); math.IsNaN(tp) || math.IsInf(tp, 0) {
+		t.Errorf("Expected finite throughput, but got %v", tp)
+	}
+	if tp := (Result{BytesRead: 1024}).Throughput(); tp != 0 {
+		t.Errorf("Expected zero throughput without time taken, but got %v",
+			tp)
+	}
+}
+
+func TestRunInvalidConfig(t *testing.T) {
+	if _, err := Run(context.Background(), Config{
+		Url: "ftp://localhost",
+	}); err != errInvalidURL {
+		t.Errorf("Expected %v, but got %v", errInvalidURL, err)
+	}
+}
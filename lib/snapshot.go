@@ -0,0 +1,67 @@
+package lib
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// PrintSnapshot outputs intermediate results of the test using the
+// selected format. It's safe to call it while the test is running.
+func (b *Bombardier) PrintSnapshot() {
+	b.sl.Lock()
+	defer b.sl.Unlock()
+	b.snapshots++
+	if err := b.writeSnapshot(b.snapshots); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+func (b *Bombardier) writeSnapshot(seq uint64) error {
+	if b.Conf.SnapshotPath == "" {
+		// Separate consecutive snapshots from each other
+		return b.executeTemplate(os.Stderr, "\n")
+	}
+	f, err := os.Create(snapshotFileName(b.Conf.SnapshotPath, seq))
+	if err != nil {
+		return err
+	}
+	if err = b.executeTemplate(f, ""); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (b *Bombardier) executeTemplate(out io.Writer, suffix string) error {
+	if err := b.template.Execute(out, b.gatherInfo()); err != nil {
+		return err
+	}
+	_, err := io.WriteString(out, suffix)
+	return err
+}
+
+func (b *Bombardier) snapshotter() {
+	ticker := time.NewTicker(b.Conf.SnapshotInterval)
+	defer ticker.Stop()
+	done := b.Barrier.done()
+	for {
+		select {
+		case <-ticker.C:
+			b.PrintSnapshot()
+		case <-done:
+			return
+		}
+	}
+}
+
+// snapshotFileName inserts sequence number before the extension of
+// the file, i.e. "/path/to/snapshot.json" becomes
+// "/path/to/snapshot.1.json".
+func snapshotFileName(path string, seq uint64) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%v.%v%v", strings.TrimSuffix(path, ext), seq, ext)
+}
@@ -0,0 +1,74 @@
+package lib
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshotFileName(t *testing.T) {
+	expectations := []struct {
+		path string
+		seq  uint64
+		out  string
+	}{
+		{"snapshot", 1, "snapshot.1"},
+		{"snapshot.json", 2, "snapshot.2.json"},
+		{"/path/to/snapshot.txt", 10, "/path/to/snapshot.10.txt"},
+	}
+	for _, e := range expectations {
+		if actual := snapshotFileName(e.path, e.seq); actual != e.out {
+			t.Errorf("Expected %q, but got %q", e.out, actual)
+		}
+	}
+}
+
+func TestBombardierPeriodicSnapshots(t *testing.T) {
+	s := httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			rw.WriteHeader(http.StatusOK)
+		}),
+	)
+	defer s.Close()
+	dir, err := ioutil.TempDir("", "snapshots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	testDuration := 1 * time.Second
+	b, e := NewBombardier(Config{
+		NumConns:         defaultNumberOfConns,
+		Duration:         &testDuration,
+		Url:              s.URL,
+		Headers:          new(HeadersList),
+		Timeout:          defaultTimeout,
+		Method:           "GET",
+		Format:           knownFormat("json"),
+		SnapshotInterval: 300 * time.Millisecond,
+		SnapshotPath:     filepath.Join(dir, "snapshot.json"),
+	})
+	if e != nil {
+		t.Error(e)
+		return
+	}
+	b.disableOutput()
+	b.Bombard()
+
+	for _, name := range []string{"snapshot.1.json", "snapshot.2.json"} {
+		contents, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if !strings.Contains(string(contents), `"result":`) {
+			t.Errorf("Unexpected contents of %v: %s", name, contents)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	uhist "github.com/codesenberg/concurrent/uint64/histogram"
+)
+
+// streamStats holds histograms describing streamed responses. Timings
+// are in microseconds.
+type streamStats struct {
+	firstByte  *uhist.Histogram
+	firstEvent *uhist.Histogram
+	gaps       *uhist.Histogram
+	durations  *uhist.Histogram
+	// events holds number of events per stream
+	events *uhist.Histogram
+}
+
+func newStreamStats() *streamStats {
+	return &streamStats{
+		firstByte:  uhist.Default(),
+		firstEvent: uhist.Default(),
+		gaps:       uhist.Default(),
+		durations:  uhist.Default(),
+		events:     uhist.Default(),
+	}
+}
+
+func usSince(start, t time.Time) uint64 {
+	return uint64(t.Sub(start).Nanoseconds() / 1000)
+}
+
+// record reads the response body as a stream of events and records
+// its timings. Time to first byte is recorded even if the stream
+// breaks, the rest only for streams read in full.
+func (s *streamStats) record(
+	body io.Reader, sse bool, start, firstByte time.Time,
+) error {
+	s.firstByte.Increment(usSince(start, firstByte))
+
+	es := eventScanner{sse: sse}
+	br := bufio.NewReader(body)
+	events := uint64(0)
+	var last time.Time
+	event := func(at time.Time) {
+		if events == 0 {
+			s.firstEvent.Increment(usSince(start, at))
+		} else {
+			s.gaps.Increment(usSince(last, at))
+		}
+		events++
+		last = at
+	}
+	for {
+		chunk, err := br.ReadSlice('\n')
+		if len(chunk) > 0 && es.feed(chunk) {
+			event(time.Now())
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF {
+			if es.eof() {
+				event(time.Now())
+			}
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	s.durations.Increment(usSince(start, time.Now()))
+	s.events.Increment(events)
+	return nil
+}
+
+func isEventStream(resp *http.Response) bool {
+	mt, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return err == nil && mt == "text/event-stream"
+}
+
+// eventScanner splits the stream into events. Server-Sent Events are
+// dispatched on empty lines, if they contain data, everything else is
+// treated as newline-delimited, i.e. each non-empty line is an event.
+type eventScanner struct {
+	sse bool
+
+	// lineLen is the length of the current line read so far
+	lineLen int
+	// isData tells whether the current line is SSE data field
+	isData bool
+	// pending tells whether SSE data was received since the last
+	// event
+	pending bool
+}
+
+var sseDataField = []byte("data")
+
+// feed processes a chunk returned by bufio.Reader.ReadSlice, that is
+// either a whole line or a part of it, and tells whether an event was
+// completed.
+func (es *eventScanner) feed(chunk []byte) bool {
+	complete := chunk[len(chunk)-1] == '\n'
+	content := chunk
+	if complete {
+		content = bytes.TrimRight(chunk, "\r\n")
+	}
+	if es.sse && es.lineLen == 0 && len(content) > 0 {
+		es.isData = bytes.HasPrefix(content, sseDataField) &&
+			(len(content) == len(sseDataField) ||
+				content[len(sseDataField)] == ':')
+	}
+	es.lineLen += len(content)
+	if !complete {
+		return false
+	}
+
+	empty := es.lineLen == 0
+	es.lineLen = 0
+	if !es.sse {
+		return !empty
+	}
+	if empty {
+		dispatched := es.pending
+		es.pending = false
+		return dispatched
+	}
+	if es.isData {
+		es.pending = true
+	}
+	es.isData = false
+	return false
+}
+
+// eof tells whether the unterminated last line completes an event.
+// Incomplete SSE events are discarded, as the specification requires.
+func (es *eventScanner) eof() bool {
+	return !es.sse && es.lineLen > 0
+}
@@ -0,0 +1,165 @@
+package lib
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// countEvents mimics streamStats.record, but with a tiny buffer to
+// exercise lines split across several chunks.
+func countEvents(t *testing.T, stream string, sse bool) int {
+	es := eventScanner{sse: sse}
+	br := bufio.NewReaderSize(strings.NewReader(stream), 16)
+	events := 0
+	for {
+		chunk, err := br.ReadSlice('\n')
+		if len(chunk) > 0 && es.feed(chunk) {
+			events++
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF {
+			if es.eof() {
+				events++
+			}
+			return events
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestEventScanner(t *testing.T) {
+	expectations := []struct {
+		stream string
+		sse    bool
+		events int
+	}{
+		{"", true, 0},
+		{"data: a\n\ndata: b\n\n", true, 2},
+		{"data: a\r\n\r\ndata: b\r\n\r\n", true, 2},
+		{"data: a\ndata: b\n\n", true, 1},
+		{"data\n\n", true, 1},
+		{": keep-alive\n\nevent: ping\n\n", true, 0},
+		{"datum: a\n\n", true, 0},
+		{"id: 1\nevent: token\ndata: {\"text\":\"hi\"}\n\n", true, 1},
+		{"data: " + strings.Repeat("x", 100) + "\n\n", true, 1},
+		{"data: incomplete\n", true, 0},
+		{"{}\n{}\n{}\n", false, 3},
+		{"{}\n\n{}\r\n", false, 2},
+		{"{}\n{}", false, 2},
+		{strings.Repeat("x", 100) + "\n" + strings.Repeat("y", 40), false, 2},
+	}
+	for _, exp := range expectations {
+		if act := countEvents(t, exp.stream, exp.sse); act != exp.events {
+			t.Errorf("%q (sse: %v): expected %v events, but got %v",
+				exp.stream, exp.sse, exp.events, act)
+		}
+	}
+}
+
+func TestBombardierStreamEvents(t *testing.T) {
+	testAllClients(t, testBombardierStreamEvents)
+}
+
+func testBombardierStreamEvents(clientType clientTyp, t *testing.T) {
+	if clientType == fhttp {
+		t.Skip("streams of events are only measured by net/http clients")
+	}
+	const (
+		eventsPerStream = 3
+		gap             = 10 * time.Millisecond
+	)
+	s := httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			rw.Header().Set("Content-Type", "text/event-stream")
+			rw.WriteHeader(http.StatusOK)
+			rw.(http.Flusher).Flush()
+			for i := 0; i < eventsPerStream; i++ {
+				time.Sleep(gap)
+				fmt.Fprintf(rw, ": comment\ndata: %v\n\n", i)
+				rw.(http.Flusher).Flush()
+			}
+		}),
+	)
+	defer s.Close()
+	numReqs := uint64(4)
+	b, e := NewBombardier(Config{
+		NumConns:     defaultNumberOfConns,
+		NumReqs:      &numReqs,
+		Url:          s.URL,
+		Headers:      new(HeadersList),
+		Timeout:      defaultTimeout,
+		Method:       "GET",
+		ClientType:   clientType,
+		StreamEvents: true,
+		Format:       knownFormat("plain-text"),
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	b.disableOutput()
+	b.Bombard()
+
+	ss := b.gatherInfo().Result.StreamStats(defaultPercentiles)
+	if ss == nil {
+		t.Fatal("Expected stream stats")
+	}
+	if ss.EventsPerStream == nil || ss.EventsPerStream.Mean != eventsPerStream {
+		t.Errorf("Expected %v events per stream, but got %+v",
+			eventsPerStream, ss.EventsPerStream)
+	}
+	if c := b.streams.gaps.Count(); c == 0 {
+		t.Error("Expected gaps between events to be recorded")
+	}
+	minGapUs := float64(gap / time.Microsecond)
+	if ss.TimeToFirstEvent == nil || ss.TimeToFirstEvent.Mean < minGapUs {
+		t.Errorf("Expected time to first event to be at least %v, "+
+			"but got %+v", gap, ss.TimeToFirstEvent)
+	}
+	if ss.InterEventGaps == nil || ss.InterEventGaps.Mean < minGapUs {
+		t.Errorf("Expected gaps to be at least %v, but got %+v",
+			gap, ss.InterEventGaps)
+	}
+	if ss.TimeToFirstByte == nil ||
+		ss.TimeToFirstByte.Max >= ss.TimeToFirstEvent.Mean {
+		t.Errorf("Expected first byte to precede first event, "+
+			"but got %+v", ss.TimeToFirstByte)
+	}
+	if ss.Durations == nil ||
+		ss.Durations.Mean < eventsPerStream*minGapUs {
+		t.Errorf("Expected streams to last at least %v, but got %+v",
+			eventsPerStream*gap, ss.Durations)
+	}
+	if b.req2xx != numReqs {
+		t.Errorf("Expected %v 2xx, but got %v", numReqs, b.req2xx)
+	}
+}
+
+func TestStreamStatsAbsentByDefault(t *testing.T) {
+	numReqs := uint64(1)
+	b, e := NewBombardier(Config{
+		NumConns:   defaultNumberOfConns,
+		NumReqs:    &numReqs,
+		Url:        "http://localhost:8080",
+		Headers:    new(HeadersList),
+		Timeout:    defaultTimeout,
+		Method:     "GET",
+		ClientType: nhttp1,
+		Format:     knownFormat("json"),
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if ss := b.gatherInfo().Result.StreamStats(nil); ss != nil {
+		t.Errorf("Expected no stream stats, but got %+v", ss)
+	}
+}
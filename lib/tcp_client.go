@@ -0,0 +1,241 @@
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"github.com/satori/go.uuid"
+)
+
+const (
+	defaultTCPTerminator = "\n"
+
+	// tcpResponseCode is returned for received responses, since
+	// there are no status codes in raw TCP.
+	tcpResponseCode = 0
+)
+
+// tcpClient sends payloads over raw TCP connections (one per worker)
+// and waits for responses, that are delimited either by terminator,
+// length prefix or fixed size.
+type tcpClient struct {
+	dial      func(string) (net.Conn, error)
+	addr      string
+	tlsConfig *tls.Config
+	timeout   time.Duration
+
+	// payload is nil if the body is either streamed or templated
+	payload  []byte
+	template *template.Template
+	bodProd  bodyStreamProducer
+	seq      uint64
+
+	terminator   []byte
+	lengthPrefix int
+	responseSize int
+
+	// idle holds established connections, that aren't used by any
+	// of the workers at the moment
+	idle chan *tcpConn
+}
+
+type tcpConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// tcpPayloadData is passed to payload templates.
+type tcpPayloadData struct {
+	// Seq is the sequence number of the request, starting from 1.
+	Seq uint64
+}
+
+var tcpPayloadFuncs = template.FuncMap{
+	"UUIDV1": uuid.NewV1,
+	"UUIDV2": uuid.NewV2,
+	"UUIDV3": uuid.NewV3,
+	"UUIDV4": uuid.NewV4,
+	"UUIDV5": uuid.NewV5,
+}
+
+func parseTCPPayload(body string) (*template.Template, error) {
+	return template.New("payload").Funcs(tcpPayloadFuncs).Parse(body)
+}
+
+func newTCPClient(opts *clientOpts) client {
+	c := new(tcpClient)
+	// Same dialer as fasthttp's, since it also closes connections
+	// once the test is aborted
+	c.dial = fasthttpDialFunc(opts)
+	u, err := url.Parse(opts.url)
+	if err != nil {
+		// opts.url guaranteed to be valid at this point
+		panic(err)
+	}
+	c.addr = u.Host
+	if u.Scheme == "tls" {
+		c.tlsConfig = &tls.Config{}
+		if opts.tlsConfig != nil {
+			c.tlsConfig = opts.tlsConfig.Clone()
+		}
+		if c.tlsConfig.ServerName == "" {
+			c.tlsConfig.ServerName = u.Hostname()
+		}
+	}
+	c.timeout = opts.timeout
+
+	switch {
+	case opts.body == nil:
+		c.bodProd = opts.bodProd
+	case strings.Contains(*opts.body, "{{"):
+		// Validated by Config.checkArgs
+		c.template = template.Must(parseTCPPayload(*opts.body))
+	default:
+		c.payload = []byte(*opts.body)
+	}
+
+	if opts.tcp == (TCPOptions{}) {
+		opts.tcp.Terminator = defaultTCPTerminator
+	}
+	c.terminator = []byte(opts.tcp.Terminator)
+	c.lengthPrefix = opts.tcp.LengthPrefix
+	c.responseSize = opts.tcp.ResponseSize
+	c.idle = make(chan *tcpConn, opts.maxConns)
+	return client(c)
+}
+
+func (c *tcpClient) do() (
+	code int, msTaken uint64, err error,
+) {
+	payload, err := c.nextPayload()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	start := time.Now()
+	var conn *tcpConn
+	select {
+	case conn = <-c.idle:
+	default:
+		conn, err = c.connect()
+		if err != nil {
+			msTaken = uint64(time.Since(start).Nanoseconds() / 1000)
+			return -1, msTaken, err
+		}
+		start = time.Now()
+	}
+	err = c.roundTrip(conn, payload)
+	msTaken = uint64(time.Since(start).Nanoseconds() / 1000)
+	if err != nil {
+		_ = conn.Close()
+		return -1, msTaken, err
+	}
+	// There are no more connections than workers, so this never
+	// blocks
+	c.idle <- conn
+	return tcpResponseCode, msTaken, nil
+}
+
+func (c *tcpClient) nextPayload() ([]byte, error) {
+	if c.payload != nil {
+		return c.payload, nil
+	}
+	if c.template != nil {
+		buf := new(bytes.Buffer)
+		data := tcpPayloadData{Seq: atomic.AddUint64(&c.seq, 1)}
+		if err := c.template.Execute(buf, data); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	bs, err := c.bodProd()
+	if err != nil {
+		return nil, err
+	}
+	payload, err := ioutil.ReadAll(bs)
+	_ = bs.Close()
+	return payload, err
+}
+
+func (c *tcpClient) connect() (*tcpConn, error) {
+	conn, err := c.dial(c.addr)
+	if err != nil {
+		return nil, err
+	}
+	if c.tlsConfig != nil {
+		if c.timeout > 0 {
+			_ = conn.SetDeadline(time.Now().Add(c.timeout))
+		}
+		tlsConn := tls.Client(conn, c.tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+	return &tcpConn{Conn: conn, r: bufio.NewReader(conn)}, nil
+}
+
+func (c *tcpClient) roundTrip(conn *tcpConn, payload []byte) error {
+	if c.timeout > 0 {
+		_ = conn.SetDeadline(time.Now().Add(c.timeout))
+	}
+	if _, err := conn.Write(payload); err != nil {
+		return err
+	}
+	switch {
+	case len(c.terminator) > 0:
+		return readUntilTerminator(conn.r, c.terminator)
+	case c.lengthPrefix > 0:
+		return readLengthPrefixed(conn.r, c.lengthPrefix)
+	default:
+		_, err := io.CopyN(ioutil.Discard, conn.r, int64(c.responseSize))
+		return err
+	}
+}
+
+// readUntilTerminator discards everything up to and including term.
+func readUntilTerminator(r *bufio.Reader, term []byte) error {
+	last := term[len(term)-1]
+	// tail holds last bytes read, enough to match term
+	tail := make([]byte, 0, 2*len(term))
+	for {
+		chunk, err := r.ReadSlice(last)
+		if len(chunk) >= len(term) {
+			tail = append(tail[:0], chunk[len(chunk)-len(term):]...)
+		} else {
+			tail = append(tail, chunk...)
+			if len(tail) > len(term) {
+				tail = append(tail[:0], tail[len(tail)-len(term):]...)
+			}
+		}
+		if err == nil && bytes.Equal(tail, term) {
+			return nil
+		}
+		if err != nil && err != bufio.ErrBufferFull {
+			return err
+		}
+	}
+}
+
+// readLengthPrefixed discards a message prefixed with its big-endian
+// length, that takes size bytes.
+func readLengthPrefixed(r *bufio.Reader, size int) error {
+	var prefix [8]byte
+	if _, err := io.ReadFull(r, prefix[8-size:]); err != nil {
+		return err
+	}
+	length := binary.BigEndian.Uint64(prefix[:])
+	_, err := io.CopyN(ioutil.Discard, r, int64(length))
+	return err
+}
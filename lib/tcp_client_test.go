@@ -0,0 +1,265 @@
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// tcpTestServer reads newline-terminated requests and writes
+// responses returned by respond.
+type tcpTestServer struct {
+	listener net.Listener
+	respond  func(req string) []byte
+
+	accepted uint64
+	ml       sync.Mutex
+	requests []string
+}
+
+func newTCPTestServer(
+	t *testing.T, respond func(req string) []byte,
+) *tcpTestServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &tcpTestServer{listener: l, respond: respond}
+	go s.serve()
+	return s
+}
+
+func (s *tcpTestServer) url() string {
+	return "tcp://" + s.listener.Addr().String()
+}
+
+func (s *tcpTestServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		atomic.AddUint64(&s.accepted, 1)
+		go s.handle(conn)
+	}
+}
+
+func (s *tcpTestServer) handle(conn net.Conn) {
+	defer func() {
+		_ = conn.Close()
+	}()
+	r := bufio.NewReader(conn)
+	for {
+		req, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		s.ml.Lock()
+		s.requests = append(s.requests, req)
+		s.ml.Unlock()
+		resp := s.respond(req)
+		if resp == nil {
+			return
+		}
+		if _, err := conn.Write(resp); err != nil {
+			return
+		}
+	}
+}
+
+func (s *tcpTestServer) Close() {
+	_ = s.listener.Close()
+}
+
+func newTCPTestBombardier(
+	t *testing.T, url string, numReqs uint64, body string, opts TCPOptions,
+) *Bombardier {
+	b, err := NewBombardier(Config{
+		NumConns:   2,
+		NumReqs:    &numReqs,
+		Url:        url,
+		Headers:    new(HeadersList),
+		Timeout:    defaultTimeout,
+		Method:     "GET",
+		Body:       body,
+		ClientType: rawTCP,
+		TCP:        opts,
+		Format:     knownFormat("plain-text"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.disableOutput()
+	return b
+}
+
+func TestReadUntilTerminator(t *testing.T) {
+	expectations := []struct {
+		in   string
+		term string
+		rest string
+		ok   bool
+	}{
+		{"+PONG\r\n", "\r\n", "", true},
+		{"a\rb\nc\r\nrest", "\r\n", "rest", true},
+		{strings.Repeat("x", 15) + "\r\nrest", "\r\n", "rest", true},
+		{strings.Repeat("x", 100) + "END", "END", "", true},
+		{"xxEN\nEND", "END", "", true},
+		{"no terminator\r", "\r\n", "", false},
+	}
+	for _, exp := range expectations {
+		r := bufio.NewReaderSize(strings.NewReader(exp.in), 16)
+		err := readUntilTerminator(r, []byte(exp.term))
+		if (err == nil) != exp.ok {
+			t.Errorf("%q: expected ok to be %v, but got %v",
+				exp.in, exp.ok, err)
+			continue
+		}
+		if !exp.ok {
+			continue
+		}
+		rest := new(bytes.Buffer)
+		_, _ = rest.ReadFrom(r)
+		if rest.String() != exp.rest {
+			t.Errorf("%q: expected %q to be left, but got %q",
+				exp.in, exp.rest, rest.String())
+		}
+	}
+}
+
+func TestReadLengthPrefixed(t *testing.T) {
+	for _, size := range []int{1, 2, 4, 8} {
+		msg := []byte("hello")
+		var prefix [8]byte
+		binary.BigEndian.PutUint64(prefix[:], uint64(len(msg)))
+		in := append(prefix[8-size:], msg...)
+		in = append(in, "rest"...)
+		r := bufio.NewReader(bytes.NewReader(in))
+		if err := readLengthPrefixed(r, size); err != nil {
+			t.Error(size, err)
+			continue
+		}
+		rest := new(bytes.Buffer)
+		_, _ = rest.ReadFrom(r)
+		if rest.String() != "rest" {
+			t.Errorf("%v: expected %q to be left, but got %q",
+				size, "rest", rest.String())
+		}
+	}
+}
+
+func TestBombardierRawTCP(t *testing.T) {
+	s := newTCPTestServer(t, func(req string) []byte {
+		if req != "PING\r\n" {
+			return []byte("-ERR unknown command\r\n")
+		}
+		return []byte("+PONG\r\n")
+	})
+	defer s.Close()
+	numReqs := uint64(20)
+	b := newTCPTestBombardier(
+		t, s.url(), numReqs, "PING\r\n", TCPOptions{Terminator: "\r\n"},
+	)
+	b.Bombard()
+
+	if b.tcpResponses != numReqs {
+		t.Errorf("Expected %v responses, but got %v",
+			numReqs, b.tcpResponses)
+	}
+	if b.others != 0 {
+		t.Errorf("Expected no failures, but got %v", b.others)
+	}
+	if a := atomic.LoadUint64(&s.accepted); a > b.Conf.NumConns {
+		t.Errorf("Expected connections to be reused, but got %v", a)
+	}
+	if b.bytesWritten != int64(numReqs)*int64(len("PING\r\n")) {
+		t.Errorf("Expected writes to be counted, but got %v",
+			b.bytesWritten)
+	}
+}
+
+func TestBombardierRawTCPTemplatedPayload(t *testing.T) {
+	s := newTCPTestServer(t, func(req string) []byte {
+		return []byte("OK\n")
+	})
+	defer s.Close()
+	numReqs := uint64(5)
+	b := newTCPTestBombardier(
+		t, s.url(), numReqs, "GET key:{{ .Seq }}\n", TCPOptions{},
+	)
+	b.Bombard()
+
+	if b.tcpResponses != numReqs {
+		t.Errorf("Expected %v responses, but got %v",
+			numReqs, b.tcpResponses)
+	}
+	s.ml.Lock()
+	defer s.ml.Unlock()
+	sort.Strings(s.requests)
+	exp := []string{
+		"GET key:1\n", "GET key:2\n", "GET key:3\n",
+		"GET key:4\n", "GET key:5\n",
+	}
+	if strings.Join(s.requests, "") != strings.Join(exp, "") {
+		t.Errorf("Expected %q, but got %q", exp, s.requests)
+	}
+}
+
+func TestBombardierRawTCPFraming(t *testing.T) {
+	expectations := []struct {
+		name string
+		resp []byte
+		opts TCPOptions
+	}{
+		{
+			"length prefix",
+			[]byte{0, 3, 'a', 'b', 'c'},
+			TCPOptions{LengthPrefix: 2},
+		},
+		{
+			"response size",
+			[]byte("abcdef"),
+			TCPOptions{ResponseSize: 6},
+		},
+	}
+	for _, exp := range expectations {
+		t.Run(exp.name, func(t *testing.T) {
+			resp := exp.resp
+			s := newTCPTestServer(t, func(string) []byte {
+				return resp
+			})
+			defer s.Close()
+			numReqs := uint64(10)
+			b := newTCPTestBombardier(t, s.url(), numReqs, "req\n", exp.opts)
+			b.Bombard()
+			if b.tcpResponses != numReqs {
+				t.Errorf("Expected %v responses, but got %v (errors: %v)",
+					numReqs, b.tcpResponses, b.errors.byFrequency())
+			}
+		})
+	}
+}
+
+func TestBombardierRawTCPClosedConnections(t *testing.T) {
+	s := newTCPTestServer(t, func(string) []byte {
+		return nil
+	})
+	defer s.Close()
+	numReqs := uint64(4)
+	b := newTCPTestBombardier(t, s.url(), numReqs, "req\n", TCPOptions{})
+	b.Bombard()
+
+	if b.others != numReqs || b.tcpResponses != 0 {
+		t.Errorf("Expected %v failures, but got %v (responses: %v)",
+			numReqs, b.others, b.tcpResponses)
+	}
+	if len(b.errors.byFrequency()) == 0 {
+		t.Error("Expected errors to be recorded")
+	}
+}
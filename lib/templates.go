@@ -6,6 +6,7 @@ var (
 	templates = map[string][]byte{
 		"plain-text": []byte(plainTextTemplate),
 		"json":       []byte(jsonTemplate),
+		"markdown":   []byte(markdownTemplate),
 	}
 )
 
@@ -29,6 +30,8 @@ func FormatFromString(formatSpec string) format {
 		return knownFormat("plain-text")
 	case "j", "json":
 		return knownFormat("json")
+	case "md", "markdown":
+		return knownFormat("markdown")
 	}
 	// nil represents unknown format
 	return nil
@@ -53,10 +56,91 @@ const (
 {{ else }}
 	{{- print "  There wasn't enough data to compute statistics for latencies." }}
 {{ end -}}
+{{ with .Result.StreamStats (FloatsToArray 0.5 0.75 0.9 0.99) -}}
+{{ with .TimeToFirstByte }}{{ printf "  %-10v %10v %10v %10v\n" "TTFB" (FormatTimeUs .Mean) (FormatTimeUs .Stddev) (FormatTimeUs .Max) }}{{ end -}}
+{{ with .TimeToFirstEvent }}{{ printf "  %-10v %10v %10v %10v\n" "TTF event" (FormatTimeUs .Mean) (FormatTimeUs .Stddev) (FormatTimeUs .Max) }}{{ end -}}
+{{ with .InterEventGaps }}{{ printf "  %-10v %10v %10v %10v\n" "Event gap" (FormatTimeUs .Mean) (FormatTimeUs .Stddev) (FormatTimeUs .Max) }}{{ end -}}
+{{ with .Durations }}{{ printf "  %-10v %10v %10v %10v\n" "Duration" (FormatTimeUs .Mean) (FormatTimeUs .Stddev) (FormatTimeUs .Max) }}{{ end -}}
+{{ with .EventsPerStream }}{{ printf "  %-10v %10.2f %10.2f %10.2f\n" "Events" .Mean .Stddev .Max }}{{ end -}}
+{{ end -}}
+{{ with .Result.ProxyConnectStats (FloatsToArray 0.5 0.75 0.9 0.99) -}}
+{{ printf "  %-10v %10v %10v %10v\n" "Proxy conn" (FormatTimeUs .Mean) (FormatTimeUs .Stddev) (FormatTimeUs .Max) }}
+{{- end -}}
+{{ with .Result.QUICHandshakeStats (FloatsToArray 0.5 0.75 0.9 0.99) -}}
+{{ printf "  %-10v %10v %10v %10v\n" "QUIC hs" (FormatTimeUs .Mean) (FormatTimeUs .Stddev) (FormatTimeUs .Max) }}
+{{- end -}}
+{{ with .Result.RedirectHopStats (FloatsToArray 0.5 0.75 0.9 0.99) -}}
+{{ printf "  %-10v %10v %10v %10v\n" "Redir hop" (FormatTimeUs .Mean) (FormatTimeUs .Stddev) (FormatTimeUs .Max) }}
+{{- end -}}
+{{ with .Result.BodySizeStats (FloatsToArray 0.5 0.75 0.9 0.99) -}}
+{{ printf "  %-10v %10v %10v %10v\n" "Body size" (FormatBinary .Mean) (FormatBinary .Stddev) (FormatBinary .Max) }}
+{{- end -}}
+{{ with .Result.ResponseSizeStats (FloatsToArray 0.5 0.75 0.9 0.99) -}}
+{{ printf "  %-10v %10v %10v %10v\n" "Resp size" (FormatBinary .Mean) (FormatBinary .Stddev) (FormatBinary .Max) }}
+{{- end -}}
 {{ with .Result -}}
+{{ if $.Spec.IsRawTCP -}}
+{{ "  TCP responses:" }}
+{{ printf "    received - %v" .TCPResponses }}
+{{- else if $.Spec.IsGRPC -}}
+{{ "  gRPC codes:" }}
+	{{- range $code, $count := .GRPCCodes }}
+		{{- printf "\n    %v - %v" $code $count }}
+	{{- end }}
+{{- else -}}
 {{ "  HTTP codes:" }}
 {{ printf "    1xx - %v, 2xx - %v, 3xx - %v, 4xx - %v, 5xx - %v" .Req1XX .Req2XX .Req3XX .Req4XX .Req5XX }}
+{{- end }}
 	{{- printf "\n    others - %v" .Others }}
+	{{- with .Redirects }}
+		{{- "\n  Redirects:" }}
+		{{- printf "\n    redirected requests - %v, followed - %v" .Chains .Followed }}
+		{{- range $code, $count := .Codes }}
+			{{- printf "\n      %v - %v" $code $count }}
+		{{- end }}
+	{{- end }}
+	{{- with .Connections }}
+		{{- "\n  Connections:" }}
+		{{- printf "\n    opened - %v, reused - %v, closed by peer - %v" .Opened .Reused .ClosedByPeer }}
+	{{- end }}
+	{{- with .Compression }}
+		{{- "\n  Compression:" }}
+		{{- printf "\n    decoded responses - %v, compressed - %v, decompressed - %v (%.2fx)" .Responses (FormatBinary (Uint64ToFloat64 .CompressedBytes)) (FormatBinary (Uint64ToFloat64 .DecompressedBytes)) .Ratio }}
+	{{- end }}
+	{{- with .Backends }}
+		{{- "\n  Backends:" }}
+		{{- range $addr, $s := . }}
+			{{- printf "\n    %v - connections - %v, dial failures - %v, read - %v, written - %v" $addr $s.Connections $s.DialFailures (FormatBinary (Uint64ToFloat64 $s.BytesRead)) (FormatBinary (Uint64ToFloat64 $s.BytesWritten)) }}
+		{{- end }}
+	{{- end }}
+	{{- with .Sources }}
+		{{- "\n  Sources:" }}
+		{{- range $addr, $s := . }}
+			{{- printf "\n    %v - connections - %v" $addr $s.Connections }}
+			{{- range $s.Errors }}
+				{{- printf "\n      %v - %v" .Error .Count }}
+			{{- end }}
+		{{- end }}
+	{{- end }}
+	{{- with .HTTP2 }}
+		{{- "\n  HTTP/2:" }}
+		{{- printf "\n    connections - %v, streams - %v (%.2f per connection)" .Connections .Streams .StreamsPerConnection }}
+		{{- printf "\n    GOAWAYs - %v, stream resets - %v" .GoAways .StreamResets }}
+	{{- end }}
+	{{- with .QUIC }}
+		{{- "\n  QUIC:" }}
+		{{- printf "\n    handshakes - %v, 0-RTT - %v" .Handshakes .ZeroRTT }}
+	{{- end }}
+	{{- with .WebSocket }}
+		{{- "\n  WebSocket:" }}
+		{{- printf "\n    connects - %v, connect failures - %v, messages - %v" .Connects .ConnectFailures .Messages }}
+		{{- with .CloseCodes }}
+			{{- "\n    close codes:" }}
+			{{- range $code, $count := . }}
+				{{- printf "\n      %v - %v" $code $count }}
+			{{- end }}
+		{{- end }}
+	{{- end }}
 	{{- with .Errors }}
 		{{- "\n  Errors:"}}
 		{{- range . }}
@@ -64,7 +148,10 @@ const (
 		{{- end -}}
 	{{ end -}}
 {{ end }}
-{{ printf "  %-10v %10v/s" "Throughput:" (FormatBinary .Result.Throughput)}}`
+{{ printf "  %-10v %10v/s" "Throughput:" (FormatBinary .Result.Throughput)}}
+{{- if .Result.Cancelled }}
+{{ printf "  Test was cancelled, effective duration: %.2fs" .Result.TimeTaken.Seconds }}
+{{- end }}`
 	jsonTemplate = `{"spec":{
 {{- with .Spec -}}
 "numberOfConnections":{{ .NumberOfConnections }}
@@ -98,6 +185,9 @@ const (
 {{- if .KeyPath -}}
 ,"keyPath":{{ .KeyPath | printf "%q" }}
 {{- end -}}
+{{- if .Proxy -}}
+,"proxy":{{ .Proxy | printf "%q" }}
+{{- end -}}
 
 ,"stream":{{ .Stream }},"timeoutSeconds":{{ .Timeout.Seconds }}
 
@@ -110,6 +200,24 @@ const (
 {{- if .IsNetHTTPV2 -}}
 ,"client":"net/http.v2"
 {{- end -}}
+{{- if .IsGRPC -}}
+,"client":"grpc"
+{{- end -}}
+{{- if .IsWebSocket -}}
+,"client":"websocket"
+{{- end -}}
+{{- if .IsRawTCP -}}
+,"client":"tcp"
+{{- end -}}
+{{- if .IsHTTP3 -}}
+,"client":"http3"
+{{- end -}}
+{{- if .IsPipelined -}}
+,"client":"fasthttp-pipelined","pipelineDepth":{{ .PipelineDepth }}
+{{- end -}}
+{{- if .IsCustomClient -}}
+,"client":{{ .CustomClient | printf "%q" }}
+{{- end -}}
 
 {{- with .Rate -}}
 ,"rate":{{ . }}
@@ -121,6 +229,9 @@ const (
 "result":{"bytesRead":{{ .BytesRead -}}
 ,"bytesWritten":{{ .BytesWritten -}}
 ,"timeTakenSeconds":{{ .TimeTaken.Seconds -}}
+{{- if .Cancelled -}}
+,"cancelled":true
+{{- end -}}
 
 ,"req1xx":{{ .Req1XX -}}
 ,"req2xx":{{ .Req2XX -}}
@@ -128,6 +239,100 @@ const (
 ,"req4xx":{{ .Req4XX -}}
 ,"req5xx":{{ .Req5XX -}}
 ,"others":{{ .Others -}}
+{{- if $.Spec.IsRawTCP -}}
+,"tcpResponses":{{ .TCPResponses -}}
+{{- end -}}
+{{- with .GRPCCodes -}}
+,"grpcCodes":{
+{{- $first := true -}}
+{{- range $code, $count := . -}}
+{{- if not $first -}},{{- end -}}
+{{- $first = false -}}
+{{ printf "%q:%v" $code $count }}
+{{- end -}}
+}
+{{- end -}}
+{{- with .Connections -}}
+,"connections":{"opened":{{ .Opened -}}
+,"reused":{{ .Reused -}}
+,"closedByPeer":{{ .ClosedByPeer -}}
+}
+{{- end -}}
+{{- with .Redirects -}}
+,"redirects":{"chains":{{ .Chains -}}
+,"codes":{
+{{- $first := true -}}
+{{- range $code, $count := .Codes -}}
+{{- if not $first -}},{{- end -}}
+{{- $first = false -}}
+{{ printf "\"%v\":%v" $code $count }}
+{{- end -}}
+}}
+{{- end -}}
+{{- with .Compression -}}
+,"compression":{"responses":{{ .Responses -}}
+,"compressedBytes":{{ .CompressedBytes -}}
+,"decompressedBytes":{{ .DecompressedBytes -}}
+}
+{{- end -}}
+{{- with .Backends -}}
+,"backends":{
+{{- $first := true -}}
+{{- range $addr, $s := . -}}
+{{- if not $first -}},{{- end -}}
+{{- $first = false -}}
+{{ printf "%q" $addr }}:{"connections":{{ $s.Connections -}}
+,"dialFailures":{{ $s.DialFailures -}}
+,"bytesRead":{{ $s.BytesRead -}}
+,"bytesWritten":{{ $s.BytesWritten -}}
+}
+{{- end -}}
+}
+{{- end -}}
+{{- with .Sources -}}
+,"sources":{
+{{- $first := true -}}
+{{- range $addr, $s := . -}}
+{{- if not $first -}},{{- end -}}
+{{- $first = false -}}
+{{ printf "%q" $addr }}:{"connections":{{ $s.Connections -}}
+,"errors":[
+{{- range $i, $e := $s.Errors -}}
+{{- if $i -}},{{- end -}}
+{"description":{{ printf "%q" $e.Error }},"count":{{ $e.Count }}}
+{{- end -}}
+]}
+{{- end -}}
+}
+{{- end -}}
+{{- with .HTTP2 -}}
+,"http2":{"connections":{{ .Connections -}}
+,"streams":{{ .Streams -}}
+,"goAways":{{ .GoAways -}}
+,"streamResets":{{ .StreamResets -}}
+}
+{{- end -}}
+{{- with .QUIC -}}
+,"quic":{"handshakes":{{ .Handshakes -}}
+,"zeroRTT":{{ .ZeroRTT -}}
+}
+{{- end -}}
+{{- with .WebSocket -}}
+,"websocket":{"connects":{{ .Connects -}}
+,"connectFailures":{{ .ConnectFailures -}}
+,"messages":{{ .Messages -}}
+{{- with .CloseCodes -}}
+,"closeCodes":{
+{{- $first := true -}}
+{{- range $code, $count := . -}}
+{{- if not $first -}},{{- end -}}
+{{- $first = false -}}
+{{ printf "\"%v\":%v" $code $count }}
+{{- end -}}
+}
+{{- end -}}
+}
+{{- end -}}
 
 {{- with .Errors -}}
 ,"errors":[
@@ -166,6 +371,223 @@ const (
 {{- end -}}
 }}
 {{- end -}}
+
+{{- with .StreamStats (FloatsToArray 0.5 0.75 0.9 0.99) -}}
+,"streams":{"timeToFirstByte":{{ template "streamStats" .TimeToFirstByte -}}
+,"timeToFirstEvent":{{ template "streamStats" .TimeToFirstEvent -}}
+,"interEventGaps":{{ template "streamStats" .InterEventGaps -}}
+,"durations":{{ template "streamStats" .Durations -}}
+,"eventsPerStream":{{ template "streamStats" .EventsPerStream -}}
+}
+{{- end -}}
+{{- with .ProxyConnectStats (FloatsToArray 0.5 0.75 0.9 0.99) -}}
+,"proxyConnect":{{ template "streamStats" . -}}
+{{- end -}}
+{{- with .QUICHandshakeStats (FloatsToArray 0.5 0.75 0.9 0.99) -}}
+,"quicHandshake":{{ template "streamStats" . -}}
+{{- end -}}
+{{- with .RedirectHopStats (FloatsToArray 0.5 0.75 0.9 0.99) -}}
+,"redirectHop":{{ template "streamStats" . -}}
+{{- end -}}
+{{- with .BodySizeStats (FloatsToArray 0.5 0.75 0.9 0.99) -}}
+,"bodySize":{{ template "streamStats" . -}}
+{{- end -}}
+{{- with .ResponseSizeStats (FloatsToArray 0.5 0.75 0.9 0.99) -}}
+,"responseSize":{{ template "streamStats" . -}}
+{{- end -}}
+}}
+{{- end -}}
+{{- define "streamStats" -}}
+{{- with . -}}
+{"mean":{{ .Mean -}}
+,"stddev":{{ .Stddev -}}
+,"max":{{ .Max -}}
+,"percentiles":{
+{{- range $pc, $v := .Percentiles }}
+{{- if ne $pc 0.5 -}},{{- end -}}
+{{- printf "\"%2.0f\":%d" (Multiply $pc 100) $v -}}
+{{- end -}}
 }}
+{{- else -}}
+null
+{{- end -}}
 {{- end -}}`
+	markdownTemplate = `
+{{- $latencies := .Result.LatenciesStats (FloatsToArray 0.5 0.75 0.9 0.99) -}}
+{{- $rps := .Result.RequestsStats (FloatsToArray 0.5 0.75 0.9 0.99) -}}
+| Statistics | Avg | Stdev | Max |
+| --- | ---: | ---: | ---: |
+{{ with $rps -}}
+	{{ printf "| Reqs/sec | %.2f | %.2f | %.2f |" .Mean .Stddev .Max }}
+{{- else -}}
+	{{ print "| Reqs/sec | n/a | n/a | n/a |" }}
+{{- end }}
+{{ with $latencies -}}
+	{{ printf "| Latency | %v | %v | %v |" (FormatTimeUs .Mean) (FormatTimeUs .Stddev) (FormatTimeUs .Max) }}
+{{- else -}}
+	{{ print "| Latency | n/a | n/a | n/a |" }}
+{{- end }}
+{{ with .Result.StreamStats (FloatsToArray 0.5 0.75 0.9 0.99) -}}
+{{ with .TimeToFirstByte }}{{ printf "| TTFB | %v | %v | %v |\n" (FormatTimeUs .Mean) (FormatTimeUs .Stddev) (FormatTimeUs .Max) }}{{ end -}}
+{{ with .TimeToFirstEvent }}{{ printf "| Time to first event | %v | %v | %v |\n" (FormatTimeUs .Mean) (FormatTimeUs .Stddev) (FormatTimeUs .Max) }}{{ end -}}
+{{ with .InterEventGaps }}{{ printf "| Gap between events | %v | %v | %v |\n" (FormatTimeUs .Mean) (FormatTimeUs .Stddev) (FormatTimeUs .Max) }}{{ end -}}
+{{ with .Durations }}{{ printf "| Stream duration | %v | %v | %v |\n" (FormatTimeUs .Mean) (FormatTimeUs .Stddev) (FormatTimeUs .Max) }}{{ end -}}
+{{ with .EventsPerStream }}{{ printf "| Events per stream | %.2f | %.2f | %.2f |\n" .Mean .Stddev .Max }}{{ end -}}
+{{ end -}}
+{{ with .Result.ProxyConnectStats (FloatsToArray 0.5 0.75 0.9 0.99) -}}
+{{ printf "| Proxy connect | %v | %v | %v |\n" (FormatTimeUs .Mean) (FormatTimeUs .Stddev) (FormatTimeUs .Max) }}
+{{- end -}}
+{{ with .Result.QUICHandshakeStats (FloatsToArray 0.5 0.75 0.9 0.99) -}}
+{{ printf "| QUIC handshake | %v | %v | %v |\n" (FormatTimeUs .Mean) (FormatTimeUs .Stddev) (FormatTimeUs .Max) }}
+{{- end -}}
+{{ with .Result.RedirectHopStats (FloatsToArray 0.5 0.75 0.9 0.99) -}}
+{{ printf "| Redirect hop | %v | %v | %v |\n" (FormatTimeUs .Mean) (FormatTimeUs .Stddev) (FormatTimeUs .Max) }}
+{{- end -}}
+{{ with .Result.BodySizeStats (FloatsToArray 0.5 0.75 0.9 0.99) -}}
+{{ printf "| Body size | %v | %v | %v |\n" (FormatBinary .Mean) (FormatBinary .Stddev) (FormatBinary .Max) }}
+{{- end -}}
+{{ with .Result.ResponseSizeStats (FloatsToArray 0.5 0.75 0.9 0.99) -}}
+{{ printf "| Response size | %v | %v | %v |\n" (FormatBinary .Mean) (FormatBinary .Stddev) (FormatBinary .Max) }}
+{{- end -}}
+{{ with $latencies -}}
+{{- if WithLatencies }}
+| Percentile | Latency |
+| ---: | ---: |
+	{{- range $pc, $lat := .Percentiles }}
+		{{- printf "\n| %2.0f%% | %v |" (Multiply $pc 100) (FormatTimeUsUint64 $lat) }}
+	{{- end }}
+{{ end -}}
+{{- end }}
+{{- with .Result }}
+{{- if $.Spec.IsRawTCP }}
+| TCP responses | Count |
+| --- | ---: |
+| received | {{ .TCPResponses }} |
+{{- else if $.Spec.IsGRPC }}
+| gRPC codes | Count |
+| --- | ---: |
+	{{- range $code, $count := .GRPCCodes }}
+		{{- printf "\n| %v | %v |" $code $count }}
+	{{- end }}
+{{- else }}
+| HTTP codes | Count |
+| --- | ---: |
+| 1xx | {{ .Req1XX }} |
+| 2xx | {{ .Req2XX }} |
+| 3xx | {{ .Req3XX }} |
+| 4xx | {{ .Req4XX }} |
+| 5xx | {{ .Req5XX }} |
+{{- end }}
+| others | {{ .Others }} |
+{{- with .Redirects }}
+
+| Redirects | Count |
+| --- | ---: |
+| redirected requests | {{ .Chains }} |
+| followed | {{ .Followed }} |
+	{{- range $code, $count := .Codes }}
+		{{- printf "\n| followed %v | %v |" $code $count }}
+	{{- end }}
+{{- end }}
+{{- with .Connections }}
+
+| Connections | Count |
+| --- | ---: |
+| opened | {{ .Opened }} |
+| reused | {{ .Reused }} |
+| closed by peer | {{ .ClosedByPeer }} |
+{{- end }}
+{{- with .Compression }}
+
+| Compression | Value |
+| --- | ---: |
+| decoded responses | {{ .Responses }} |
+| compressed | {{ FormatBinary (Uint64ToFloat64 .CompressedBytes) }} |
+| decompressed | {{ FormatBinary (Uint64ToFloat64 .DecompressedBytes) }} |
+| ratio | {{ printf "%.2fx" .Ratio }} |
+{{- end }}
+{{- with .Backends }}
+
+| Backends | Connections | Dial failures | Read | Written |
+| --- | ---: | ---: | ---: | ---: |
+	{{- range $addr, $s := . }}
+		{{- printf "\n| %v | %v | %v | %v | %v |" $addr $s.Connections $s.DialFailures (FormatBinary (Uint64ToFloat64 $s.BytesRead)) (FormatBinary (Uint64ToFloat64 $s.BytesWritten)) }}
+	{{- end }}
+{{- end }}
+{{- with .Sources }}
+
+| Sources | Count |
+| --- | ---: |
+	{{- range $addr, $s := . }}
+		{{- printf "\n| %v connections | %v |" $addr $s.Connections }}
+		{{- range $s.Errors }}
+			{{- printf "\n| %v %v | %v |" $addr (EscapeMarkdown .Error) .Count }}
+		{{- end }}
+	{{- end }}
+{{- end }}
+{{- with .HTTP2 }}
+
+| HTTP/2 | Count |
+| --- | ---: |
+| connections | {{ .Connections }} |
+| streams | {{ .Streams }} |
+| streams per connection | {{ printf "%.2f" .StreamsPerConnection }} |
+| GOAWAYs | {{ .GoAways }} |
+| stream resets | {{ .StreamResets }} |
+{{- end }}
+{{- with .QUIC }}
+
+| QUIC | Count |
+| --- | ---: |
+| handshakes | {{ .Handshakes }} |
+| 0-RTT | {{ .ZeroRTT }} |
+{{- end }}
+{{- with .WebSocket }}
+
+| WebSocket | Count |
+| --- | ---: |
+| connects | {{ .Connects }} |
+| connect failures | {{ .ConnectFailures }} |
+| messages | {{ .Messages }} |
+	{{- range $code, $count := .CloseCodes }}
+		{{- printf "\n| close code %v | %v |" $code $count }}
+	{{- end }}
+{{- end }}
+{{ with .Errors }}
+| Errors | Count |
+| --- | ---: |
+	{{- range . }}
+		{{- printf "\n| %v | %v |" (EscapeMarkdown .Error) .Count }}
+	{{- end }}
+{{ end -}}
+{{- end }}
+**Throughput:** {{ FormatBinary .Result.Throughput }}/s
+{{- if .Result.Cancelled }}
+
+**Test was cancelled,** effective duration: {{ printf "%.2fs" .Result.TimeTaken.Seconds }}
+{{- end }}
+{{- with .Baseline }}
+
+| Compared to baseline | Baseline | Current | Change |
+| --- | ---: | ---: | ---: |
+	{{- if and .RPS $rps }}
+		{{- printf "\n| Reqs/sec | %.2f | %.2f | %v |" .RPS.Mean $rps.Mean (PercentChange .RPS.Mean $rps.Mean) }}
+	{{- end }}
+	{{- if and .Latency $latencies }}
+		{{- printf "\n| Latency (avg) | %v | %v | %v |" (FormatTimeUs .Latency.Mean) (FormatTimeUs $latencies.Mean) (PercentChange .Latency.Mean $latencies.Mean) }}
+		{{- printf "\n| Latency (max) | %v | %v | %v |" (FormatTimeUs .Latency.Max) (FormatTimeUs $latencies.Max) (PercentChange .Latency.Max $latencies.Max) }}
+		{{- $base := .Latency.Percentiles }}
+		{{- range $pc, $lat := $latencies.Percentiles }}
+			{{- with index $base (printf "%2.0f" (Multiply $pc 100)) }}
+				{{- printf "\n| Latency (p%2.0f) | %v | %v | %v |" (Multiply $pc 100) (FormatTimeUs .) (FormatTimeUsUint64 $lat) (PercentChange . (Uint64ToFloat64 $lat)) }}
+			{{- end }}
+		{{- end }}
+	{{- end }}
+	{{- if gt .TimeTakenSeconds 0.0 }}
+		{{- printf "\n| Throughput | %v/s | %v/s | %v |" (FormatBinary .Throughput) (FormatBinary $.Result.Throughput) (PercentChange .Throughput $.Result.Throughput) }}
+	{{- else }}
+		{{- printf "\n| Throughput | n/a | %v/s | n/a |" (FormatBinary $.Result.Throughput) }}
+	{{- end }}
+{{- end }}
+`
 )
@@ -0,0 +1,472 @@
+package lib
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/goware/urlx"
+	"github.com/tony24681379/bombardier/internal"
+)
+
+const (
+	wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xa
+
+	wsCloseNormal   = 1000
+	wsCloseNoStatus = 1005
+
+	// wsMessageCode is returned for echoed messages to tell them
+	// apart from handshakes, that are reported using HTTP codes.
+	wsMessageCode = 0
+
+	// wsCloseTimeout limits the time spent on closing handshakes
+	// when the test is over.
+	wsCloseTimeout = time.Second
+)
+
+// wsClient either measures latency of WebSocket handshakes or keeps a
+// connection per worker open and measures round-trip time of messages
+// echoed by the server.
+type wsClient struct {
+	ctx       context.Context
+	dial      func(context.Context, string, string) (net.Conn, error)
+	tlsConfig *tls.Config
+	timeout   time.Duration
+
+	url     *url.URL
+	addr    string
+	tls     bool
+	headers http.Header
+
+	echo bool
+	// message is nil if the body is streamed
+	message []byte
+	bodProd bodyStreamProducer
+
+	// idle holds established connections, that aren't used by any
+	// of the workers at the moment
+	idle chan *wsConn
+
+	cl    sync.Mutex
+	conns map[*wsConn]struct{}
+
+	connects, connectFailures uint64
+	closeCodes                map[int]uint64
+}
+
+func newWSClient(opts *clientOpts) client {
+	c := new(wsClient)
+	c.ctx = opts.context()
+	c.dial = httpDialContextFunc(opts)
+	c.timeout = opts.timeout
+
+	u, err := urlx.Parse(opts.url)
+	if err != nil {
+		// opts.url guaranteed to be valid at this point
+		panic(err)
+	}
+	c.tls = u.Scheme == "wss" || u.Scheme == "https"
+	c.url = u
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if c.tls {
+			port = "443"
+		}
+	}
+	c.addr = net.JoinHostPort(u.Hostname(), port)
+	c.tlsConfig = opts.tlsConfig
+	if c.tls && c.tlsConfig != nil && c.tlsConfig.ServerName == "" {
+		c.tlsConfig = c.tlsConfig.Clone()
+		c.tlsConfig.ServerName = u.Hostname()
+	}
+	c.headers = headersToHTTPHeaders(opts.headers)
+
+	c.echo = opts.wsEcho
+	if opts.body != nil {
+		c.message = []byte(*opts.body)
+	} else {
+		c.bodProd = opts.bodProd
+	}
+	c.idle = make(chan *wsConn, opts.maxConns)
+	c.conns = make(map[*wsConn]struct{})
+	c.closeCodes = make(map[int]uint64)
+
+	if done := c.ctx.Done(); done != nil {
+		// Connections are closed once the test is aborted, which
+		// also interrupts messages in flight
+		go func() {
+			<-done
+			c.closeAll()
+		}()
+	}
+	return client(c)
+}
+
+func (c *wsClient) do() (
+	code int, msTaken uint64, err error,
+) {
+	start := time.Now()
+	if !c.echo {
+		var conn *wsConn
+		conn, code, err = c.connect()
+		msTaken = uint64(time.Since(start).Nanoseconds() / 1000)
+		if err == nil {
+			c.release(conn, wsCloseNormal)
+		}
+		return
+	}
+
+	var conn *wsConn
+	select {
+	case conn = <-c.idle:
+	default:
+		conn, code, err = c.connect()
+		if err != nil {
+			msTaken = uint64(time.Since(start).Nanoseconds() / 1000)
+			return
+		}
+		start = time.Now()
+	}
+	err = c.roundTrip(conn)
+	msTaken = uint64(time.Since(start).Nanoseconds() / 1000)
+	if err != nil {
+		c.forget(conn)
+		_ = conn.Close()
+		return -1, msTaken, err
+	}
+	// There are no more connections than workers, so this never
+	// blocks
+	c.idle <- conn
+	return wsMessageCode, msTaken, nil
+}
+
+func (c *wsClient) connect() (*wsConn, int, error) {
+	conn, code, err := c.handshake()
+	if err != nil {
+		atomic.AddUint64(&c.connectFailures, 1)
+		return nil, code, err
+	}
+	atomic.AddUint64(&c.connects, 1)
+	c.cl.Lock()
+	c.conns[conn] = struct{}{}
+	c.cl.Unlock()
+	return conn, code, nil
+}
+
+func (c *wsClient) handshake() (*wsConn, int, error) {
+	netConn, err := c.dial(c.ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, -1, err
+	}
+	conn, code, err := c.upgrade(netConn)
+	if err != nil {
+		_ = netConn.Close()
+		return nil, code, err
+	}
+	return conn, code, nil
+}
+
+func (c *wsClient) upgrade(conn net.Conn) (*wsConn, int, error) {
+	if c.timeout > 0 {
+		_ = conn.SetDeadline(time.Now().Add(c.timeout))
+	}
+	if c.tls {
+		tlsConn := tls.Client(conn, c.tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			return nil, -1, err
+		}
+		conn = tlsConn
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, -1, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+	req := &http.Request{
+		Method:     "GET",
+		URL:        c.url,
+		Host:       c.url.Host,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header, len(c.headers)+4),
+	}
+	for k, v := range c.headers {
+		req.Header[k] = v
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if err := req.Write(conn); err != nil {
+		return nil, -1, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, -1, err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return nil, resp.StatusCode, errWebSocketHandshakeRejected
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != wsAcceptKey(key) {
+		return nil, resp.StatusCode, errInvalidWebSocketAccept
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return &wsConn{Conn: conn, r: br}, resp.StatusCode, nil
+}
+
+func wsAcceptKey(key string) string {
+	h := sha1.New()
+	_, _ = io.WriteString(h, key+wsGUID)
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func (c *wsClient) roundTrip(conn *wsConn) error {
+	msg := c.message
+	if msg == nil {
+		bs, err := c.bodProd()
+		if err != nil {
+			return err
+		}
+		msg, err = ioutil.ReadAll(bs)
+		_ = bs.Close()
+		if err != nil {
+			return err
+		}
+	}
+	op := byte(wsOpBinary)
+	if utf8.Valid(msg) {
+		op = wsOpText
+	}
+
+	if c.timeout > 0 {
+		_ = conn.SetDeadline(time.Now().Add(c.timeout))
+		defer func() { _ = conn.SetDeadline(time.Time{}) }()
+	}
+	if err := conn.writeFrame(op, msg); err != nil {
+		return err
+	}
+	err := conn.readMessage()
+	if ce, ok := err.(*wsCloseError); ok {
+		c.recordClose(ce.code)
+		// Reply to the closing handshake, while we still can
+		_ = conn.writeFrame(wsOpClose, ce.payload)
+		return errWebSocketClosed
+	}
+	return err
+}
+
+func (c *wsClient) recordClose(code int) {
+	c.cl.Lock()
+	c.closeCodes[code]++
+	c.cl.Unlock()
+}
+
+func (c *wsClient) forget(conn *wsConn) {
+	c.cl.Lock()
+	delete(c.conns, conn)
+	c.cl.Unlock()
+}
+
+// release performs closing handshake (without waiting for the
+// server's reply) and closes the connection.
+func (c *wsClient) release(conn *wsConn, code int) {
+	c.forget(conn)
+	_ = conn.SetWriteDeadline(time.Now().Add(wsCloseTimeout))
+	_ = conn.writeFrame(wsOpClose, wsClosePayload(code))
+	_ = conn.Close()
+}
+
+func (c *wsClient) closeAll() {
+	c.cl.Lock()
+	conns := make([]*wsConn, 0, len(c.conns))
+	for conn := range c.conns {
+		conns = append(conns, conn)
+	}
+	c.cl.Unlock()
+	for _, conn := range conns {
+		c.release(conn, wsCloseNormal)
+	}
+}
+
+func (c *wsClient) stats() *internal.WebSocketStats {
+	c.cl.Lock()
+	defer c.cl.Unlock()
+	s := &internal.WebSocketStats{
+		Connects:        atomic.LoadUint64(&c.connects),
+		ConnectFailures: atomic.LoadUint64(&c.connectFailures),
+	}
+	if len(c.closeCodes) > 0 {
+		s.CloseCodes = make(map[int]uint64, len(c.closeCodes))
+		for code, count := range c.closeCodes {
+			s.CloseCodes[code] = count
+		}
+	}
+	return s
+}
+
+type wsConn struct {
+	net.Conn
+	r *bufio.Reader
+	// wl serializes writes, since closing handshakes might be
+	// performed concurrently with the worker using the connection
+	wl sync.Mutex
+}
+
+// writeFrame writes a single masked frame, as required from clients.
+func (wc *wsConn) writeFrame(op byte, payload []byte) error {
+	frame := make([]byte, 0, 14+len(payload))
+	frame = append(frame, 0x80|op)
+	const masked = 0x80
+	switch l := len(payload); {
+	case l <= 125:
+		frame = append(frame, masked|byte(l))
+	case l <= 0xffff:
+		frame = append(frame, masked|126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(l))
+	default:
+		frame = append(frame, masked|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[2:], uint64(l))
+	}
+	var mask [4]byte
+	if _, err := rand.Read(mask[:]); err != nil {
+		return err
+	}
+	frame = append(frame, mask[:]...)
+	start := len(frame)
+	frame = append(frame, payload...)
+	for i := range payload {
+		frame[start+i] ^= mask[i%4]
+	}
+
+	wc.wl.Lock()
+	defer wc.wl.Unlock()
+	_, err := wc.Conn.Write(frame)
+	return err
+}
+
+// readMessage reads frames until a complete data message is received,
+// answering pings along the way. Contents of the message are
+// discarded.
+func (wc *wsConn) readMessage() error {
+	for {
+		fin, op, payload, err := wc.readFrame()
+		if err != nil {
+			return err
+		}
+		switch op {
+		case wsOpPing:
+			if err := wc.writeFrame(wsOpPong, payload); err != nil {
+				return err
+			}
+		case wsOpPong:
+		case wsOpClose:
+			code := wsCloseNoStatus
+			if len(payload) >= 2 {
+				code = int(binary.BigEndian.Uint16(payload))
+			}
+			return &wsCloseError{code: code, payload: payload}
+		case wsOpText, wsOpBinary, wsOpContinuation:
+			if fin {
+				return nil
+			}
+		default:
+			return errInvalidWebSocketFrame
+		}
+	}
+}
+
+// readFrame reads a single frame. Payload is only returned for control
+// frames, payload of data frames is discarded.
+func (wc *wsConn) readFrame() (
+	fin bool, op byte, payload []byte, err error,
+) {
+	var header [2]byte
+	if _, err = io.ReadFull(wc.r, header[:]); err != nil {
+		return
+	}
+	fin = header[0]&0x80 != 0
+	op = header[0] & 0x0f
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(wc.r, ext[:]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(wc.r, ext[:]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	var mask [4]byte
+	if masked {
+		if _, err = io.ReadFull(wc.r, mask[:]); err != nil {
+			return
+		}
+	}
+
+	isControl := op&0x8 != 0
+	if !isControl {
+		_, err = io.CopyN(ioutil.Discard, wc.r, int64(length))
+		return
+	}
+	if length > 125 {
+		err = errInvalidWebSocketFrame
+		return
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(wc.r, payload); err != nil {
+		return
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return
+}
+
+func wsClosePayload(code int) []byte {
+	payload := make([]byte, 2)
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	return payload
+}
+
+type wsCloseError struct {
+	code    int
+	payload []byte
+}
+
+func (e *wsCloseError) Error() string {
+	return errWebSocketClosed.Error()
+}
@@ -0,0 +1,245 @@
+package lib
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// wsEchoServer is a minimal WebSocket server, that echoes data frames
+// back and closes connections with closeCode after closeAfter
+// messages, if closeAfter is positive.
+type wsEchoServer struct {
+	t *testing.T
+
+	closeAfter int
+	closeCode  int
+
+	ml       sync.Mutex
+	messages []string
+}
+
+func (s *wsEchoServer) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Upgrade") != "websocket" {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	conn, brw, err := rw.(http.Hijacker).Hijack()
+	if err != nil {
+		s.t.Error(err)
+		return
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	key := r.Header.Get("Sec-WebSocket-Key")
+	_, _ = io.WriteString(conn, "HTTP/1.1 101 Switching Protocols\r\n"+
+		"Upgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Accept: "+wsAcceptKey(key)+"\r\n\r\n")
+
+	received := 0
+	for {
+		op, payload, err := readClientFrame(brw.Reader)
+		if err != nil {
+			return
+		}
+		switch op {
+		case wsOpClose:
+			_, _ = conn.Write(serverFrame(wsOpClose, payload))
+			return
+		case wsOpText, wsOpBinary:
+			s.ml.Lock()
+			s.messages = append(s.messages, string(payload))
+			s.ml.Unlock()
+			received++
+			if s.closeAfter > 0 && received >= s.closeAfter {
+				_, _ = conn.Write(
+					serverFrame(wsOpClose, wsClosePayload(s.closeCode)),
+				)
+				return
+			}
+			_, _ = conn.Write(serverFrame(wsOpPing, []byte("ping")))
+			_, _ = conn.Write(serverFrame(op, payload))
+		}
+	}
+}
+
+func readClientFrame(r *bufio.Reader) (byte, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(r, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return header[0] & 0x0f, payload, nil
+}
+
+func serverFrame(op byte, payload []byte) []byte {
+	// Tests only use short payloads
+	frame := []byte{0x80 | op, byte(len(payload))}
+	return append(frame, payload...)
+}
+
+func newWSTestBombardier(
+	t *testing.T, url string, numReqs uint64, echo bool, body string,
+) *Bombardier {
+	b, err := NewBombardier(Config{
+		NumConns:      2,
+		NumReqs:       &numReqs,
+		Url:           url,
+		Headers:       new(HeadersList),
+		Timeout:       defaultTimeout,
+		Method:        "GET",
+		Body:          body,
+		ClientType:    ws,
+		WebSocketEcho: echo,
+		Format:        knownFormat("plain-text"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.disableOutput()
+	return b
+}
+
+func TestWSAcceptKey(t *testing.T) {
+	// Example from RFC 6455
+	exp := "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="
+	if act := wsAcceptKey("dGhlIHNhbXBsZSBub25jZQ=="); act != exp {
+		t.Errorf("Expected %v, but got %v", exp, act)
+	}
+}
+
+func TestBombardierWebSocketHandshakes(t *testing.T) {
+	s := httptest.NewServer(&wsEchoServer{t: t})
+	defer s.Close()
+	url := "ws" + strings.TrimPrefix(s.URL, "http")
+	numReqs := uint64(10)
+	b := newWSTestBombardier(t, url, numReqs, false, "")
+	b.Bombard()
+
+	if b.req1xx != numReqs {
+		t.Errorf("Expected %v handshakes, but got %v", numReqs, b.req1xx)
+	}
+	stats := b.gatherInfo().Result.WebSocket
+	if stats == nil {
+		t.Fatal("Expected WebSocket stats")
+	}
+	if stats.Connects != numReqs || stats.ConnectFailures != 0 {
+		t.Errorf("Expected %v connects, but got %+v", numReqs, stats)
+	}
+	if stats.Messages != 0 {
+		t.Errorf("Expected no messages, but got %v", stats.Messages)
+	}
+}
+
+func TestBombardierWebSocketEcho(t *testing.T) {
+	server := &wsEchoServer{t: t}
+	s := httptest.NewServer(server)
+	defer s.Close()
+	numReqs := uint64(20)
+	body := "hello"
+	b := newWSTestBombardier(t, s.URL, numReqs, true, body)
+	b.Bombard()
+
+	stats := b.gatherInfo().Result.WebSocket
+	if stats.Messages != numReqs {
+		t.Errorf("Expected %v messages, but got %v", numReqs, stats.Messages)
+	}
+	if stats.Connects == 0 || stats.Connects > b.Conf.NumConns {
+		t.Errorf("Expected up to %v connects, but got %v",
+			b.Conf.NumConns, stats.Connects)
+	}
+	if errs := b.errors.byFrequency(); len(errs) > 0 {
+		t.Error(errs)
+	}
+	server.ml.Lock()
+	defer server.ml.Unlock()
+	for _, msg := range server.messages {
+		if msg != body {
+			t.Errorf("Expected %q, but got %q", body, msg)
+		}
+	}
+}
+
+func TestBombardierWebSocketCloseCodes(t *testing.T) {
+	tryAgainLater := 1013
+	s := httptest.NewServer(&wsEchoServer{
+		t:          t,
+		closeAfter: 1,
+		closeCode:  tryAgainLater,
+	})
+	defer s.Close()
+	numReqs := uint64(6)
+	b := newWSTestBombardier(t, s.URL, numReqs, true, "hello")
+	b.Bombard()
+
+	stats := b.gatherInfo().Result.WebSocket
+	if stats.CloseCodes[tryAgainLater] != numReqs {
+		t.Errorf("Expected %v closes with %v, but got %v",
+			numReqs, tryAgainLater, stats.CloseCodes)
+	}
+	if b.others != numReqs {
+		t.Errorf("Expected %v failed messages, but got %v", numReqs, b.others)
+	}
+	errs := b.errors.byFrequency()
+	if len(errs) != 1 || errs[0].error != errWebSocketClosed.Error() {
+		t.Errorf("Expected %v, but got %v", errWebSocketClosed, errs)
+	}
+}
+
+func TestBombardierWebSocketRejectedHandshakes(t *testing.T) {
+	var requests uint64
+	s := httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			atomic.AddUint64(&requests, 1)
+			rw.WriteHeader(http.StatusForbidden)
+		}),
+	)
+	defer s.Close()
+	numReqs := uint64(5)
+	b := newWSTestBombardier(t, s.URL, numReqs, true, "hello")
+	b.Bombard()
+
+	if b.req4xx != numReqs {
+		t.Errorf("Expected %v 4xx, but got %v", numReqs, b.req4xx)
+	}
+	stats := b.gatherInfo().Result.WebSocket
+	if stats.ConnectFailures != numReqs || stats.Connects != 0 {
+		t.Errorf("Expected %v connect failures, but got %+v",
+			numReqs, stats)
+	}
+	if requests != numReqs {
+		t.Errorf("Expected %v handshakes, but got %v", numReqs, requests)
+	}
+}
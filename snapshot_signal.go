@@ -0,0 +1,25 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/tony24681379/bombardier/lib"
+)
+
+// printSnapshotsOnSignal prints intermediate results of the test
+// each time the process receives SIGUSR1.
+func printSnapshotsOnSignal(b *lib.Bombardier) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGUSR1)
+
+	go func() {
+		for range c {
+			b.PrintSnapshot()
+		}
+	}()
+}
@@ -0,0 +1,10 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package main
+
+import "github.com/tony24681379/bombardier/lib"
+
+// printSnapshotsOnSignal does nothing, since there is no SIGUSR1 on
+// this platform. Use --snapshot-every instead.
+func printSnapshotsOnSignal(b *lib.Bombardier) {}
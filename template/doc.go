@@ -24,6 +24,15 @@ besides those described in aforementioned documentation, namely:
 		Arithmetics are not available inside of templates either.
 	- StringToBytes(s string) []byte
 		Convenience function to convert string to []byte.
+	- Uint64ToFloat64(u uint64) float64
+		Converts uint64 to float64, for the same reason as
+		FormatTimeUsUint64 and FloatsToArray exist.
+	- EscapeMarkdown(s string) string
+		Escapes characters that would otherwise break a cell of
+		a Markdown table.
+	- PercentChange(old, new float64) string
+		Formats relative change from old to new in percents,
+		i.e. "+12.50%" or "-3.00%" ("n/a" if old is zero).
 	- UUIDV1() (UUID, error)
 		Generates UUID Version 1, based on timestamp and
 		MAC address (RFC 4122)
@@ -44,6 +53,60 @@ fields, the former contains various information about the test
 (number of connections, URL, HTTP method, headers, body, rate, etc.)
 performed, while the latter contains results obtained during the
 execution of this test (bytes read/written, time taken, RPS, etc.).
+If --baseline flag were used, Baseline field contains results of
+the previous test read from the provided file, otherwise it's nil.
+If --grpc flag were used, Spec.IsGRPC() is true and Result.GRPCCodes
+contains gRPC codes instead of HTTP codes (Req1XX-Req5XX fields).
+If --websocket or --websocket-echo flag were used, Spec.IsWebSocket()
+is true and Result.WebSocket contains numbers of connects, connect
+failures, echoed messages and close codes received from the server.
+If --tcp flag were used, Spec.IsRawTCP() is true and
+Result.TCPResponses contains number of received responses, that
+aren't classified by HTTP codes.
+If --pipeline flag were used, Spec.IsPipelined() is true and
+Spec.PipelineDepth contains the number of requests in flight per
+connection.
+If --stream-events flag were used, Result.StreamStats(percentiles)
+returns statistics of time to first byte, time to first event, gaps
+between events, stream durations and number of events per stream,
+otherwise it returns nil.
+If --proxy flag were used, Spec.Proxy contains its URL (with password
+redacted) and Result.ProxyConnectStats(percentiles) returns statistics
+of time taken to establish tunnels through the proxy, otherwise it
+returns nil.
+If one of HTTP clients were used with any of connection options (i.e.
+keep-alive disabled, limits or idle timeout), Result.Connections
+contains numbers of connections opened, reused (requests sent over
+previously used connections) and closed by the server.
+If one of --h2c, --h2-conns, --h2-max-streams, --h2-stream-window or
+--h2-conn-window flags were used, Result.HTTP2 contains numbers of
+HTTP/2 connections, streams sent over them, GOAWAY and RST_STREAM
+frames received from the server, and Result.HTTP2.StreamsPerConnection
+returns the average number of streams sent over a connection.
+If --follow-redirects flag were used, Result.Redirects contains the
+number of redirected requests and numbers of intermediate responses
+followed by status code (Result.Redirects.Followed returns their sum),
+while Result.Req1XX through Result.Req5XX only count final responses.
+Latencies describe whole chains of redirects and
+Result.RedirectHopStats(percentiles) returns statistics of each hop,
+otherwise it returns nil.
+If --decompress flag were used, Result.Compression contains the number
+of decoded responses and their sizes before and after decoding, while
+Result.BytesRead counts bytes read from connections, and
+Result.Compression.Ratio returns the ratio of these sizes.
+If --body-random flag were used, Result.BodySizes contains sizes of
+generated request bodies in bytes and Result.BodySizeStats(percentiles)
+returns their statistics, otherwise it returns nil.
+Result.ResponseSizes contains sizes of response bodies in bytes and
+Result.ResponseSizeStats(percentiles) returns their statistics, if HTTP
+clients were used, otherwise it returns nil.
+If --resolve or --dns-server flags were used, Result.Backends maps
+addresses connections were made to, to numbers of connections, failed
+attempts to connect and bytes read and written over them, otherwise
+it's nil.
+If --local-addr flag were used, Result.Sources maps local addresses
+connections were made from, to numbers of connections and errors, that
+occurred while connecting from them, otherwise it's nil.
 
 Link to GoDoc for the structure used in template:
 https://godoc.org/github.com/codesenberg/bombardier/internal#TestInfo